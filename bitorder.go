@@ -0,0 +1,209 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//isMSB reports if the Defaults in the options pack bits from the most significant bit of each byte.
+func isMSB(options []EncDecOption) (bool, error) {
+	switch order := defaultBitOrder(options); order {
+	case "", "lsb":
+		return false, nil
+	case "msb":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported bitorder value: %v", order)
+	}
+}
+
+//msbWriter packs the bits of a struct with bitorder=msb from the most significant bit of each byte, writing each byte
+// to w once it is full. Numbers are given to it by writeOrdered from their most significant bit and bytes are written
+// the same. The bits written by custom coding keep their order, so they must fill whole bytes.
+type msbWriter struct {
+	w bits.BitSetWriter
+	//pending are the bits of the byte being packed, from its most significant bit
+	pending [8]bool
+	used    int
+}
+
+func (m *msbWriter) Write(bytes []byte) (int, error) {
+	for i, b := range bytes {
+		for j := 7; j >= 0; j-- {
+			if err := m.put(b&(1<<uint(j)) != 0); err != nil {
+				return i, err
+			}
+		}
+	}
+	return len(bytes), nil
+}
+
+func (m *msbWriter) WriteBits(bits []bool) (int, error) {
+	if m.used != 0 || len(bits)%8 != 0 {
+		return 0, fmt.Errorf("bits written by custom coding in a bitorder=msb struct must fill whole bytes")
+	}
+	if err := writeBits(m.w, bits); err != nil {
+		return 0, err
+	}
+	return len(bits), nil
+}
+
+//writeNumber writes the little endian bits b of a number from its most significant bit, the same as reading the bits
+// of the little endian bits written for the other endianness backwards.
+func (m *msbWriter) writeNumber(b []bool, endianness binary.ByteOrder) error {
+	var scratch [64]bool
+	ordered := b
+	if endianness != binary.BigEndian {
+		ordered = swapBytes(scratch[:len(b)], b)
+	}
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if err := m.put(ordered[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//put adds bit to the byte being packed.
+func (m *msbWriter) put(bit bool) error {
+	m.pending[m.used] = bit
+	m.used++
+	if m.used < 8 {
+		return nil
+	}
+
+	m.used = 0
+	var b [8]bool
+	for i := range b {
+		b[i] = m.pending[7-i]
+	}
+	return writeBits(m.w, b[:])
+}
+
+//writerPos returns the number of bits written to buf, ok is false when the writer does not tell.
+func writerPos(buf bits.BitSetWriter) (n int, ok bool) {
+	switch w := buf.(type) {
+	case *byteWriter:
+		return w.pos(), true
+	case *bitCounter:
+		return w.n, true
+	case *msbWriter:
+		return w.used, true
+	case *bits.BitSetBuffer:
+		return bitPos(w), true
+	}
+	return 0, false
+}
+
+//encodeFields encodes the fields of the struct v. The fields of a struct with bitorder=msb are written through an
+// msbWriter, and those of a struct with bitorder=lsb held by one past it.
+func encodeFields(t reflect.Type, v reflect.Value, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	fields, inner, err := fieldsOf(t, options)
+	if err != nil {
+		return err
+	}
+	msb, err := isMSB(inner)
+	if err != nil {
+		return fmt.Errorf("%v: %v", t, err)
+	}
+	_, packing := buf.(*msbWriter)
+	switched := msb != packing
+
+	w := buf
+	if switched {
+		if n, ok := writerPos(buf); ok && n%8 != 0 {
+			return fmt.Errorf("%v: a struct with a different bit order must start on a byte boundary", t)
+		}
+		if m, ok := buf.(*msbWriter); ok {
+			w = m.w
+		} else {
+			w = &msbWriter{w: buf}
+		}
+	}
+
+	for _, sf := range fields {
+		if err := EncodeField(sf.Name, sf.Type, encodedField(v, sf, inner), sf.Tag, w, sizeMap, inner...); err != nil {
+			return err
+		}
+	}
+
+	if n, ok := writerPos(w); ok && switched && n%8 != 0 {
+		return fmt.Errorf("%v: a struct with a different bit order must end on a byte boundary", t)
+	}
+	return nil
+}
+
+//readOrdered reads a number of n bits packed from the most significant bit of each byte, returning its little endian
+// bits. The bits are those written by msbWriter.writeNumber, read from the other end of their bytes.
+func readOrdered(buf *bits.BitSetBuffer, cursor *readCursor, n int, endianness binary.ByteOrder) ([]bool, error) {
+	start := cursor.bitPos(buf)
+	if remaining := len(buf.Set) - start; n > remaining || (start+n+7)/8*8 > len(buf.Set) {
+		return nil, fmt.Errorf("only %v of %v bits read", remaining, n)
+	}
+	b := make([]bool, n)
+	for i := range b {
+		pos := start + i
+		b[n-1-i] = buf.Set[pos/8*8+7-pos%8]
+	}
+	if err := skipBits(buf, cursor, n); err != nil {
+		return nil, err
+	}
+	if endianness == binary.BigEndian {
+		return b, nil
+	}
+	return unswapBytes(make([]bool, n), b), nil
+}
+
+//readOrderedUint is bits.ReadUint, reading the number from the most significant bit of each byte when msb is set.
+func readOrderedUint(buf *bits.BitSetBuffer, cursor *readCursor, n int, endianness binary.ByteOrder, msb bool) (uint64, error) {
+	if !msb {
+		return bits.ReadUint(buf, n, endianness)
+	}
+	b, err := readOrdered(buf, cursor, n, endianness)
+	if err != nil {
+		return 0, err
+	}
+	var x uint64
+	for i, bit := range b {
+		if bit {
+			x |= 1 << uint(i)
+		}
+	}
+	return x, nil
+}
+
+//readOrderedInt is bits.ReadInt, reading the number from the most significant bit of each byte when msb is set.
+func readOrderedInt(buf *bits.BitSetBuffer, cursor *readCursor, n int, endianness binary.ByteOrder, msb bool) (int64, error) {
+	if !msb {
+		return bits.ReadInt(buf, n, endianness)
+	}
+	x, err := readOrderedUint(buf, cursor, n, endianness, msb)
+	if err != nil {
+		return 0, err
+	}
+	//the sign bit is extended
+	shift := uint(64 - n)
+	return int64(x<<shift) >> shift, nil
+}
+
+//readByteData reads bs from buf, each byte from its most significant bit when msb is set. Bytes starting on a byte
+// boundary are the same in both bit orders.
+func readByteData(buf *bits.BitSetBuffer, cursor *readCursor, bs []byte, msb bool) (int, error) {
+	if !msb || cursor.bitPos(buf)%8 == 0 {
+		return buf.Read(bs)
+	}
+	for i := range bs {
+		if cursor.remaining(buf) < 8 {
+			return i, nil
+		}
+		x, err := readOrderedUint(buf, cursor, 8, binary.LittleEndian, msb)
+		if err != nil {
+			return i, err
+		}
+		bs[i] = byte(x)
+	}
+	return len(bs), nil
+}
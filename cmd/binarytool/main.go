@@ -0,0 +1,152 @@
+//Command binarytool decodes binary records into JSON and encodes JSON records into binary, using a schema in place of
+// a Go struct. The schema is read by schema.Parse, it is the JSON or YAML written by Schema.JSON and Schema.YAML or
+// the same written by hand.
+//
+//	binarytool decode -schema header.yaml packets.bin
+//	binarytool encode -schema header.yaml -o packets.bin records.json
+//
+// Decode reads records one after another until the input ends, each starting on a byte boundary, and writes each as a
+// line of JSON. Encode reads a stream of JSON objects and writes the records one after another. The JSON is that of
+// binary.BinaryToJSON and binary.JSONToBinary.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+	"github.com/nathanhack/binary"
+	"github.com/nathanhack/binary/schema"
+)
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "decode" && os.Args[1] != "encode") {
+		usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	schemaFile := flags.String("schema", "", "schema file, JSON or YAML")
+	output := flags.String("o", "", "file to write, standard output when empty")
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[2:])
+
+	if *schemaFile == "" || flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(command, *schemaFile, flags.Arg(0), *output); err != nil {
+		fmt.Fprintf(os.Stderr, "binarytool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: binarytool decode|encode -schema file [flags] [input]\n")
+}
+
+func run(command, schemaFile, input, output string) error {
+	data, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return err
+	}
+	layout, err := schema.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%v: %v", schemaFile, err)
+	}
+	t := layout.Type()
+
+	in := os.Stdin
+	if input != "" {
+		if in, err = os.Open(input); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	out := os.Stdout
+	if output != "" {
+		if out, err = os.Create(output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	w := bufio.NewWriter(out)
+	if command == "decode" {
+		err = decode(t, in, w)
+	} else {
+		err = encode(t, in, w)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+//decode writes the records of in as lines of JSON.
+func decode(t reflect.Type, in io.Reader, w io.Writer) error {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	buf, err := bits.NewFromBytes(data)
+	if err != nil {
+		return err
+	}
+	prototype := reflect.New(t).Interface()
+	for i, pos := 0, 0; pos < len(data)*8; i++ {
+		js, n, err := binary.BitsToJSON(buf, prototype)
+		if err != nil {
+			return fmt.Errorf("record %v: %v", i, err)
+		}
+		if n == 0 {
+			return fmt.Errorf("record %v: no data decoded", i)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", js); err != nil {
+			return err
+		}
+
+		//the next record starts on the next byte
+		pos += n
+		if pad := (8 - pos%8) % 8; pad > 0 {
+			if _, err := buf.ReadBits(make([]bool, pad)); err != nil {
+				return fmt.Errorf("record %v: %v", i, err)
+			}
+			pos += pad
+		}
+	}
+	return nil
+}
+
+//encode writes the records of the JSON objects in in.
+func encode(t reflect.Type, in io.Reader, w io.Writer) error {
+	prototype := reflect.New(t).Interface()
+	dec := json.NewDecoder(in)
+	for i := 0; ; i++ {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("record %v: %v", i, err)
+		}
+
+		bs, err := binary.JSONToBinary(record, prototype)
+		if err != nil {
+			return fmt.Errorf("record %v: %v", i, err)
+		}
+		if _, err := w.Write(bs); err != nil {
+			return err
+		}
+	}
+}
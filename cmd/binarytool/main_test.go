@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/nathanhack/binary/schema"
+)
+
+const testSchema = `
+name: Record
+fields:
+  - {name: version, kind: uint8, bits: 4}
+  - {name: kind, kind: uint8, bits: 4}
+  - {name: length, kind: uint16, endian: big}
+  - name: payload
+    kind: slice
+    size: length
+    elem: {kind: uint8}
+  - {name: last, kind: bool}
+`
+
+func TestDecodeEncode(t *testing.T) {
+	layout, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	//each record ends with 7 bits of padding after last
+	data := []byte{
+		0x14, 0x00, 0x02, 0xde, 0xad, 0x00,
+		0x23, 0x00, 0x00, 0x00,
+		0x11, 0x00, 0x01, 0xff, 0x01,
+	}
+	var js bytes.Buffer
+	if err := decode(layout.Type(), bytes.NewReader(data), &js); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expected := `{"version":4,"kind":1,"length":2,"payload":"dead","last":false}
+{"version":3,"kind":2,"length":0,"payload":"","last":false}
+{"version":1,"kind":1,"length":1,"payload":"ff","last":true}
+`
+	if js.String() != expected {
+		t.Fatalf("expected\n%v\nbut found\n%v", expected, js.String())
+	}
+
+	var actual bytes.Buffer
+	if err := encode(layout.Type(), bytes.NewReader(js.Bytes()), &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(data, actual.Bytes()) {
+		t.Fatalf("expected %x but found %x", data, actual.Bytes())
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	layout, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	var js bytes.Buffer
+	if err := decode(layout.Type(), bytes.NewReader([]byte{0x14, 0x00, 0x02, 0xde}), &js); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
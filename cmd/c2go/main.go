@@ -0,0 +1,50 @@
+//Command c2go generates tagged Go structs from packed C struct definitions.
+//
+//	c2go -pkg packets -o ipv4.go ipv4.h
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nathanhack/binary/cstruct"
+)
+
+func main() {
+	pkg := flag.String("pkg", "main", "package of the generated code")
+	output := flag.String("o", "", "file to write, standard output when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: c2go [flags] header.h\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *pkg, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "c2go: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, pkg, output string) error {
+	c, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	src, err := cstruct.Import(c, pkg)
+	if err != nil {
+		return fmt.Errorf("%v: %v", input, err)
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0644)
+}
@@ -0,0 +1,50 @@
+//Command ksy2go generates tagged Go structs from a Kaitai Struct (.ksy) spec.
+//
+//	ksy2go -pkg packets -o ipv4.go ipv4.ksy
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nathanhack/binary/kaitai"
+)
+
+func main() {
+	pkg := flag.String("pkg", "main", "package of the generated code")
+	output := flag.String("o", "", "file to write, standard output when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ksy2go [flags] spec.ksy\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *pkg, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "ksy2go: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, pkg, output string) error {
+	ksy, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	src, err := kaitai.Import(ksy, pkg)
+	if err != nil {
+		return fmt.Errorf("%v: %v", input, err)
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0644)
+}
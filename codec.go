@@ -0,0 +1,153 @@
+package binary
+
+import (
+	"reflect"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//Codec holds a set of options so they can be given once and reused. The StructEncDec and InterfaceEncDec options are
+// kept by type, so finding the one for a value does not go through all of them. The settings, such as Defaults and
+// DecodeOptions, apply to every call. The options given to a call take the place of those of the codec: their
+// handlers are used first and their settings follow the ones of the codec.
+//
+//	codec, err := binary.NewCodec(&binary.StructEncDec{...}, binary.DefaultEndian("big"))
+//	...
+//	bs, err := codec.Encode(&msg)
+//	err = codec.Decode(bs, &msg)
+//
+// A Codec is itself an option, it can be given to the functions taking options, e.g. Describe(t, codec). It is not
+// changed once made and may be used by many goroutines at once.
+type Codec struct {
+	handlers map[reflect.Type]EncDecOption
+	settings []EncDecOption
+	//fieldCodecs is set when a handler is a FieldCodec, which needs the path of the values
+	fieldCodecs bool
+	//lookup is the codec with the handlers alone, put after the options of a call by with
+	lookup *Codec
+}
+
+//NewCodec returns a Codec holding the options. When more than one option handles the same type the first one is
+// used, the same as when they are given to Encode and Decode. Options that are a Codec add all of its options.
+func NewCodec(options ...EncDecOption) (*Codec, error) {
+	if err := validateOptions(options...); err != nil {
+		return nil, err
+	}
+
+	c := &Codec{handlers: map[reflect.Type]EncDecOption{}}
+	c.add(options)
+	c.lookup = &Codec{handlers: c.handlers, fieldCodecs: c.fieldCodecs}
+	return c, nil
+}
+
+func (c *Codec) add(options []EncDecOption) {
+	for _, item := range options {
+		switch o := item.(type) {
+		case *Codec:
+			for t, handler := range o.handlers {
+				if _, has := c.handlers[t]; !has {
+					c.handlers[t] = handler
+				}
+			}
+			c.settings = append(c.settings, o.settings...)
+			c.fieldCodecs = c.fieldCodecs || o.fieldCodecs
+		case *decodeState, *fieldPath, *hiddenFields, *readCursor:
+			//the state of a call in progress is not kept
+		case setting:
+			c.settings = append(c.settings, o)
+		default:
+			if _, has := c.handlers[item.Type()]; !has {
+				c.handlers[item.Type()] = item
+			}
+			if o, ok := item.(*TypeEncDec); ok && o.codec != nil {
+				c.fieldCodecs = true
+			}
+		}
+	}
+}
+
+func (c *Codec) Type() reflect.Type {
+	return nil
+}
+
+func (c *Codec) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (c *Codec) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (c *Codec) setting() {}
+
+//with returns the options of a single call in between the settings and the handlers of the codec, so the options of
+// the call are used over those of the codec.
+func (c *Codec) with(options []EncDecOption) []EncDecOption {
+	result := make([]EncDecOption, 0, len(c.settings)+len(options)+1)
+	result = append(result, c.settings...)
+	result = append(result, options...)
+	if c.lookup == nil {
+		//a Codec not made by NewCodec
+		return append(result, &Codec{handlers: c.handlers, fieldCodecs: c.fieldCodecs})
+	}
+	return append(result, c.lookup)
+}
+
+//Encode is Encode with the options given used over the options of the codec.
+func (c *Codec) Encode(st interface{}, options ...EncDecOption) ([]byte, error) {
+	return Encode(st, c.with(options)...)
+}
+
+//AppendEncode is AppendEncode with the options given used over the options of the codec.
+func (c *Codec) AppendEncode(dst []byte, st interface{}, options ...EncDecOption) ([]byte, error) {
+	return AppendEncode(dst, st, c.with(options)...)
+}
+
+//EncodeToBits is EncodeToBits with the options given used over the options of the codec.
+func (c *Codec) EncodeToBits(st interface{}, options ...EncDecOption) (*bits.BitSetBuffer, error) {
+	return EncodeToBits(st, c.with(options)...)
+}
+
+//Decode is Decode with the options given used over the options of the codec.
+func (c *Codec) Decode(data []byte, value interface{}, options ...EncDecOption) error {
+	return Decode(data, value, c.with(options)...)
+}
+
+//DecodeN is DecodeN with the options given used over the options of the codec.
+func (c *Codec) DecodeN(data []byte, value interface{}, options ...EncDecOption) (int, error) {
+	return DecodeN(data, value, c.with(options)...)
+}
+
+//DecodeToBits is DecodeToBits with the options given used over the options of the codec.
+func (c *Codec) DecodeToBits(buf *bits.BitSetBuffer, value interface{}, options ...EncDecOption) error {
+	return DecodeToBits(buf, value, c.with(options)...)
+}
+
+//lookupOption returns the option handling values of type t, nil when there is none.
+func lookupOption(t reflect.Type, options []EncDecOption) EncDecOption {
+	for _, item := range options {
+		if c, ok := item.(*Codec); ok {
+			if handler, has := c.handlers[t]; has {
+				return handler
+			}
+			continue
+		}
+		if item.Type() == t {
+			return item
+		}
+	}
+	return nil
+}
+
+//eachSetting calls f with each of the options in order, with the settings held by codecs in place of the codecs.
+func eachSetting(options []EncDecOption, f func(item EncDecOption)) {
+	for _, item := range options {
+		if c, ok := item.(*Codec); ok {
+			for _, setting := range c.settings {
+				f(setting)
+			}
+			continue
+		}
+		f(item)
+	}
+}
@@ -0,0 +1,185 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//byteEncDec returns a StructEncDec encoding HasInterface as the byte of its Value shifted down by shift.
+func byteEncDec(shift int) *StructEncDec {
+	return &StructEncDec{
+		StructType: reflect.TypeOf(HasInterface{}),
+		Encoder: func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+			_, err := buf.Write([]byte{byte(v.Interface().(HasInterface).Value >> shift)})
+			return err
+		},
+		Decoder: func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+			b := make([]byte, 1)
+			if _, err := buf.Read(b); err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(HasInterface{Value: uint32(b[0]) << shift}))
+			return nil
+		},
+	}
+}
+
+func TestCodec(t *testing.T) {
+	type inner struct {
+		Thing HasInterface
+		Count uint16
+	}
+	type message struct {
+		Thing  HasInterface
+		Length uint16
+		Items  []inner `size:"2"`
+	}
+
+	codec, err := NewCodec(byteEncDec(16), byteEncDec(0), DefaultEndian("big"))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	input := message{Thing: HasInterface{0x11223344}, Length: 0x0102, Items: []inner{{HasInterface{0x00550000}, 3}, {HasInterface{0}, 4}}}
+	expected := []byte{0x22, 0x01, 0x02, 0x55, 0x00, 0x03, 0x00, 0x00, 0x04}
+	bs, err := codec.Encode(&input)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	//the codec gives the same result as its options
+	direct, err := Encode(&input, byteEncDec(16), DefaultEndian("big"))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(direct, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", direct, bs)
+	}
+
+	var actual message
+	if err := codec.Decode(bs, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	decoded := message{Thing: HasInterface{0x00220000}, Length: 0x0102, Items: []inner{{HasInterface{0x00550000}, 3}, {HasInterface{0}, 4}}}
+	if !reflect.DeepEqual(decoded, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", decoded, actual)
+	}
+
+	//the options of a call are used over the ones of the codec
+	var partial message
+	n, err := codec.DecodeN(bs, &partial, StopAfter("Length"))
+	if err != nil || n != 24 || partial.Length != 0x0102 || partial.Items != nil {
+		t.Fatalf("expected to stop after Length but found %v %v %#v", n, err, partial)
+	}
+	bs, err = codec.Encode(&input, DefaultEndian("little"))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if bs[1] != 0x02 {
+		t.Fatalf("expected the little endian option of the call but found %x", bs)
+	}
+	bs, err = codec.Encode(&input, byteEncDec(8))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if bs[0] != 0x33 {
+		t.Fatalf("expected the encoder of the call but found %x", bs)
+	}
+
+	//a codec is an option to the functions taking options
+	size, fixed := StaticBitSize(reflect.TypeOf(inner{}), codec)
+	if fixed {
+		t.Fatalf("expected no static size with a custom encoder but found %v", size)
+	}
+	schema, err := Describe(reflect.TypeOf(input), codec)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if schema.Fields[1].Endian != "big" {
+		t.Fatalf("expected the endianness of the codec but found %v", schema.Fields[1].Endian)
+	}
+
+	//codecs add the options of codecs given to them
+	outer, err := NewCodec(codec, &DecodeOptions{DisallowTrailingData: true})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if err := outer.Decode(append(expected, 0), &actual); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := outer.Decode(expected, &actual); err != nil || !reflect.DeepEqual(decoded, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n %v", decoded, actual, err)
+	}
+}
+
+func TestCodecSettingsAllocs(t *testing.T) {
+	codec, err := NewCodec(byteEncDec(8), DefaultEndian("big"), &DecodeOptions{MaxDepth: 4})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	//the settings of a codec are found for every field, so that must not allocate
+	options := []EncDecOption{DefaultEndian("little"), codec}
+	endian := ""
+	if allocs := testing.AllocsPerRun(100, func() { endian = defaultEndian(options) }); allocs != 0 {
+		t.Fatalf("expected no allocations but found %v", allocs)
+	}
+	if endian != "big" {
+		t.Fatalf("expected the endianness of the codec but found %v", endian)
+	}
+}
+
+func TestCodecConcurrent(t *testing.T) {
+	type message struct {
+		Thing HasInterface
+		Value uint32
+	}
+	codec, err := NewCodec(byteEncDec(8), DefaultEndian("big"))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := message{HasInterface{uint32(i) << 8}, uint32(i)}
+			bs, err := codec.Encode(&input)
+			if err != nil {
+				errs <- err
+				return
+			}
+			var actual message
+			if err := codec.Decode(bs, &actual); err != nil {
+				errs <- err
+				return
+			}
+			if !reflect.DeepEqual(input, actual) {
+				errs <- fmt.Errorf("expected %v but found %v", input, actual)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestNewCodecErrors(t *testing.T) {
+	if _, err := NewCodec(&StructEncDec{StructType: reflect.TypeOf(HasInterface{})}); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := NewCodec(&StructEncDec{}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,42 @@
+//Package cstruct converts between tagged structs and C struct definitions. Header writes a C header declaring the
+// same layout as a struct, Import generates tagged structs from C struct definitions.
+//
+// Both directions use the layout GCC and Clang give packed structs (`#pragma pack(1)`) on little endian machines:
+// bit fields are allocated from the least significant bit and run across bytes, the same as this library packs bits.
+// MSVC allocates bit fields differently and is not supported.
+package cstruct
+
+import (
+	"fmt"
+
+	"github.com/nathanhack/binary/internal/names"
+)
+
+var keywords = map[string]bool{
+	"auto": true, "bool": true, "break": true, "case": true, "char": true, "const": true, "continue": true,
+	"default": true, "do": true, "double": true, "else": true, "enum": true, "extern": true, "float": true,
+	"for": true, "goto": true, "if": true, "inline": true, "int": true, "long": true, "register": true,
+	"restrict": true, "return": true, "short": true, "signed": true, "sizeof": true, "static": true,
+	"struct": true, "switch": true, "typedef": true, "union": true, "unsigned": true, "void": true,
+	"volatile": true, "while": true,
+}
+
+//identifier returns the C identifier of a Go name, keywords get a trailing underscore.
+func identifier(name string) (string, error) {
+	id := names.Snake(name)
+	for i, r := range id {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z'):
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return "", fmt.Errorf("%q can not be a C identifier", name)
+		}
+	}
+	if id == "" {
+		return "", fmt.Errorf("empty identifier")
+	}
+	if keywords[id] {
+		id += "_"
+	}
+	return id, nil
+}
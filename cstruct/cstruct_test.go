@@ -0,0 +1,392 @@
+package cstruct
+
+import (
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nathanhack/binary"
+)
+
+type cFlags uint8
+
+var _ = binary.RegisterFlags(cFlags(0), "Ack", "", "Retry")
+
+type cPoint struct {
+	X int16 `bits:"12"`
+	Y int16 `bits:"12"`
+}
+
+type cFrame struct {
+	Version uint8  `bits:"4"`
+	Flags   cFlags `bits:"4"`
+	Length  uint16 `endian:"big"`
+	Offset  uint16 `bits:"13" endian:"big"`
+	Small   int8   `bits:"3"`
+	Origin  cPoint
+	Path    [2]cPoint
+	Nibbles [3]uint8 `bits:"4"`
+	Wide    uint32   `bits:"20"`
+	Name    string   `strlen:"6"`
+	Ratio   float32
+	Counter uint64
+	Signed  int32  `bits:"17"`
+	Enabled bool   `bits:"1"`
+	Tail    uint16 `bits:"14"`
+	Data    []byte
+}
+
+const frameHeader = `/* Code generated by cstruct.Header from cFrame. DO NOT EDIT. */
+
+#ifndef C_FRAME_H
+#define C_FRAME_H
+
+#include <stdint.h>
+
+#pragma pack(push, 1)
+
+struct c_point {
+	int16_t x : 12;
+	int16_t y : 12;
+};
+_Static_assert(sizeof(struct c_point) == 3, "struct c_point must be 3 bytes");
+
+struct c_frame {
+	uint8_t version : 4;
+	uint8_t flags : 4; /* flags: Ack (bit 0), Retry (bit 2) */
+	uint16_t length; /* big endian */
+	uint8_t offset_0 : 5; /* big endian offset is (offset_0 << 8) | offset_1 */
+	uint8_t offset_1 : 8;
+	int8_t small : 3;
+	struct c_point origin;
+	struct c_point path[2];
+	uint8_t nibbles_0 : 4;
+	uint8_t nibbles_1 : 4;
+	uint8_t nibbles_2 : 4;
+	uint32_t wide : 20;
+	char name[6];
+	float ratio;
+	uint64_t counter;
+	int32_t signed_ : 17;
+	uint8_t enabled : 1;
+	uint16_t tail : 14;
+	uint8_t data[]; /* the rest of the data */
+};
+
+#pragma pack(pop)
+
+#endif
+`
+
+var frame = cFrame{
+	Version: 5, Flags: 5, Length: 300, Offset: 0x1234, Small: -3,
+	Origin:  cPoint{X: -100, Y: 2000},
+	Path:    [2]cPoint{{X: 1, Y: -1}, {X: -2048, Y: 2047}},
+	Nibbles: [3]uint8{1, 15, 7}, Wide: 0xABCDE, Name: "abcdef", Ratio: 1.5, Counter: 0x0102030405060708,
+	Signed: -65536, Enabled: true, Tail: 0x3ffe, Data: []byte{1, 2, 3},
+}
+
+//frameMain sets the fields of a c_frame to the values of frame and prints its bytes.
+const frameMain = `#include <stdio.h>
+#include <string.h>
+#include "frame.h"
+
+int main(void) {
+	static unsigned char buf[sizeof(struct c_frame) + 3];
+	struct c_frame *f = (struct c_frame *)buf;
+	f->version = 5;
+	f->flags = 5;
+	f->length = (uint16_t)((300 >> 8) | (300 << 8));
+	f->offset_0 = 0x12;
+	f->offset_1 = 0x34;
+	f->small = -3;
+	f->origin.x = -100;
+	f->origin.y = 2000;
+	f->path[0].x = 1;
+	f->path[0].y = -1;
+	f->path[1].x = -2048;
+	f->path[1].y = 2047;
+	f->nibbles_0 = 1;
+	f->nibbles_1 = 15;
+	f->nibbles_2 = 7;
+	f->wide = 0xABCDE;
+	memcpy(f->name, "abcdef", 6);
+	f->ratio = 1.5f;
+	f->counter = 0x0102030405060708;
+	f->signed_ = -65536;
+	f->enabled = 1;
+	f->tail = 0x3ffe;
+	f->data[0] = 1;
+	f->data[1] = 2;
+	f->data[2] = 3;
+	for (size_t i = 0; i < sizeof buf; i++) {
+		printf("%02x", buf[i]);
+	}
+	return 0;
+}
+`
+
+const packetC = `#include <stdint.h>
+
+#pragma pack(push, 1)
+
+// a sensor reading
+typedef struct {
+	uint8_t kind : 3;
+	uint8_t valid : 1, stale : 1;
+	unsigned int : 3;
+	int16_t delta;
+} reading_t;
+
+struct packet {
+	uint16_t magic; /* big endian */
+	unsigned char version;
+	reading_t readings[2];
+	uint32_t timestamp : 24;
+	int8_t offsets[2][3];
+	uint8_t priority : 2;
+	_Bool urgent;
+	double scale;
+	char payload[];
+} __attribute__((packed));
+
+#pragma pack(pop)
+`
+
+const packetGo = "// Code generated by cstruct.Import. DO NOT EDIT.\n" + `
+package packets
+
+type Reading struct {
+	Kind      uint8  ` + "`bits:\"3\"`" + `
+	Valid     uint8  ` + "`bits:\"1\"`" + `
+	Stale     uint8  ` + "`bits:\"1\"`" + `
+	Reserved0 uint32 ` + "`bits:\"3\"`" + `
+	Delta     int16
+}
+
+type Packet struct {
+	Magic     uint16 ` + "`endian:\"big\"`" + `
+	Version   byte
+	Readings  [2]Reading
+	Timestamp uint32 ` + "`bits:\"24\"`" + `
+	Offsets   [2][3]int8
+	Priority  uint8 ` + "`bits:\"2\"`" + `
+	Reserved0 uint8 ` + "`bits:\"6\"`" + `
+	Urgent    bool
+	Scale     float64
+	Payload   []byte
+}
+`
+
+//importedReading and importedPacket are the structs of packetGo.
+type importedReading struct {
+	Kind      uint8  `bits:"3"`
+	Valid     uint8  `bits:"1"`
+	Stale     uint8  `bits:"1"`
+	Reserved0 uint32 `bits:"3"`
+	Delta     int16
+}
+
+type importedPacket struct {
+	Magic     uint16 `endian:"big"`
+	Version   byte
+	Readings  [2]importedReading
+	Timestamp uint32 `bits:"24"`
+	Offsets   [2][3]int8
+	Priority  uint8 `bits:"2"`
+	Reserved0 uint8 `bits:"6"`
+	Urgent    bool
+	Scale     float64
+	Payload   []byte
+}
+
+const packetMain = `#include <stdio.h>
+#include "packet.h"
+
+int main(void) {
+	static unsigned char buf[sizeof(struct packet) + 2];
+	struct packet *p = (struct packet *)buf;
+	p->magic = 0xadde;
+	p->version = 2;
+	p->readings[0].kind = 5;
+	p->readings[0].valid = 1;
+	p->readings[0].delta = -300;
+	p->readings[1].kind = 2;
+	p->readings[1].stale = 1;
+	p->readings[1].delta = 1000;
+	p->timestamp = 0x123456;
+	p->offsets[0][0] = -1;
+	p->offsets[0][2] = 7;
+	p->offsets[1][1] = -128;
+	p->priority = 3;
+	p->urgent = 1;
+	p->scale = -0.25;
+	p->payload[0] = 'o';
+	p->payload[1] = 'k';
+	for (size_t i = 0; i < sizeof buf; i++) {
+		printf("%02x", buf[i]);
+	}
+	return 0;
+}
+`
+
+var packet = importedPacket{
+	Magic: 0xdead, Version: 2,
+	Readings:  [2]importedReading{{Kind: 5, Valid: 1, Delta: -300}, {Kind: 2, Stale: 1, Delta: 1000}},
+	Timestamp: 0x123456,
+	Offsets:   [2][3]int8{{-1, 0, 7}, {0, -128, 0}},
+	Priority:  3,
+	Urgent:    true, Scale: -0.25, Payload: []byte("ok"),
+}
+
+//runC compiles and runs a C program, returning what it prints. The test is skipped when there is no C compiler.
+func runC(t *testing.T, files map[string]string) string {
+	t.Helper()
+	cc, err := exec.LookPath("gcc")
+	if err != nil {
+		if cc, err = exec.LookPath("cc"); err != nil {
+			t.Skip("no C compiler found")
+		}
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prog := filepath.Join(dir, "prog")
+	if out, err := exec.Command(cc, "-std=c11", "-Wall", "-Werror", "-o", prog, filepath.Join(dir, "main.c")).CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	out, err := exec.Command(prog).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestHeader(t *testing.T) {
+	h, err := Header(reflect.TypeOf(&cFrame{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(h) != frameHeader {
+		t.Errorf("expected:\n%v\nbut found:\n%v", frameHeader, string(h))
+	}
+}
+
+func TestHeaderLayout(t *testing.T) {
+	h, err := Header(reflect.TypeOf(cFrame{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := runC(t, map[string]string{"frame.h": string(h), "main.c": frameMain})
+
+	bs, err := binary.Encode(&frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != hex.EncodeToString(bs) {
+		t.Errorf("expected the C struct to match Encode:\nC:      %v\nEncode: %x", c, bs)
+	}
+}
+
+func TestImport(t *testing.T) {
+	src, err := Import([]byte(packetC), "packets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != packetGo {
+		t.Errorf("expected:\n%v\nbut found:\n%v", packetGo, string(src))
+	}
+}
+
+func TestImportLayout(t *testing.T) {
+	c := runC(t, map[string]string{"packet.h": packetC, "main.c": packetMain})
+
+	bs, err := binary.Encode(&packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != hex.EncodeToString(bs) {
+		t.Errorf("expected Encode to match the C struct:\nC:      %v\nEncode: %x", c, bs)
+	}
+}
+
+func TestHeaderImport(t *testing.T) {
+	h, err := Header(reflect.TypeOf(cFrame{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Import(h, "frames")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"type CPoint struct {",
+		"Origin   CPoint",
+		"Path     [2]CPoint",
+		"Length   uint16 `endian:\"big\"`",
+		"Offset0  uint8  `bits:\"5\"`",
+		"Name     [6]byte",
+		"Signed   int32  `bits:\"17\"`",
+		"Data     []uint8",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(src), e) {
+			t.Errorf("expected the import to contain %q:\n%v", e, string(src))
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	type unaligned struct {
+		A uint8 `bits:"3"`
+		B float32
+	}
+	type dynamic struct {
+		N    uint8
+		Data []byte `size:"N"`
+		M    uint8
+	}
+	type bitsFrom struct {
+		N uint8
+		V uint32 `bits:"N"`
+	}
+	type odd struct {
+		A uint8 `bits:"3"`
+	}
+	type nestedOdd struct {
+		O odd
+	}
+	for _, typ := range []reflect.Type{reflect.TypeOf(0), reflect.TypeOf(unaligned{}), reflect.TypeOf(dynamic{}),
+		reflect.TypeOf(bitsFrom{}), reflect.TypeOf(nestedOdd{})} {
+		if _, err := Header(typ); err == nil {
+			t.Errorf("%v: expected an error", typ)
+		}
+	}
+
+	for _, src := range []string{
+		"",
+		"int x;",
+		"struct a { long x; };",
+		"struct a { uint8_t x : 9; };",
+		"struct a { uint8_t x : 0; };",
+		"struct a { struct b x; };",
+		"struct a { uint8_t *x; };",
+		"struct a { float x : 3; };",
+		"struct a { uint8_t x[n]; };",
+		"struct a { uint8_t x; } /* unterminated",
+		"struct { uint8_t x; };",
+	} {
+		if _, err := Import([]byte(src), "p"); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+}
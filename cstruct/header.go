@@ -0,0 +1,300 @@
+package cstruct
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nathanhack/binary"
+	"github.com/nathanhack/binary/internal/bitfields"
+)
+
+//Header returns a C header declaring a packed struct with the layout of t, a tagged struct or a pointer to one. The
+// options are the ones given to Encode and Decode, values they handle can not be declared.
+//
+// Numbers that start on a byte boundary and use their whole width are plain members, all others are bit fields. C has
+// no big endian types, so these are marked in a comment and big endian bit fields are split into their bytes, with
+// the partial byte first. A slice or string without a fixed size can only be the last field, it becomes a flexible
+// array member.
+func Header(t reflect.Type, options ...binary.EncDecOption) ([]byte, error) {
+	schema, err := binary.Describe(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{types: map[string]string{}, used: map[string]bool{}}
+	root, err := h.structDef(schema.Name, schema.Name, schema.Fields, false)
+	if err != nil {
+		return nil, err
+	}
+	guard := strings.ToUpper(strings.TrimPrefix(root, "struct ")) + "_H"
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "/* Code generated by cstruct.Header from %v. DO NOT EDIT. */\n\n", schema.Name)
+	fmt.Fprintf(&out, "#ifndef %v\n#define %v\n\n", guard, guard)
+	out.WriteString("#include <stdint.h>\n\n")
+	out.WriteString("#pragma pack(push, 1)\n")
+	for _, def := range h.defs {
+		out.WriteString(def)
+	}
+	out.WriteString("\n#pragma pack(pop)\n\n")
+	out.WriteString("#endif\n")
+	return out.Bytes(), nil
+}
+
+type header struct {
+	//types are the C names of the structs by Go type, used are all the names given out
+	types map[string]string
+	used  map[string]bool
+	//defs are the struct definitions, each after the ones it uses
+	defs []string
+}
+
+//structDef returns the C type of the struct typeName, writing its definition when it is the first time the struct is
+// seen. name is the name of the field holding an anonymous struct. Nested structs must be whole bytes, as C starts
+// and ends them on a byte boundary.
+func (h *header) structDef(typeName, name string, fields []binary.FieldSchema, nested bool) (string, error) {
+	if ctype, has := h.types[typeName]; has && !strings.HasPrefix(typeName, "struct") {
+		return ctype, nil
+	}
+
+	if !strings.HasPrefix(typeName, "struct") {
+		name = typeName[strings.LastIndex(typeName, ".")+1:]
+	}
+	id, err := identifier(name)
+	if err != nil {
+		return "", err
+	}
+	unique := id
+	for i := 2; h.used[unique]; i++ {
+		unique = id + strconv.Itoa(i)
+	}
+	h.used[unique] = true
+	ctype := "struct " + unique
+	h.types[typeName] = ctype
+
+	var members []string
+	pos := 0
+	for i, f := range fields {
+		id, err := identifier(f.Name)
+		if err != nil {
+			return "", err
+		}
+		lines, err := h.member(id, f, &pos, i == len(fields)-1)
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", f.Name, err)
+		}
+		members = append(members, lines...)
+	}
+	if len(members) == 0 {
+		return "", fmt.Errorf("%v has no fields to declare", typeName)
+	}
+	if nested && (pos < 0 || pos%8 != 0) {
+		return "", fmt.Errorf("%v is not a whole number of bytes", typeName)
+	}
+
+	var def strings.Builder
+	fmt.Fprintf(&def, "\n%v {\n", ctype)
+	for _, m := range members {
+		fmt.Fprintf(&def, "\t%v\n", m)
+	}
+	def.WriteString("};\n")
+	if pos >= 0 {
+		size := (pos + 7) / 8
+		fmt.Fprintf(&def, "_Static_assert(sizeof(%v) == %v, \"%v must be %v bytes\");\n", ctype, size, ctype, size)
+	}
+	h.defs = append(h.defs, def.String())
+	return ctype, nil
+}
+
+//member returns the members declaring the field f starting at the bit position pos, which is advanced past it. pos
+// becomes -1 after a flexible array member.
+func (h *header) member(id string, f binary.FieldSchema, pos *int, last bool) ([]string, error) {
+	if *pos < 0 {
+		return nil, fmt.Errorf("no field can follow a flexible array member")
+	}
+
+	switch f.Kind {
+	case "bool", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		return h.number(id, f, pos)
+	case "array", "slice", "string":
+		count, fixed := f.Size, true
+		switch f.Kind {
+		case "array":
+			count = strconv.Itoa(f.Length)
+		case "string":
+			count = f.StrLen
+		}
+		if _, err := strconv.ParseUint(count, 10, 63); err != nil {
+			fixed = false
+		}
+
+		elem := binary.FieldSchema{Name: f.Name, Type: "uint8", Kind: "uint8", Bits: &eight}
+		if f.Elem != nil {
+			elem = *f.Elem
+			elem.Name = f.Name
+		}
+
+		if *pos%8 == 0 {
+			ctype, dims, comment, ok, err := h.plain(elem)
+			if err != nil {
+				return nil, err
+			}
+			if f.Kind == "string" {
+				ctype = "char"
+			}
+			switch {
+			case ok && fixed:
+				n, _ := strconv.Atoi(count)
+				*pos += n * *elem.Bits
+				return []string{fmt.Sprintf("%v %v[%v]%v;%v", ctype, id, count, dims, comment)}, nil
+			case ok && last:
+				*pos = -1
+				size := "the rest of the data"
+				if count != "" {
+					ref, err := identifier(count)
+					if err != nil {
+						return nil, err
+					}
+					size = ref + " items"
+					if f.Kind == "string" {
+						size = ref + " bytes"
+					}
+				}
+				return []string{fmt.Sprintf("%v %v[]%v; /* %v */", ctype, id, dims, size)}, nil
+			}
+		}
+		if !fixed {
+			return nil, fmt.Errorf("only the last field can have a size that is not a number, and it must start on a byte boundary")
+		}
+
+		//items that can not be a C array are declared one by one
+		n, _ := strconv.Atoi(count)
+		var members []string
+		for i := 0; i < n; i++ {
+			lines, err := h.member(fmt.Sprintf("%v_%v", id, i), elem, pos, false)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, lines...)
+		}
+		return members, nil
+	case "float32", "float64", "struct":
+		if *pos%8 != 0 {
+			return nil, fmt.Errorf("%v must start on a byte boundary", f.Kind)
+		}
+		ctype, dims, comment, _, err := h.plain(f)
+		if err != nil {
+			return nil, err
+		}
+		*pos += *f.Bits
+		return []string{fmt.Sprintf("%v %v%v;%v", ctype, id, dims, comment)}, nil
+	}
+	return nil, fmt.Errorf("%v of kind %v can not be declared", f.Type, f.Kind)
+}
+
+var eight = 8
+
+//plain returns the C type, array dimensions and comment of f when it is declared as a plain member starting on a byte
+// boundary. ok is false when it needs to be a bit field.
+func (h *header) plain(f binary.FieldSchema) (ctype, dims, comment string, ok bool, err error) {
+	switch f.Kind {
+	case "bool", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		ctype, natural := numberType(f.Kind)
+		if f.BitsFrom != "" || *f.Bits != natural {
+			return "", "", "", false, nil
+		}
+		if f.Endian == "big" && natural > 8 {
+			comment = " /* big endian */"
+		}
+		return ctype, "", comment + flagsComment(f), true, nil
+	case "float32", "float64":
+		ctype = "float"
+		if f.Kind == "float64" {
+			ctype = "double"
+		}
+		if f.Endian == "big" {
+			comment = " /* big endian */"
+		}
+		return ctype, "", comment, true, nil
+	case "struct":
+		if f.Bits == nil {
+			return "", "", "", false, fmt.Errorf("%v has no fixed size", f.Type)
+		}
+		ctype, err := h.structDef(f.Type, f.Name, f.Fields, true)
+		if err != nil {
+			return "", "", "", false, err
+		}
+		return ctype, "", "", true, nil
+	case "array", "string":
+		if f.Bits == nil || *f.Bits%8 != 0 {
+			return "", "", "", false, nil
+		}
+		if f.Kind == "string" {
+			return "char", "[" + f.StrLen + "]", "", true, nil
+		}
+		elem := *f.Elem
+		elem.Name = f.Name
+		ctype, dims, comment, ok, err := h.plain(elem)
+		return ctype, "[" + strconv.Itoa(f.Length) + "]" + dims, comment, ok, err
+	}
+	return "", "", "", false, nil
+}
+
+//number returns the members of the integer or bool f.
+func (h *header) number(id string, f binary.FieldSchema, pos *int) ([]string, error) {
+	if f.BitsFrom != "" {
+		return nil, fmt.Errorf("bits from the field %v can not be declared", f.BitsFrom)
+	}
+	width := *f.Bits
+	if width == 0 {
+		return nil, nil
+	}
+
+	if *pos%8 == 0 {
+		if ctype, _, comment, ok, _ := h.plain(f); ok {
+			*pos += width
+			return []string{fmt.Sprintf("%v %v;%v", ctype, id, comment)}, nil
+		}
+	}
+	*pos += width
+
+	ctype, _ := numberType(f.Kind)
+	if f.Endian != "big" || width <= 8 {
+		return []string{fmt.Sprintf("%v %v : %v;%v", ctype, id, width, flagsComment(f))}, nil
+	}
+
+	part := func(i int) string { return fmt.Sprintf("%v_%v", id, i) }
+	parts, value := bitfields.BigEndian(width, part)
+	members := make([]string, 0, len(parts))
+	for i, n := range parts {
+		members = append(members, fmt.Sprintf("uint8_t %v : %v;", part(i), n))
+	}
+	signed := ""
+	if strings.HasPrefix(f.Kind, "int") {
+		signed = fmt.Sprintf(", signed %v bits", width)
+	}
+	members[0] += fmt.Sprintf(" /* big endian %v is %v%v */", id, value, signed)
+	return members, nil
+}
+
+//numberType returns the C type of an integer or bool kind and its width.
+func numberType(kind string) (string, int) {
+	if kind == "bool" {
+		return "uint8_t", 8
+	}
+	return kind + "_t", bitfields.Natural(kind)
+}
+
+func flagsComment(f binary.FieldSchema) string {
+	if len(f.Flags) == 0 {
+		return ""
+	}
+	flags := make([]string, 0, len(f.Flags))
+	for _, flag := range f.Flags {
+		flags = append(flags, fmt.Sprintf("%v (bit %v)", flag.Name, flag.Bit))
+	}
+	return " /* flags: " + strings.Join(flags, ", ") + " */"
+}
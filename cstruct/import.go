@@ -0,0 +1,493 @@
+package cstruct
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nathanhack/binary/internal/names"
+)
+
+//Import returns the Go source of tagged structs with the layout of the C structs defined in src, all in package pkg.
+// The structs are taken to be packed and the numbers little endian, members marked `/* big endian */`, the way
+// Header marks them, are big endian.
+//
+// Only struct definitions, typedefs of them and preprocessor lines are allowed in src. Members can be fixed width
+// integers and the standard integer types but long, bool, float, double, structs defined before and arrays of these.
+// Bit fields become `bits` tags and a flexible array member becomes a slice taking the rest of the data.
+func Import(src []byte, pkg string) ([]byte, error) {
+	tokens, err := tokenize(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens, structs: map[string]string{}, aliases: map[string]string{}}
+	for !p.done() {
+		if err := p.declaration(); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.out) == 0 {
+		return nil, fmt.Errorf("no struct definitions found")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by cstruct.Import. DO NOT EDIT.\n\npackage %v\n", pkg)
+	for _, def := range p.out {
+		out.WriteString(def)
+	}
+	return format.Source(out.Bytes())
+}
+
+type token struct {
+	text string
+	//comment is the comment following the token on the same line
+	comment string
+	line    int
+}
+
+//tokenize splits src into identifiers, numbers, strings and punctuation, leaving out preprocessor lines. Comments are
+// kept with the token before them.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			//preprocessor lines, with their continuations
+			for i < len(src) && (src[i] != '\n' || src[i-1] == '\\') {
+				if src[i] == '\n' {
+					line++
+				}
+				i++
+			}
+		case strings.HasPrefix(src[i:], "//"), strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i:], "\n")
+			text := ""
+			if c == '/' && src[i+1] == '/' {
+				if end < 0 {
+					end = len(src) - i
+				}
+				text = src[i+2 : i+end]
+			} else {
+				end = strings.Index(src[i+2:], "*/")
+				if end < 0 {
+					return nil, fmt.Errorf("line %v: unterminated comment", line)
+				}
+				text = src[i+2 : i+2+end]
+				end += 4
+			}
+			if n := len(tokens); n > 0 && tokens[n-1].line == line {
+				tokens[n-1].comment += strings.TrimSpace(text)
+			}
+			line += strings.Count(src[i:i+end], "\n")
+			i += end
+		case c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{text: src[i:j], line: line})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' && src[j] != '\n' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) || src[j] != '"' {
+				return nil, fmt.Errorf("line %v: unterminated string", line)
+			}
+			tokens = append(tokens, token{text: src[i : j+1], line: line})
+			i = j + 1
+		case strings.ContainsRune("{}[];:,()*=", rune(c)):
+			tokens = append(tokens, token{text: string(c), line: line})
+			i++
+		default:
+			return nil, fmt.Errorf("line %v: unexpected %q", line, c)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	//structs are the Go names of the structs by C tag, aliases the Go types of the typedef names
+	structs map[string]string
+	aliases map[string]string
+	out     []string
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *parser) next() token {
+	if p.done() {
+		return token{}
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.next(); t.text != text {
+		return p.errorf(t, "expected %q but found %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	if t.text == "" {
+		return fmt.Errorf("unexpected end of input: "+format, args...)
+	}
+	return fmt.Errorf("line %v: "+format, append([]interface{}{t.line}, args...)...)
+}
+
+//skipAttributes skips any `__attribute__((...))`.
+func (p *parser) skipAttributes() error {
+	for p.peek() == "__attribute__" {
+		p.next()
+		depth := 0
+		for {
+			t := p.next()
+			switch t.text {
+			case "":
+				return p.errorf(t, "unterminated __attribute__")
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+//declaration parses a struct definition, a typedef of one or a static assertion.
+func (p *parser) declaration() error {
+	t := p.next()
+	switch t.text {
+	case ";":
+		return nil
+	case "_Static_assert", "static_assert":
+		for !p.done() && p.peek() != ";" {
+			p.next()
+		}
+		return p.expect(";")
+	case "typedef":
+		if p.peek() != "struct" {
+			return p.errorf(p.tokens[p.pos-1], "only typedefs of structs are supported")
+		}
+		p.next()
+		goName, err := p.structDef()
+		if err != nil {
+			return err
+		}
+		for {
+			if err := p.skipAttributes(); err != nil {
+				return err
+			}
+			alias := p.next()
+			if !isIdentifier(alias.text) {
+				return p.errorf(alias, "expected a typedef name but found %q", alias.text)
+			}
+			p.aliases[alias.text] = goName
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		return p.expect(";")
+	case "struct":
+		if _, err := p.structDef(); err != nil {
+			return err
+		}
+		if err := p.skipAttributes(); err != nil {
+			return err
+		}
+		return p.expect(";")
+	}
+	return p.errorf(t, "only struct definitions are supported, found %q", t.text)
+}
+
+//structDef parses a struct definition following the struct keyword, returning the Go name of the struct.
+func (p *parser) structDef() (string, error) {
+	if err := p.skipAttributes(); err != nil {
+		return "", err
+	}
+	tag := ""
+	if isIdentifier(p.peek()) {
+		tag = p.next().text
+	}
+	if err := p.skipAttributes(); err != nil {
+		return "", err
+	}
+	open := p.next()
+	if open.text != "{" {
+		return "", p.errorf(open, "expected a struct definition but found %q", open.text)
+	}
+
+	var fields []string
+	var l layout
+	for p.peek() != "}" {
+		if p.done() {
+			return "", p.errorf(token{}, "unterminated struct")
+		}
+		members, err := p.members(&l)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, members...)
+	}
+	p.next()
+	//C structs end on a byte boundary
+	fields = append(fields, l.pad()...)
+
+	goName := names.Camel(tag)
+	if tag == "" {
+		//anonymous structs are named after their typedef
+		if p.done() {
+			return "", p.errorf(token{}, "anonymous struct without a typedef")
+		}
+		pos := p.pos
+		if err := p.skipAttributes(); err != nil {
+			return "", err
+		}
+		if !isIdentifier(p.peek()) {
+			return "", p.errorf(p.next(), "anonymous struct without a typedef")
+		}
+		goName = names.Camel(strings.TrimSuffix(p.peek(), "_t"))
+		p.pos = pos
+	} else {
+		if _, has := p.structs[tag]; has {
+			return "", p.errorf(open, "struct %v is defined more than once", tag)
+		}
+		p.structs[tag] = goName
+	}
+
+	var def strings.Builder
+	fmt.Fprintf(&def, "\ntype %v struct {\n", goName)
+	for _, f := range fields {
+		fmt.Fprintf(&def, "\t%v\n", f)
+	}
+	def.WriteString("}\n")
+	p.out = append(p.out, def.String())
+	return goName, nil
+}
+
+//layout follows the bits of a struct as its members are parsed.
+type layout struct {
+	//pos is the bit position within the current byte
+	pos int
+	//reserved counts the fields added for unnamed bit fields and padding
+	reserved int
+}
+
+func (l *layout) reserve() string {
+	name := fmt.Sprintf("Reserved%v", l.reserved)
+	l.reserved++
+	return name
+}
+
+//pad returns the field filling the rest of the current byte, if any.
+func (l *layout) pad() []string {
+	if l.pos == 0 {
+		return nil
+	}
+	field := fmt.Sprintf("%v uint8 `bits:\"%v\"`", l.reserve(), 8-l.pos)
+	l.pos = 0
+	return []string{field}
+}
+
+//members parses a member declaration, returning the Go fields it declares.
+func (p *parser) members(l *layout) ([]string, error) {
+	start := p.tokens[p.pos]
+	goType, natural, err := p.memberType()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for {
+		name := ""
+		if isIdentifier(p.peek()) {
+			name = names.Camel(p.next().text)
+		}
+
+		var dims []string
+		flexible := false
+		for p.peek() == "[" {
+			open := p.next()
+			if p.peek() == "]" {
+				if len(dims) > 0 {
+					return nil, p.errorf(open, "only the first dimension can be empty")
+				}
+				flexible = true
+				p.next()
+				continue
+			}
+			n := p.next()
+			if _, err := strconv.ParseUint(n.text, 0, 31); err != nil {
+				return nil, p.errorf(n, "array sizes must be numbers, found %q", n.text)
+			}
+			size, _ := strconv.ParseUint(n.text, 0, 31)
+			dims = append(dims, strconv.FormatUint(size, 10))
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+
+		var tags []string
+		if p.peek() == ":" {
+			colon := p.next()
+			n := p.next()
+			width, err := strconv.ParseUint(n.text, 0, 7)
+			if err != nil {
+				return nil, p.errorf(n, "bit field widths must be numbers, found %q", n.text)
+			}
+			switch {
+			case natural == 0:
+				return nil, p.errorf(colon, "%v can not be a bit field", goType)
+			case len(dims) > 0 || flexible:
+				return nil, p.errorf(colon, "arrays can not be bit fields")
+			case width == 0:
+				return nil, p.errorf(n, "zero width bit fields are not supported")
+			case int(width) > natural:
+				return nil, p.errorf(n, "%v bits do not fit in %v", width, goType)
+			}
+			if goType == "bool" && width > 1 {
+				return nil, p.errorf(n, "bool bit fields are 1 bit")
+			}
+			tags = append(tags, fmt.Sprintf(`bits:"%v"`, width))
+			if name == "" {
+				name = l.reserve()
+			}
+			l.pos = (l.pos + int(width)) % 8
+		} else {
+			//members that are not bit fields start on a byte boundary
+			fields = append(fields, l.pad()...)
+		}
+		if name == "" {
+			return nil, p.errorf(start, "members must be named")
+		}
+
+		sep := p.next()
+		if strings.Contains(sep.comment, "big endian") && natural > 8 {
+			tags = append([]string{`endian:"big"`}, tags...)
+		}
+
+		field := name + " "
+		if flexible {
+			field += "[]"
+		}
+		for _, d := range dims {
+			field += "[" + d + "]"
+		}
+		field += goType
+		if len(tags) > 0 {
+			field += " `" + strings.Join(tags, " ") + "`"
+		}
+		fields = append(fields, field)
+
+		switch sep.text {
+		case ";":
+			return fields, nil
+		case ",":
+		default:
+			return nil, p.errorf(sep, "expected \";\" but found %q", sep.text)
+		}
+	}
+}
+
+var cTypes = map[string]struct {
+	goType  string
+	natural int
+}{
+	"uint8_t": {"uint8", 8}, "uint16_t": {"uint16", 16}, "uint32_t": {"uint32", 32}, "uint64_t": {"uint64", 64},
+	"int8_t": {"int8", 8}, "int16_t": {"int16", 16}, "int32_t": {"int32", 32}, "int64_t": {"int64", 64},
+	"char": {"byte", 8}, "signed char": {"int8", 8}, "unsigned char": {"byte", 8},
+	"short": {"int16", 16}, "signed short": {"int16", 16}, "unsigned short": {"uint16", 16},
+	"int": {"int32", 32}, "signed int": {"int32", 32}, "unsigned int": {"uint32", 32},
+	"signed": {"int32", 32}, "unsigned": {"uint32", 32},
+	"long long": {"int64", 64}, "signed long long": {"int64", 64}, "unsigned long long": {"uint64", 64},
+	"bool": {"bool", 8}, "_Bool": {"bool", 8},
+	"float": {"float32", 0}, "double": {"float64", 0},
+}
+
+//memberType parses the type of a member, returning the Go type and the width of the integers and bools allowed to be
+// bit fields, 0 for others.
+func (p *parser) memberType() (string, int, error) {
+	for p.peek() == "const" || p.peek() == "volatile" {
+		p.next()
+	}
+
+	first := p.next()
+	if first.text == "struct" {
+		tag := p.next()
+		goName, has := p.structs[tag.text]
+		if !has {
+			return "", 0, p.errorf(tag, "struct %v is not defined before", tag.text)
+		}
+		return goName, 0, nil
+	}
+	if goName, has := p.aliases[first.text]; has {
+		return goName, 0, nil
+	}
+
+	words := []string{first.text}
+	for {
+		next := p.peek()
+		if next != "signed" && next != "unsigned" && next != "char" && next != "short" && next != "int" &&
+			next != "long" {
+			break
+		}
+		words = append(words, p.next().text)
+	}
+	//int is implied after short and long
+	name := strings.Join(words, " ")
+	name = strings.Replace(name, "short int", "short", 1)
+	name = strings.Replace(name, "long long int", "long long", 1)
+	if t, has := cTypes[name]; has {
+		return t.goType, t.natural, nil
+	}
+	if strings.Contains(name, "long") {
+		return "", 0, p.errorf(first, "%v has a different size on different machines, use a fixed width type", name)
+	}
+	return "", 0, p.errorf(first, "type %v is not supported", name)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || keywords[s] {
+		return false
+	}
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/nathanhack/binary/internal/tagrules"
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//Defaults sets what the fields of every struct default to when neither their tags nor the struct set it. It is passed
+// in with the other options, e.g. Encode(msg, &Defaults{Endian: "big"}), and must be given to Decode the same.
+//
+// A struct sets defaults for its own fields with a `binary` tag on a blank struct{} field, e.g.
+//
+//	type Header struct {
+//		_       struct{} `binary:"endian=big"`
+//		Version uint8    `bits:"4"`
+//		Length  uint16
+//		Small   uint16 `endian:"little"`
+//	}
+//
+// The fields of nested structs, and of the structs within arrays, slices and pointers, inherit the defaults of the
+// struct holding them unless their struct sets its own. Only endian and bitorder can be set.
+type Defaults struct {
+	//Endian is `little` or `big`. When empty numbers are little endian.
+	Endian string
+	//BitOrder is `lsb` or `msb`. When empty bits are packed from the least significant bit of each byte, with `msb`
+	// they are packed from the most significant bit and numbers are written from their most significant bit, so
+	// Version uint8 `bits:"4"` followed by IHL uint8 `bits:"4"` encode 4 and 5 as 0x45. A struct with a bit order
+	// different from the struct holding it must start and end on a byte boundary.
+	BitOrder string
+}
+
+func (d *Defaults) Type() reflect.Type {
+	return nil
+}
+
+func (d *Defaults) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (d *Defaults) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (d *Defaults) setting() {}
+
+//DefaultEndian returns Defaults making numbers without an endian tag big or little endian.
+func DefaultEndian(endian string) *Defaults {
+	return &Defaults{Endian: endian}
+}
+
+//defaultEndian returns the endianness of the last Defaults with one.
+func defaultEndian(options []EncDecOption) string {
+	endian := ""
+	eachSetting(options, func(item EncDecOption) {
+		if d, ok := item.(*Defaults); ok && d != nil && d.Endian != "" {
+			endian = d.Endian
+		}
+	})
+	return endian
+}
+
+//defaultBitOrder returns the bit order of the last Defaults with one.
+func defaultBitOrder(options []EncDecOption) string {
+	order := ""
+	eachSetting(options, func(item EncDecOption) {
+		if d, ok := item.(*Defaults); ok && d != nil && d.BitOrder != "" {
+			order = d.BitOrder
+		}
+	})
+	return order
+}
+
+//structInfo is what a struct type sets for its fields.
+type structInfo struct {
+	//defaults are the settings of the `binary` tag of the struct, nil when it has none
+	defaults *Defaults
+	//fields are the fields encoded, leaving out omitted fields and the struct{} field holding the `binary` tag. The
+	// settings of the `binary` tags of the fields are added to their tags as the separate tags.
+	fields []reflect.StructField
+	err    error
+}
+
+//infoCache holds the *structInfo of each struct type.
+var infoCache sync.Map
+
+//getStructInfo returns the structInfo of the struct type t.
+func getStructInfo(t reflect.Type) *structInfo {
+	if info, has := infoCache.Load(t); has {
+		return info.(*structInfo)
+	}
+
+	info := &structInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		s, has := sf.Tag.Lookup("binary")
+		if has && marksDefaults(sf) {
+			if info.defaults != nil {
+				info.err = fmt.Errorf("%v has more than one binary tag on a blank struct{} field", t)
+				break
+			}
+			info.defaults = &Defaults{}
+			if problems := info.defaults.parse(s); len(problems) > 0 {
+				info.err = fmt.Errorf("%v: %v", t, problems[0])
+				break
+			}
+			continue
+		}
+
+		tag, problems := tagrules.Expand(sf.Tag)
+		if len(problems) > 0 {
+			info.err = fmt.Errorf("%v: %v", sf.Name, problems[0])
+			break
+		}
+		sf.Tag = tag
+		if _, has := sf.Tag.Lookup("omit"); has {
+			continue
+		}
+		info.fields = append(info.fields, sf)
+	}
+
+	actual, _ := infoCache.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
+
+//marksDefaults reports if sf is the blank struct{} field whose `binary` tag sets the defaults of its struct.
+func marksDefaults(sf reflect.StructField) bool {
+	return sf.Name == "_" && sf.Type.Kind() == reflect.Struct && sf.Type.NumField() == 0
+}
+
+//parse sets d to the settings of the `binary` tag s, returning the problems found.
+func (d *Defaults) parse(s string) []string {
+	endian, bitOrder, problems := tagrules.ParseDefaults(s)
+	d.Endian = endian
+	d.BitOrder = bitOrder
+	return problems
+}
+
+//fieldsOf returns the fields of the struct t that are encoded, along with the options to encode them with. When the
+// struct has a `binary` tag on a blank struct{} field its settings are added to the options, so they reach the fields
+// of nested structs.
+func fieldsOf(t reflect.Type, options []EncDecOption) ([]reflect.StructField, []EncDecOption, error) {
+	info := getStructInfo(t)
+	if info.err != nil {
+		return nil, nil, info.err
+	}
+	if d := info.defaults; d != nil && ((d.Endian != "" && d.Endian != defaultEndian(options)) ||
+		(d.BitOrder != "" && d.BitOrder != defaultBitOrder(options))) {
+		options = append(options[:len(options):len(options)], d)
+	}
+	return info.fields, options, nil
+}
@@ -0,0 +1,339 @@
+package binary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bigPoint struct {
+	_ struct{} `binary:"endian=big"`
+	X int16
+	Y int16 `endian:"little"`
+}
+
+type littlePoint struct {
+	_ struct{} `binary:"endian=little,bitorder=lsb"`
+	X int16
+}
+
+type bigMessage struct {
+	_       struct{} `binary:"endian=big"`
+	Version uint8    `bits:"4"`
+	Length  uint16   `bits:"12"`
+	ID      uint32
+	Small   uint16 `endian:"little"`
+	Inner   struct {
+		A uint16
+		B float32
+	}
+	Items  []struct{ V uint16 } `size:"2"`
+	Ptr    *struct{ V uint16 }
+	Little littlePoint
+	Point  bigPoint
+}
+
+type taggedMessage struct {
+	Version uint8  `bits:"4"`
+	Length  uint16 `bits:"12" endian:"big"`
+	ID      uint32 `endian:"big"`
+	Small   uint16 `endian:"little"`
+	Inner   struct {
+		A uint16  `endian:"big"`
+		B float32 `endian:"big"`
+	}
+	Items  []struct {
+		V uint16 `endian:"big"`
+	} `size:"2"`
+	Ptr *struct {
+		V uint16 `endian:"big"`
+	}
+	Little struct{ X int16 }
+	Point  struct {
+		X int16 `endian:"big"`
+		Y int16 `endian:"little"`
+	}
+}
+
+func TestStructDefaults(t *testing.T) {
+	m := bigMessage{Version: 4, Length: 0x123, ID: 0x01020304, Small: 0x0506}
+	m.Inner.A, m.Inner.B = 0x0708, 1.5
+	m.Items = []struct{ V uint16 }{{0x090a}, {0x0b0c}}
+	m.Ptr = &struct{ V uint16 }{0x0d0e}
+	m.Little.X = 0x0f10
+	m.Point.X, m.Point.Y = 0x1112, 0x1314
+
+	var tagged taggedMessage
+	tagged.Version, tagged.Length, tagged.ID, tagged.Small = m.Version, m.Length, m.ID, m.Small
+	tagged.Inner.A, tagged.Inner.B = m.Inner.A, m.Inner.B
+	tagged.Items = make([]struct {
+		V uint16 `endian:"big"`
+	}, len(m.Items))
+	for i, item := range m.Items {
+		tagged.Items[i].V = item.V
+	}
+	tagged.Ptr = &struct {
+		V uint16 `endian:"big"`
+	}{m.Ptr.V}
+	tagged.Little.X = m.Little.X
+	tagged.Point.X, tagged.Point.Y = m.Point.X, m.Point.Y
+
+	expected, err := Encode(&tagged)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	bs, err := Encode(&m)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual bigMessage
+	if err := Decode(bs, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(m, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", m, actual)
+	}
+	if n, fixed := StaticBitSize(reflect.TypeOf(bigPoint{})); !fixed || n != 32 {
+		t.Fatalf("expected 32 bits but found %v", n)
+	}
+
+	//skipping reads Length with the defaults too
+	type sized struct {
+		_    struct{} `binary:"endian=big"`
+		N    uint16
+		Data []byte `size:"N"`
+		Tail uint8
+	}
+	input := sized{N: 3, Data: []byte{1, 2, 3}, Tail: 9}
+	bs, err = Encode(&input)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	var partial sized
+	if err := Decode(bs, &partial, OnlyFields("Tail")); err != nil || partial.Tail != 9 {
+		t.Fatalf("expected the tail after skipping but found %v %v", partial.Tail, err)
+	}
+}
+
+func TestDefaultsOption(t *testing.T) {
+	type plain struct {
+		A uint16
+		B uint16 `endian:"little"`
+		C struct{ D uint32 }
+	}
+	type tagged struct {
+		A uint16 `endian:"big"`
+		B uint16 `endian:"little"`
+		C struct {
+			D uint32 `endian:"big"`
+		}
+	}
+
+	input := plain{A: 1, B: 2}
+	input.C.D = 3
+	bs, err := Encode(&input, DefaultEndian("big"))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	var expectedInput tagged
+	expectedInput.A, expectedInput.B, expectedInput.C.D = 1, 2, 3
+	expected, err := Encode(&expectedInput)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual plain
+	if err := Decode(bs, &actual, &Defaults{Endian: "big"}); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", input, actual)
+	}
+
+	//a struct setting its own defaults is not changed by the option
+	point := bigPoint{X: 1, Y: 2}
+	bs, err = Encode(&point, DefaultEndian("little"))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(bs, []byte{0, 1, 2, 0}) {
+		t.Fatalf("expected the struct defaults but found %x", bs)
+	}
+
+	if _, err := Encode(&input, DefaultEndian("middle")); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+type msbHeader struct {
+	_       struct{} `binary:"endian=big,bitorder=msb"`
+	Version uint8    `bits:"4"`
+	IHL     uint8    `bits:"4"`
+	DSCP    uint8    `bits:"6"`
+	ECN     uint8    `bits:"2"`
+	Length  uint16
+	Flags   uint8  `bits:"3"`
+	Offset  uint16 `bits:"13"`
+	Delta   int8   `bits:"4"`
+	Name    string `strlen:"1"`
+	Last    uint8  `bits:"4"`
+	Small   uint16 `endian:"little"`
+	Ratio   float32
+}
+
+func TestStructDefaultsMSB(t *testing.T) {
+	input := msbHeader{Version: 4, IHL: 5, DSCP: 46, ECN: 1, Length: 0x54, Flags: 1, Offset: 0x1abc, Delta: -3,
+		Name: "a", Last: 2, Small: 0x0102, Ratio: 1.5}
+	bs, err := Encode(&input)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	//Delta is 1101 followed by the 01100001 of "a" and the 0010 of Last
+	expected := []byte{0x45, 0xb9, 0x00, 0x54, 0x3a, 0xbc, 0xd6, 0x12, 0x02, 0x01, 0x3f, 0xc0, 0x00, 0x00}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual msbHeader
+	if err := Decode(bs, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", input, actual)
+	}
+
+	//the option gives the same bit order to structs without defaults, which switch on byte boundaries
+	type inner struct {
+		_  struct{} `binary:"bitorder=lsb"`
+		Lo uint8    `bits:"4"`
+		Hi uint8    `bits:"4"`
+	}
+	type plain struct {
+		A     uint8 `bits:"3"`
+		B     uint8 `bits:"5"`
+		C     uint16
+		Inner inner
+		Items []inner `size:"2"`
+	}
+	p := plain{A: 5, B: 3, C: 0x1234, Inner: inner{Lo: 1, Hi: 2}, Items: []inner{{Lo: 3, Hi: 4}, {Lo: 5, Hi: 6}}}
+	bs, err = Encode(&p, &Defaults{BitOrder: "msb"})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expected = []byte{0xa3, 0x34, 0x12, 0x21, 0x43, 0x65}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+	var actualPlain plain
+	if err := Decode(bs, &actualPlain, &Defaults{BitOrder: "msb"}); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(p, actualPlain) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", p, actualPlain)
+	}
+
+	if _, err := Encode(&p, &Defaults{BitOrder: "middle"}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestStructDefaultsMSBBoundaries(t *testing.T) {
+	type half struct {
+		_ struct{} `binary:"bitorder=msb"`
+		A uint8    `bits:"4"`
+	}
+	type unaligned struct {
+		A uint8 `bits:"4"`
+		B struct {
+			_ struct{} `binary:"bitorder=msb"`
+			C uint8
+		}
+		D uint8 `bits:"4"`
+	}
+	tests := []interface{}{&half{}, &unaligned{}}
+	for _, test := range tests {
+		if _, err := Encode(test); err == nil || !strings.Contains(err.Error(), "byte boundary") {
+			t.Fatalf("%T: expected the byte boundary error but found %v", test, err)
+		}
+		if err := Decode([]byte{1, 2, 3}, test); err == nil || !strings.Contains(err.Error(), "byte boundary") {
+			t.Fatalf("%T: expected the byte boundary error but found %v", test, err)
+		}
+	}
+
+	if _, err := Describe(reflect.TypeOf(msbHeader{})); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestStructDefaultsDescribe(t *testing.T) {
+	schema, err := Describe(reflect.TypeOf(bigMessage{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if schema.Fields[0].Name != "Version" {
+		t.Fatalf("expected the blank field to be left out but found %v", schema.Fields[0].Name)
+	}
+	length, small, inner, little := schema.Fields[1], schema.Fields[3], schema.Fields[4], schema.Fields[7]
+	if length.Endian != "big" || small.Endian != "little" || inner.Fields[0].Endian != "big" || little.Fields[0].Endian != "little" {
+		t.Fatalf("expected the endianness of the defaults but found %#v", schema.Fields)
+	}
+}
+
+func TestStructDefaultsErrors(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			_ struct{} `binary:"endian=middle"`
+			A uint16
+		}{},
+		&struct {
+			_ struct{} `binary:"bitorder=middle"`
+			A uint16
+		}{},
+		&struct {
+			_ struct{} `binary:"order=big"`
+			A uint16
+		}{},
+		&struct {
+			_ struct{} `binary:"big"`
+			A uint16
+		}{},
+		&struct {
+			_ struct{} `binary:"endian=big,endian=little"`
+			A uint16
+		}{},
+		&struct {
+			_ struct{} `binary:"endian=big"`
+			_ struct{} `binary:"endian=little"`
+			A uint16
+		}{},
+		&struct {
+			Inner struct {
+				_ struct{} `binary:"endian=big,"`
+				_ struct{} `binary:"endian=big"`
+			}
+		}{},
+	}
+	for _, test := range tests {
+		if _, err := Encode(test); err == nil {
+			t.Fatalf("%T: expected an encoding error", test)
+		}
+		if err := Decode([]byte{1, 2, 3, 4}, test); err == nil {
+			t.Fatalf("%T: expected a decoding error", test)
+		}
+		if _, err := Describe(reflect.TypeOf(test)); err == nil {
+			t.Fatalf("%T: expected a describe error", test)
+		}
+	}
+
+	_, err := Encode(tests[1])
+	if err == nil || !strings.Contains(err.Error(), "bitorder") {
+		t.Fatalf("expected the bitorder error but found %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//maxDumpBytes is the most raw bytes shown for a field, longer fields are cut short.
+const maxDumpBytes = 16
+
+//Dump encodes v and returns an annotated listing of the encoded bits, see DumpBytes.
+func Dump(v interface{}, options ...EncDecOption) (string, error) {
+	if v == nil {
+		return "", fmt.Errorf("nil parameters not allowed")
+	}
+	buf, err := EncodeToBits(v, options...)
+	if err != nil {
+		return "", err
+	}
+	buf.ResetToStart()
+
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return dumpBits(buf, reflect.New(t).Interface(), 0, options...)
+}
+
+//DumpBytes decodes data into value, the same as Decode, and returns an annotated listing of it. Each decoded value
+// gets a line with its path, its bits as a `start:end` range, the raw bits and the decoded value. Raw bits are shown
+// as hex when they are whole bytes and otherwise as binary in the order they are read.
+//
+// When decoding fails the listing goes up to the value that failed, along with the error.
+func DumpBytes(data []byte, value interface{}, options ...EncDecOption) (string, error) {
+	if data == nil || value == nil {
+		return "", fmt.Errorf("nil parameters not allowed")
+	}
+	buf, err := bits.NewFromBytes(data)
+	if err != nil {
+		return "", err
+	}
+	return dumpBits(buf, value, 7, options...)
+}
+
+//dumpBits decodes buf into value, the same as decodeToBits, and returns the listing of it.
+func dumpBits(buf *bits.BitSetBuffer, value interface{}, padding int, options ...EncDecOption) (string, error) {
+	options, state, _ := withDecodeState(options)
+	if state == nil {
+		state = &decodeState{}
+		options = append(options[:len(options):len(options)], state)
+	}
+	d := &dumper{frames: []dumpFrame{{entry: -1}}}
+	state.dump = d
+	defer func() { state.dump = nil }()
+
+	_, err := decodeToBits(buf, value, padding, options...)
+	return d.listing(buf.Set, err), err
+}
+
+//dumper records the values decoded while the decodeState holding it is in use.
+type dumper struct {
+	entries []dumpEntry
+	frames  []dumpFrame
+}
+
+type dumpEntry struct {
+	path       string
+	start, end int
+	value      string
+	//leaf is false for structs, arrays and slices, which have their values listed after them
+	leaf bool
+	err  error
+}
+
+type dumpFrame struct {
+	name  string
+	path  string
+	start int
+	//entry is the index of the entry of the frame, -1 for frames that have none
+	entry int
+	//items counts the items decoded within an array or slice
+	items int
+	//through is true for pointers and interfaces, which are listed as the value they hold. held is set once that
+	// value is decoded.
+	through bool
+	held    bool
+	//quiet is true within byte arrays and slices, which are listed as one value
+	quiet bool
+	//custom is true for values decoded by an option, which are listed as one value
+	custom bool
+}
+
+//enter is called as DecodeField starts on a value of type t at the bit position start.
+func (d *dumper) enter(fieldName string, t reflect.Type, start int) {
+	parent := &d.frames[len(d.frames)-1]
+	if parent.quiet {
+		d.frames = append(d.frames, dumpFrame{entry: -1, quiet: true})
+		return
+	}
+
+	var path string
+	switch {
+	case parent.through && (fieldName == "" || fieldName == parent.name):
+		path = parent.path
+		parent.held = true
+	case fieldName == "":
+		path = fmt.Sprintf("%v[%v]", parent.path, parent.items)
+		parent.items++
+	default:
+		path = joinPath(parent.path, fieldName)
+	}
+
+	frame := dumpFrame{name: fieldName, path: path, start: start, entry: -1}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		frame.through = true
+	default:
+		frame.entry = len(d.entries)
+		d.entries = append(d.entries, dumpEntry{path: path, start: start, end: -1})
+		frame.quiet = (t.Kind() == reflect.Array || t.Kind() == reflect.Slice) && t.Elem().Kind() == reflect.Uint8
+	}
+	d.frames = append(d.frames, frame)
+}
+
+//custom is called as DecodeField hands the value it started on to an option.
+func (d *dumper) custom() {
+	frame := &d.frames[len(d.frames)-1]
+	frame.quiet = true
+	frame.custom = true
+}
+
+//leave is called as DecodeField is done with the value v, with the bit position end and the error it returns.
+func (d *dumper) leave(v reflect.Value, end int, err error) {
+	frame := d.frames[len(d.frames)-1]
+	d.frames = d.frames[:len(d.frames)-1]
+	if frame.through && !frame.held {
+		//the value was decoded without going through DecodeField, e.g. by an InterfaceEncDec
+		frame.entry = len(d.entries)
+		d.entries = append(d.entries, dumpEntry{path: frame.path, start: frame.start})
+	}
+	if frame.entry < 0 {
+		return
+	}
+
+	e := &d.entries[frame.entry]
+	e.end = end
+	if err != nil && err != errStopDecoding {
+		e.err = err
+		return
+	}
+
+	if frame.custom {
+		e.value = formatValue(v)
+		e.leaf = true
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		e.value = v.Type().String()
+		if hasCustomDecoding(v) {
+			e.value = fmt.Sprintf("%v", v.Interface())
+			e.leaf = true
+		}
+	case reflect.Array, reflect.Slice:
+		if frame.quiet {
+			e.value = fmt.Sprintf("%x", v.Interface())
+			e.leaf = true
+		} else {
+			e.value = fmt.Sprintf("%v items", v.Len())
+		}
+	default:
+		e.value = formatValue(v)
+		e.leaf = true
+	}
+}
+
+//hasCustomDecoding reports if the struct v is decoded by a BitsUnmarshaler, its fields are not listed.
+func hasCustomDecoding(v reflect.Value) bool {
+	modelType := reflect.TypeOf((*BitsUnmarshaler)(nil)).Elem()
+	return v.CanInterface() && (v.Type().Implements(modelType) || reflect.PtrTo(v.Type()).Implements(modelType))
+}
+
+//formatValue returns v as it is shown in a dump.
+func formatValue(v reflect.Value) string {
+	if !v.CanInterface() {
+		return ""
+	}
+	if names, has := LookupFlags(v.Type()); has {
+		switch v.Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return fmt.Sprintf("%v (%v)", v.Uint(), names.Format(v.Uint()))
+		}
+	}
+	if names, has := LookupEnum(v.Type()); has {
+		if name, has := names.Name(v.Uint()); has {
+			return fmt.Sprintf("%v (%v)", v.Uint(), name)
+		}
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	if v.CanAddr() {
+		//types such as big.Int only have String on the pointer
+		if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+//listing returns the entries as a table, followed by the error when there is one.
+func (d *dumper) listing(set []bool, err error) string {
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BITS\tFIELD\tRAW\tVALUE")
+	for _, e := range d.entries {
+		switch {
+		case e.err != nil:
+			fmt.Fprintf(w, "%v:\t%v\t\terror\n", e.start, e.path)
+		case e.leaf:
+			fmt.Fprintf(w, "%v:%v\t%v\t%v\t%v\n", e.start, e.end, e.path, rawBits(set, e.start, e.end), e.value)
+		default:
+			fmt.Fprintf(w, "%v:%v\t%v\t\t%v\n", e.start, e.end, e.path, e.value)
+		}
+	}
+	w.Flush()
+	if err != nil {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+	return out.String()
+}
+
+//rawBits returns the bits of set from start up to end, as hex when they are whole bytes.
+func rawBits(set []bool, start, end int) string {
+	if start >= end {
+		return "-"
+	}
+	if start%8 == 0 && end%8 == 0 {
+		n := (end - start) / 8
+		more := ""
+		if n > maxDumpBytes {
+			n, more = maxDumpBytes, fmt.Sprintf(" ...(%v more)", n-maxDumpBytes)
+		}
+		bs := make([]byte, n)
+		for i := range bs {
+			for j := 0; j < 8; j++ {
+				if set[start+8*i+j] {
+					bs[i] |= 1 << j
+				}
+			}
+		}
+		return fmt.Sprintf("% x%v", bs, more)
+	}
+
+	var b strings.Builder
+	b.WriteString("0b")
+	for i := start; i < end && i < start+8*maxDumpBytes; i++ {
+		if set[i] {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	if end-start > 8*maxDumpBytes {
+		fmt.Fprintf(&b, " ...(%v more)", end-start-8*maxDumpBytes)
+	}
+	return b.String()
+}
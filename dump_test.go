@@ -0,0 +1,90 @@
+package binary
+
+import "testing"
+
+type dumpPoint struct {
+	X int8 `bits:"5"`
+	Y *uint16
+}
+
+type dumpMessage struct {
+	Version uint8   `bits:"4"`
+	Flags   ipFlags `bits:"3"`
+	N       uint8
+	Name    string      `strlen:"N"`
+	Points  []dumpPoint `size:"N"`
+	Raw     [3]byte
+	Tail    []uint16
+}
+
+const dumpListing = `BITS    FIELD        RAW                         VALUE
+0:4     Version      0b0010                      4
+4:7     Flags        0b010                       2 (DontFrag)
+7:15    N            0b01000000                  2
+15:31   Name         0b0001011010010110          "hi"
+31:73   Points                                   2 items
+31:52   Points[0]                                binary.dumpPoint
+31:36   Points[0].X  0b10111                     -3
+36:52   Points[0].Y  0b1110000000000000          7
+52:73   Points[1]                                binary.dumpPoint
+52:57   Points[1].X  0b10100                     5
+57:73   Points[1].Y  0b1110000000000000          7
+73:97   Raw          0b100000000100000011000000  010203
+97:113  Tail                                     1 items
+97:113  Tail[0]      0b1111011101111101          48879
+`
+
+func TestDump(t *testing.T) {
+	y := uint16(7)
+	m := dumpMessage{Version: 4, Flags: ipDontFrag, N: 2, Name: "hi", Points: []dumpPoint{{-3, &y}, {5, &y}},
+		Raw: [3]byte{1, 2, 3}, Tail: []uint16{0xbeef}}
+
+	listing, err := Dump(&m)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if listing != dumpListing {
+		t.Fatalf("expected:\n%v\nbut found:\n%v", dumpListing, listing)
+	}
+}
+
+func TestDumpBytes(t *testing.T) {
+	type header struct {
+		Kind   uint8
+		Length uint16 `endian:"big"`
+		Data   []byte `size:"Length"`
+	}
+
+	listing, err := DumpBytes([]byte{1, 0, 3, 0xaa, 0xbb, 0xcc}, &header{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expected := `BITS   FIELD   RAW       VALUE
+0:8    Kind    01        1
+8:24   Length  00 03     3
+24:48  Data    aa bb cc  aabbcc
+`
+	if listing != expected {
+		t.Fatalf("expected:\n%v\nbut found:\n%v", expected, listing)
+	}
+
+	listing, err = DumpBytes([]byte{1, 0, 3, 0xaa}, &header{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	expected = `BITS  FIELD   RAW    VALUE
+0:8   Kind    01     1
+8:24  Length  00 03  3
+24:   Data           error
+error: ` + err.Error() + "\n"
+	if listing != expected {
+		t.Fatalf("expected:\n%v\nbut found:\n%v", expected, listing)
+	}
+
+	if _, err := DumpBytes(nil, &header{}); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := Dump(nil); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,121 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+//EnumNames is the name table of an enum type, a named unsigned integer type (e.g. `type MsgType uint8`) whose values
+// have names. It is encoded and decoded like any other integer, values without a name are allowed.
+type EnumNames struct {
+	t      reflect.Type
+	names  map[uint64]string
+	values map[string]uint64
+}
+
+var (
+	enumsLock     sync.RWMutex
+	enumsRegistry = map[reflect.Type]*EnumNames{}
+)
+
+//RegisterEnum registers the value names of an enum type, given as a map from the values of the type to their names.
+// RegisterEnum is meant to be called during initialization and panics if names is not a map from an unsigned integer
+// type to strings or if a name is empty or repeated.
+//
+//	type MsgType uint8
+//
+//	const (
+//		Ping MsgType = 1
+//		Pong MsgType = 2
+//	)
+//
+//	var msgTypes = binary.RegisterEnum(map[MsgType]string{Ping: "Ping", Pong: "Pong"})
+//
+//	func (m MsgType) String() string { return msgTypes.Format(uint64(m)) }
+func RegisterEnum(names interface{}) *EnumNames {
+	m := reflect.ValueOf(names)
+	if m.Kind() != reflect.Map || m.Type().Elem().Kind() != reflect.String {
+		panic(fmt.Sprintf("binary: RegisterEnum with %T, it must be a map to strings", names))
+	}
+
+	t := m.Type().Key()
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic(fmt.Sprintf("binary: RegisterEnum type %v must be an unsigned integer", t))
+	}
+
+	e := &EnumNames{
+		t:      t,
+		names:  make(map[uint64]string, m.Len()),
+		values: make(map[string]uint64, m.Len()),
+	}
+	iter := m.MapRange()
+	for iter.Next() {
+		value, name := iter.Key().Uint(), iter.Value().String()
+		if name == "" {
+			panic(fmt.Sprintf("binary: RegisterEnum type %v has an empty name for %v", t, value))
+		}
+		if _, has := e.values[name]; has {
+			panic(fmt.Sprintf("binary: RegisterEnum type %v has the name %v more than once", t, name))
+		}
+		e.names[value] = name
+		e.values[name] = value
+	}
+
+	enumsLock.Lock()
+	defer enumsLock.Unlock()
+	enumsRegistry[t] = e
+	return e
+}
+
+//LookupEnum returns the EnumNames registered for t.
+func LookupEnum(t reflect.Type) (*EnumNames, bool) {
+	enumsLock.RLock()
+	defer enumsLock.RUnlock()
+	e, has := enumsRegistry[t]
+	return e, has
+}
+
+//Type returns the enum type the names belong to.
+func (e *EnumNames) Type() reflect.Type {
+	return e.t
+}
+
+//Name returns the name of value.
+func (e *EnumNames) Name(value uint64) (string, bool) {
+	name, has := e.names[value]
+	return name, has
+}
+
+//Value returns the value with the name.
+func (e *EnumNames) Value(name string) (uint64, bool) {
+	value, has := e.values[name]
+	return value, has
+}
+
+//Names returns the names ordered by value.
+func (e *EnumNames) Names() []string {
+	values := make([]uint64, 0, len(e.names))
+	for value := range e.names {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	names := make([]string, len(values))
+	for i, value := range values {
+		names[i] = e.names[value]
+	}
+	return names
+}
+
+//Format returns the name of value, or the number when it has none.
+func (e *EnumNames) Format(value uint64) string {
+	if name, has := e.names[value]; has {
+		return name
+	}
+	return strconv.FormatUint(value, 10)
+}
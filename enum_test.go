@@ -0,0 +1,74 @@
+package binary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type msgType uint8
+
+const (
+	msgPing msgType = 1
+	msgPong msgType = 2
+	msgData msgType = 7
+)
+
+var msgTypeNames = RegisterEnum(map[msgType]string{msgPing: "Ping", msgPong: "Pong", msgData: "Data"})
+
+func (m msgType) String() string { return msgTypeNames.Format(uint64(m)) }
+
+func TestEnum(t *testing.T) {
+	names, has := LookupEnum(reflect.TypeOf(msgPing))
+	if !has || names != msgTypeNames || names.Type() != reflect.TypeOf(msgPing) {
+		t.Fatalf("expected the registered names")
+	}
+	if name, has := names.Name(2); !has || name != "Pong" {
+		t.Fatalf("expected Pong but found %v", name)
+	}
+	if value, has := names.Value("Data"); !has || value != 7 {
+		t.Fatalf("expected 7 but found %v", value)
+	}
+	if _, has := names.Value("Nope"); has {
+		t.Fatalf("expected no value")
+	}
+	if !reflect.DeepEqual(names.Names(), []string{"Ping", "Pong", "Data"}) {
+		t.Fatalf("expected the names by value but found %v", names.Names())
+	}
+	if msgPong.String() != "Pong" || msgType(9).String() != "9" {
+		t.Fatalf("expected Pong and 9 but found %v and %v", msgPong, msgType(9))
+	}
+
+	type message struct {
+		Type msgType `bits:"4"`
+	}
+	listing, err := Dump(&message{Type: msgData})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !strings.Contains(listing, "7 (Data)") {
+		t.Fatalf("expected the enum name in:\n%v", listing)
+	}
+}
+
+func TestRegisterEnumPanics(t *testing.T) {
+	type code uint16
+	tests := []interface{}{
+		nil,
+		[]string{"A"},
+		map[int]string{1: "A"},
+		map[code]int{1: 1},
+		map[code]string{1: ""},
+		map[code]string{1: "A", 2: "A"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%v: expected a panic", test)
+				}
+			}()
+			RegisterEnum(test)
+		}()
+	}
+}
@@ -0,0 +1,249 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//FieldCodec encodes and decodes the values of one type, the same as the Encoder and Decoder of a TypeEncDec but
+// with everything they are given held by a context. Use CodecFor to pass it in as an option.
+//
+//	type label struct{}
+//
+//	func (label) Encode(ctx *binary.EncodeContext, v reflect.Value) error {
+//		if err := ctx.EncodeField("Len", reflect.ValueOf(uint8(v.Len())), ""); err != nil {
+//			return err
+//		}
+//		_, err := ctx.Writer().Write([]byte(v.String()))
+//		return err
+//	}
+//
+// Errors returned are reported as they are, the codec should add ctx.Path() to them where it helps.
+type FieldCodec interface {
+	Encode(ctx *EncodeContext, v reflect.Value) error
+	Decode(ctx *DecodeContext, v reflect.Value) error
+}
+
+//CodecFor returns the option encoding and decoding the values of type t with codec.
+func CodecFor(t reflect.Type, codec FieldCodec) *TypeEncDec {
+	if codec == nil {
+		//left without funcs so the option is rejected by Encode and Decode
+		return &TypeEncDec{ValueType: t}
+	}
+	return &TypeEncDec{
+		ValueType: t,
+		codec:     codec,
+		Encoder: func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+			ctx := &EncodeContext{fieldContext: newFieldContext(fieldName, tag, sizeMap, options), buf: buf}
+			return codec.Encode(ctx, v)
+		},
+		Decoder: func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+			ctx := &DecodeContext{fieldContext: newFieldContext(fieldName, tag, sizeMap, options), buf: buf}
+			return codec.Decode(ctx, v)
+		},
+	}
+}
+
+//fieldContext is what the EncodeContext and DecodeContext have in common.
+type fieldContext struct {
+	name    string
+	path    string
+	tag     reflect.StructTag
+	sizeMap map[string]int
+	options []EncDecOption
+}
+
+func newFieldContext(fieldName string, tag reflect.StructTag, sizeMap map[string]int, options []EncDecOption) fieldContext {
+	path := fieldName
+	if p := getFieldPath(options); p != nil {
+		path = p.current()
+	}
+	return fieldContext{name: fieldName, path: path, tag: tag, sizeMap: sizeMap, options: options}
+}
+
+//Name returns the name of the field holding the value.
+func (c *fieldContext) Name() string {
+	return c.name
+}
+
+//Path returns the path of the field from the top level struct, the names of the fields joined with `.` and the
+// indexes of items in brackets, e.g. `Items[1].Count` for the field Count encoded by a codec with
+// ctx.EncodeField("Count", ...) for the second item of the field Items.
+func (c *fieldContext) Path() string {
+	return c.path
+}
+
+//Tag returns the value of the tag key of the field, has is false when the field has no such tag.
+func (c *fieldContext) Tag(key string) (value string, has bool) {
+	return c.tag.Lookup(key)
+}
+
+//StructTag returns all the tags of the field.
+func (c *fieldContext) StructTag() reflect.StructTag {
+	return c.tag
+}
+
+//Lookup returns the value of the integer field with the given name found prior to the value, the same fields a
+// `size` tag may refer to.
+func (c *fieldContext) Lookup(name string) (value int, has bool) {
+	value, has = c.sizeMap[name]
+	return
+}
+
+//Options returns the options of the call, for use with functions such as EncodeField.
+func (c *fieldContext) Options() []EncDecOption {
+	return c.options
+}
+
+//EncodeContext is given to FieldCodec.Encode.
+type EncodeContext struct {
+	fieldContext
+	buf bits.BitSetWriter
+}
+
+//Writer returns the buffer the value is encoded into.
+func (c *EncodeContext) Writer() bits.BitSetWriter {
+	return c.buf
+}
+
+//BitOffset returns the number of bits encoded before the value, or -1 when it can not be known because Encode was
+// given a BitSetWriter other than a BitSetBuffer.
+func (c *EncodeContext) BitOffset() int {
+	switch w := c.buf.(type) {
+	case *byteWriter:
+		return w.pos()
+	case *bitCounter:
+		return w.n
+	case *bits.BitSetBuffer:
+		return bitPos(w)
+	}
+	return -1
+}
+
+//EncodeField encodes v, a field of the value called name, with the given tags. Integer fields can then be found with
+// Lookup and referred to by the tags of the fields after them.
+func (c *EncodeContext) EncodeField(name string, v reflect.Value, tag reflect.StructTag) error {
+	if !v.IsValid() {
+		return fmt.Errorf("%v: invalid value", joinPath(c.path, name))
+	}
+	return EncodeField(name, v.Type(), v, tag, c.buf, c.sizeMap, c.options...)
+}
+
+//DecodeContext is given to FieldCodec.Decode.
+type DecodeContext struct {
+	fieldContext
+	buf *bits.BitSetBuffer
+}
+
+//Reader returns the buffer the value is decoded from.
+func (c *DecodeContext) Reader() *bits.BitSetBuffer {
+	return c.buf
+}
+
+//BitOffset returns the number of bits decoded before the value.
+func (c *DecodeContext) BitOffset() int {
+	return getReadCursor(c.options).bitPos(c.buf)
+}
+
+//DecodeField decodes v, a field of the value called name, with the given tags. v must be settable, e.g. a field of
+// the value or reflect.New(t).Elem(). Integer fields can then be found with Lookup and referred to by the tags of the
+// fields after them.
+func (c *DecodeContext) DecodeField(name string, v reflect.Value, tag reflect.StructTag) error {
+	if !v.CanSet() {
+		return fmt.Errorf("%v: value can not be set", joinPath(c.path, name))
+	}
+	return DecodeField(name, v.Type(), v, tag, c.buf, c.sizeMap, c.options...)
+}
+
+//fieldPath is the path of the value being encoded or decoded, kept while the options hold a FieldCodec so the codecs
+// know where they are, and while converting to and from JSON. EncodeField, DecodeField and skipField enter it as they
+// start on a value and leave it when done.
+type fieldPath struct {
+	frames []pathFrame
+}
+
+type pathFrame struct {
+	name string
+	path string
+	//through is set for pointers and interfaces, and skipped values, which hand their value on under the same name
+	through bool
+	items   int
+}
+
+func (p *fieldPath) Type() reflect.Type {
+	return nil
+}
+
+func (p *fieldPath) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (p *fieldPath) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (p *fieldPath) setting() {}
+
+//enter is called as a value of type t called fieldName is started on, skip is true when it is only skipped over.
+func (p *fieldPath) enter(fieldName string, t reflect.Type, skip bool) {
+	path := fieldName
+	if len(p.frames) > 0 {
+		parent := &p.frames[len(p.frames)-1]
+		switch {
+		case parent.through && fieldName == parent.name:
+			path = parent.path
+		case fieldName == "":
+			path = fmt.Sprintf("%v[%v]", parent.path, parent.items)
+			parent.items++
+		default:
+			path = joinPath(parent.path, fieldName)
+		}
+	}
+	through := skip || t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface
+	p.frames = append(p.frames, pathFrame{name: fieldName, path: path, through: through})
+}
+
+//leave is called when the value last entered is done.
+func (p *fieldPath) leave() {
+	p.frames = p.frames[:len(p.frames)-1]
+}
+
+//current returns the path of the value last entered.
+func (p *fieldPath) current() string {
+	if len(p.frames) == 0 {
+		return ""
+	}
+	return p.frames[len(p.frames)-1].path
+}
+
+//getFieldPath returns the fieldPath of the options, nil when there is none.
+func getFieldPath(options []EncDecOption) *fieldPath {
+	for _, item := range options {
+		if p, ok := item.(*fieldPath); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+//withFieldPath returns the options with a fieldPath added when they hold a FieldCodec and no fieldPath yet, the one
+// of an outer call.
+func withFieldPath(options []EncDecOption) []EncDecOption {
+	needed := false
+	for _, item := range options {
+		switch o := item.(type) {
+		case *fieldPath:
+			return options
+		case *TypeEncDec:
+			needed = needed || o.codec != nil
+		case *Codec:
+			needed = needed || o.fieldCodecs
+		}
+	}
+	if !needed {
+		return options
+	}
+	return append(options[:len(options):len(options)], &fieldPath{})
+}
@@ -0,0 +1,264 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//label is a string encoded as a uint8 length followed by its bytes, it may be limited with `maxlen`.
+type label string
+
+//labelPair is encoded as its two labels, with the first one limited to 2 bytes.
+type labelPair struct {
+	First  label
+	Second label
+}
+
+//recorder notes the path and bit offset of each value it is given.
+type recorder struct {
+	seen []string
+}
+
+func (r *recorder) note(path string, offset int) {
+	r.seen = append(r.seen, fmt.Sprintf("%v@%v", path, offset))
+}
+
+type labelCodec struct{ *recorder }
+
+func (c labelCodec) Encode(ctx *EncodeContext, v reflect.Value) error {
+	c.note(ctx.Path(), ctx.BitOffset())
+	if max, has := ctx.Tag("maxlen"); has {
+		n, err := strconv.Atoi(max)
+		if err != nil {
+			return fmt.Errorf("%v: %v", ctx.Path(), err)
+		}
+		if v.Len() > n {
+			return fmt.Errorf("%v: longer than %v", ctx.Path(), n)
+		}
+	}
+	if err := ctx.EncodeField("Len", reflect.ValueOf(uint8(v.Len())), ""); err != nil {
+		return err
+	}
+	_, err := ctx.Writer().Write([]byte(v.String()))
+	return err
+}
+
+func (c labelCodec) Decode(ctx *DecodeContext, v reflect.Value) error {
+	c.note(ctx.Path(), ctx.BitOffset())
+	if err := ctx.DecodeField("Len", reflect.New(reflect.TypeOf(uint8(0))).Elem(), ""); err != nil {
+		return err
+	}
+	n, _ := ctx.Lookup("Len")
+	bs := make([]byte, n)
+	if read, err := ctx.Reader().Read(bs); err != nil || read != n {
+		return fmt.Errorf("%v: needed %v bytes but read %v: %v", ctx.Path(), n, read, err)
+	}
+	v.SetString(string(bs))
+	return nil
+}
+
+type pairCodec struct{ *recorder }
+
+func (c pairCodec) Encode(ctx *EncodeContext, v reflect.Value) error {
+	c.note(ctx.Path(), ctx.BitOffset())
+	if err := ctx.EncodeField("First", v.Field(0), `maxlen:"2"`); err != nil {
+		return err
+	}
+	return ctx.EncodeField("Second", v.Field(1), "")
+}
+
+func (c pairCodec) Decode(ctx *DecodeContext, v reflect.Value) error {
+	c.note(ctx.Path(), ctx.BitOffset())
+	if err := ctx.DecodeField("First", v.Field(0), `maxlen:"2"`); err != nil {
+		return err
+	}
+	return ctx.DecodeField("Second", v.Field(1), "")
+}
+
+func fieldCodecs(r *recorder) []EncDecOption {
+	return []EncDecOption{
+		CodecFor(reflect.TypeOf(label("")), labelCodec{r}),
+		CodecFor(reflect.TypeOf(labelPair{}), pairCodec{r}),
+	}
+}
+
+func TestFieldCodec(t *testing.T) {
+	type message struct {
+		Flags uint8 `bits:"4"`
+		Name  label
+		Pair  labelPair
+	}
+	//the same bits without the codecs
+	type plain struct {
+		Flags     uint8 `bits:"4"`
+		NameLen   uint8
+		Name      [2]byte
+		FirstLen  uint8
+		First     [1]byte
+		SecondLen uint8
+		Second    [3]byte
+	}
+	input := message{Flags: 9, Name: "ab", Pair: labelPair{First: "x", Second: "yes"}}
+	expected, err := Encode(&plain{9, 2, [2]byte{'a', 'b'}, 1, [1]byte{'x'}, 3, [3]byte{'y', 'e', 's'}})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	seen := []string{"Name@4", "Pair@28", "Pair.First@28", "Pair.Second@44"}
+
+	r := &recorder{}
+	bs, err := Encode(&input, fieldCodecs(r)...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+	if !reflect.DeepEqual(seen, r.seen) {
+		t.Fatalf("expected %v but found %v", seen, r.seen)
+	}
+
+	//the offsets are from the start of what is encoded
+	for _, encode := range []func() error{
+		func() error { _, err := AppendEncode([]byte{1, 2, 3}, &input, fieldCodecs(r)...); return err },
+		func() error { _, err := EncodeToBits(&input, fieldCodecs(r)...); return err },
+	} {
+		r.seen = nil
+		if err := encode(); err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		if !reflect.DeepEqual(seen, r.seen) {
+			t.Fatalf("expected %v but found %v", seen, r.seen)
+		}
+	}
+
+	r.seen = nil
+	var actual message
+	if err := Decode(bs, &actual, fieldCodecs(r)...); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", input, actual)
+	}
+	if !reflect.DeepEqual(seen, r.seen) {
+		t.Fatalf("expected %v but found %v", seen, r.seen)
+	}
+
+	//the codecs are kept by a Codec like any other option
+	codec, err := NewCodec(fieldCodecs(r)...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if bs, err = codec.Encode(&input); err != nil || !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n %v", expected, bs, err)
+	}
+}
+
+func TestFieldCodecPath(t *testing.T) {
+	type inner struct {
+		Pairs [2]labelPair
+	}
+	type message struct {
+		Count uint8
+		Inner *inner
+		Names []label `size:"Count"`
+	}
+	input := message{Count: 1, Inner: &inner{Pairs: [2]labelPair{{"a", "b"}, {"c", "d"}}}, Names: []label{"e"}}
+	seen := []string{
+		"Inner.Pairs[0]", "Inner.Pairs[0].First", "Inner.Pairs[0].Second",
+		"Inner.Pairs[1]", "Inner.Pairs[1].First", "Inner.Pairs[1].Second", "Names[0]",
+	}
+	paths := func(r *recorder) []string {
+		var paths []string
+		for _, s := range r.seen {
+			paths = append(paths, s[:strings.Index(s, "@")])
+		}
+		return paths
+	}
+
+	//the paths are from the top level struct, through the fields that are not encoded by a codec
+	r := &recorder{}
+	bs, err := Encode(&input, fieldCodecs(r)...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(seen, paths(r)) {
+		t.Fatalf("expected %v but found %v", seen, paths(r))
+	}
+
+	r.seen = nil
+	var actual message
+	if err := Decode(bs, &actual, fieldCodecs(r)...); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(seen, paths(r)) {
+		t.Fatalf("expected %v but found %v", seen, paths(r))
+	}
+
+	//values skipped over have the same paths
+	r.seen = nil
+	if err := Decode(bs, &actual, append(fieldCodecs(r), OnlyFields("Names"))...); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(seen, paths(r)) {
+		t.Fatalf("expected %v but found %v", seen, paths(r))
+	}
+
+	codec, err := NewCodec(fieldCodecs(r)...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	r.seen = nil
+	if _, err := codec.Encode(&input); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(seen, paths(r)) {
+		t.Fatalf("expected %v but found %v", seen, paths(r))
+	}
+
+	_, err = Encode(&message{Inner: &inner{Pairs: [2]labelPair{{}, {First: "long"}}}}, fieldCodecs(r)...)
+	if err == nil || !strings.Contains(err.Error(), "Inner.Pairs[1].First: longer than 2") {
+		t.Fatalf("expected the path in the error but found: %v", err)
+	}
+}
+
+func TestFieldCodecErrors(t *testing.T) {
+	type message struct {
+		Pair labelPair
+	}
+	r := &recorder{}
+
+	_, err := Encode(&message{Pair: labelPair{First: "long"}}, fieldCodecs(r)...)
+	if err == nil || !strings.Contains(err.Error(), "Pair.First: longer than 2") {
+		t.Fatalf("expected the path in the error but found: %v", err)
+	}
+
+	var actual message
+	err = Decode([]byte{1, 'x', 5, 'y'}, &actual, fieldCodecs(r)...)
+	if err == nil || !strings.Contains(err.Error(), "Pair.Second: needed 5 bytes") {
+		t.Fatalf("expected the path in the error but found: %v", err)
+	}
+
+	if _, err := Encode(&message{}, CodecFor(reflect.TypeOf(labelPair{}), nil)); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	//DecodeField needs a value it can set
+	var pair unsettableCodec
+	if err := Decode([]byte{0, 0}, &actual, CodecFor(reflect.TypeOf(labelPair{}), pair)); err == nil || !strings.Contains(err.Error(), "Pair.First") {
+		t.Fatalf("expected an error for the value that can not be set but found: %v", err)
+	}
+}
+
+//unsettableCodec decodes into a value that can not be set.
+type unsettableCodec struct{}
+
+func (unsettableCodec) Encode(ctx *EncodeContext, v reflect.Value) error {
+	return nil
+}
+
+func (unsettableCodec) Decode(ctx *DecodeContext, v reflect.Value) error {
+	return ctx.DecodeField("First", reflect.ValueOf(label("")), "")
+}
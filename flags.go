@@ -0,0 +1,163 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+//FlagNames is the name table of a flags type. A flags type is a named unsigned integer type (e.g. `type Flags uint8`)
+// where each bit is a flag. It is encoded and decoded like any other integer, but when decoding any bit that has no
+// name (a reserved bit) must be zero.
+type FlagNames struct {
+	t     reflect.Type
+	names []string
+}
+
+var (
+	flagsLock     sync.RWMutex
+	flagsRegistry = map[reflect.Type]*FlagNames{}
+)
+
+//RegisterFlags registers the flag names of the type of value, which must be an unsigned integer type. The name at
+// index i is the name of bit i, where bit 0 is the least significant bit. An empty name marks a reserved bit, as
+// does every bit past the last name. RegisterFlags is meant to be called during initialization and panics if value is
+// not an unsigned integer, if there are more names than bits or if a name is repeated.
+//
+//  type IPFlags uint8
+//
+//  var ipFlags = binary.RegisterFlags(IPFlags(0), "", "DontFrag", "MoreFrag")
+//
+//  func (f IPFlags) Has(flag IPFlags) bool { return f&flag == flag }
+//  func (f *IPFlags) Set(flag IPFlags)     { *f |= flag }
+//  func (f IPFlags) String() string        { return ipFlags.Format(uint64(f)) }
+func RegisterFlags(value interface{}, names ...string) *FlagNames {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		panic("binary: RegisterFlags with nil value")
+	}
+
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic(fmt.Sprintf("binary: RegisterFlags type %v must be an unsigned integer", t))
+	}
+
+	if len(names) > t.Bits() {
+		panic(fmt.Sprintf("binary: RegisterFlags type %v has %v bits but %v names were given", t, t.Bits(), len(names)))
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			panic(fmt.Sprintf("binary: RegisterFlags type %v has the name %v more than once", t, name))
+		}
+		seen[name] = true
+	}
+
+	f := &FlagNames{
+		t:     t,
+		names: append([]string(nil), names...),
+	}
+
+	flagsLock.Lock()
+	defer flagsLock.Unlock()
+	flagsRegistry[t] = f
+	return f
+}
+
+//LookupFlags returns the FlagNames registered for t.
+func LookupFlags(t reflect.Type) (*FlagNames, bool) {
+	flagsLock.RLock()
+	defer flagsLock.RUnlock()
+	f, has := flagsRegistry[t]
+	return f, has
+}
+
+//Type returns the flags type the names belong to.
+func (f *FlagNames) Type() reflect.Type {
+	return f.t
+}
+
+//Flag returns the value with only the bit of the named flag set.
+func (f *FlagNames) Flag(name string) (uint64, bool) {
+	for i, n := range f.names {
+		if n != "" && n == name {
+			return 1 << uint(i), true
+		}
+	}
+	return 0, false
+}
+
+//Has reports if the named flag is set in value. Unknown names are never set.
+func (f *FlagNames) Has(value uint64, name string) bool {
+	flag, has := f.Flag(name)
+	return has && value&flag != 0
+}
+
+//Set returns value with the named flag set. Unknown names leave value unchanged.
+func (f *FlagNames) Set(value uint64, name string) uint64 {
+	flag, _ := f.Flag(name)
+	return value | flag
+}
+
+//Clear returns value with the named flag cleared. Unknown names leave value unchanged.
+func (f *FlagNames) Clear(value uint64, name string) uint64 {
+	flag, _ := f.Flag(name)
+	return value &^ flag
+}
+
+//Names returns the names of the flags set in value ordered from the least significant bit.
+func (f *FlagNames) Names(value uint64) []string {
+	names := make([]string, 0)
+	for i, n := range f.names {
+		if n != "" && value&(1<<uint(i)) != 0 {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+//Reserved returns the bits of value that are set but have no name.
+func (f *FlagNames) Reserved(value uint64) uint64 {
+	known := uint64(0)
+	for i, n := range f.names {
+		if n != "" {
+			known |= 1 << uint(i)
+		}
+	}
+	return value &^ known
+}
+
+//Format returns a readable form of value listing the set flags separated by `|`, e.g. `DontFrag|MoreFrag`. Reserved
+// bits that are set are listed as a hex value and `0` is returned when nothing is set.
+func (f *FlagNames) Format(value uint64) string {
+	parts := f.Names(value)
+	if reserved := f.Reserved(value); reserved != 0 {
+		parts = append(parts, fmt.Sprintf("%#x", reserved))
+	}
+	if len(parts) == 0 {
+		return "0"
+	}
+	return strings.Join(parts, "|")
+}
+
+//Check returns an error if any reserved bit is set in value.
+func (f *FlagNames) Check(value uint64) error {
+	if reserved := f.Reserved(value); reserved != 0 {
+		return fmt.Errorf("reserved bits %#x set in %v", reserved, f.t)
+	}
+	return nil
+}
+
+func checkFlags(t reflect.Type, value uint64) error {
+	f, has := LookupFlags(t)
+	if !has {
+		return nil
+	}
+	return f.Check(value)
+}
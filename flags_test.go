@@ -0,0 +1,102 @@
+package binary
+
+import (
+	"reflect"
+	"testing"
+)
+
+type ipFlags uint8
+
+const (
+	ipDontFrag ipFlags = 1 << 1
+	ipMoreFrag ipFlags = 1 << 2
+)
+
+var ipFlagNames = RegisterFlags(ipFlags(0), "", "DontFrag", "MoreFrag")
+
+func (f ipFlags) Has(flag ipFlags) bool { return f&flag == flag }
+func (f *ipFlags) Set(flag ipFlags)     { *f |= flag }
+func (f ipFlags) String() string        { return ipFlagNames.Format(uint64(f)) }
+
+func TestFlags(t *testing.T) {
+	type fragment struct {
+		Flags      ipFlags `bits:"3"`
+		FragOffset uint16  `bits:"13" endian:"big"`
+	}
+
+	var f ipFlags
+	f.Set(ipDontFrag)
+	if !f.Has(ipDontFrag) || f.Has(ipMoreFrag) {
+		t.Fatalf("expected only DontFrag but found %v", f)
+	}
+
+	expected := fragment{Flags: ipDontFrag | ipMoreFrag, FragOffset: 3}
+	bs, err := Encode(expected)
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+
+	expectedBs := []byte{0x06, 0x03}
+	if !reflect.DeepEqual(bs, expectedBs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expectedBs, bs)
+	}
+
+	var actual fragment
+	err = Decode(bs, &actual)
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
+	}
+
+	if actual.Flags.String() != "DontFrag|MoreFrag" {
+		t.Fatalf("expected DontFrag|MoreFrag but found %v", actual.Flags)
+	}
+
+	//the reserved bit is set so decoding must fail
+	err = Decode([]byte{0x07, 0x03}, &actual)
+	if err == nil {
+		t.Fatalf("expected reserved bit error")
+	}
+}
+
+func TestFlagNames(t *testing.T) {
+	names, has := LookupFlags(reflect.TypeOf(ipFlags(0)))
+	if !has || names != ipFlagNames {
+		t.Fatalf("expected registered flag names")
+	}
+
+	tests := []struct {
+		value    uint64
+		expected string
+		names    []string
+	}{
+		{0, "0", []string{}},
+		{2, "DontFrag", []string{"DontFrag"}},
+		{6, "DontFrag|MoreFrag", []string{"DontFrag", "MoreFrag"}},
+		{0x81, "0x81", []string{}},
+		{0x84, "MoreFrag|0x80", []string{"MoreFrag"}},
+	}
+	for _, test := range tests {
+		if actual := names.Format(test.value); actual != test.expected {
+			t.Fatalf("expected %v but found %v", test.expected, actual)
+		}
+		if actual := names.Names(test.value); !reflect.DeepEqual(actual, test.names) {
+			t.Fatalf("expected %v but found %v", test.names, actual)
+		}
+	}
+
+	value := names.Set(0, "MoreFrag")
+	if value != 4 || !names.Has(value, "MoreFrag") {
+		t.Fatalf("expected MoreFrag to be set found %v", value)
+	}
+	value = names.Clear(value, "MoreFrag")
+	if value != 0 {
+		t.Fatalf("expected MoreFrag to be cleared found %v", value)
+	}
+	if names.Has(0xff, "Unknown") {
+		t.Fatalf("expected unknown flags to never be set")
+	}
+}
@@ -0,0 +1,124 @@
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+type ipv4Header struct {
+	Version        uint8   `bits:"4"`
+	IHL            uint8   `bits:"4"`
+	DSCP           uint8   `bits:"6"`
+	ECN            uint8   `bits:"2"`
+	TotalLength    uint16  `endian:"big"`
+	Identification uint16  `endian:"big"`
+	Flags          ipFlags `bits:"3"`
+	FragOffset     uint16  `bits:"13" endian:"big"`
+	TTL            uint8
+	Protocol       uint8
+	Checksum       uint16 `endian:"big"`
+	Source         [4]byte
+	Destination    [4]byte
+}
+
+type fuzzSized struct {
+	Count  uint8
+	Items  []int16 `size:"Count"`
+	Length uint8
+	Name   string `strlen:"Length"`
+	Rest   []byte
+}
+
+type fuzzNested struct {
+	Header  *routedHeader
+	Message routedMessage
+	Enabled bool
+	Ratio   float32
+	private uint8
+	Tail    string
+}
+
+//fuzzShapes returns new values of the types decoded by FuzzDecode.
+func fuzzShapes() []interface{} {
+	return []interface{}{
+		&ipv4Header{},
+		&fuzzSized{},
+		&fuzzNested{},
+		&routedMessage{},
+		&validatedMessage{},
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	seeds := []interface{}{
+		ipv4Header{Version: 4, IHL: 5, TotalLength: 20, Flags: ipDontFrag, TTL: 64, Protocol: 6,
+			Source: [4]byte{10, 0, 0, 1}, Destination: [4]byte{10, 0, 0, 2}},
+		fuzzSized{Count: 2, Items: []int16{-1, 1}, Length: 3, Name: "abc", Rest: []byte{1, 2}},
+		routedInput(),
+	}
+	for _, seed := range seeds {
+		bs, err := Encode(seed)
+		if err != nil {
+			f.Fatalf("expected no encoding error found: %v", err)
+		}
+		f.Add(bs)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		//the whole input is turned into bits up front, large inputs only slow the fuzzer down
+		if len(data) > 1024 {
+			return
+		}
+
+		for _, value := range fuzzShapes() {
+			limits := &DecodeOptions{MaxTotalAllocBytes: 1 << 20}
+			_, err := DumpBytes(data, reflect.New(reflect.TypeOf(value).Elem()).Interface(), limits)
+			failOnPanic(t, value, err)
+			buf, err := bits.NewFromBytes(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			failOnPanic(t, value, Skip(buf, reflect.TypeOf(value), limits))
+
+			err = Decode(data, value, limits)
+			failOnPanic(t, value, err)
+			if err != nil {
+				continue
+			}
+
+			//whatever decodes must encode, and the encoding must decode back to the same value and bytes
+			bs, err := Encode(value)
+			failOnPanic(t, value, err)
+			if err != nil {
+				continue
+			}
+
+			again := reflect.New(reflect.TypeOf(value).Elem()).Interface()
+			if err := Decode(bs, again); err != nil {
+				t.Fatalf("%T: expected no decoding error for %x found: %v", value, bs, err)
+			}
+
+			actual, err := Encode(again)
+			if err != nil {
+				t.Fatalf("%T: expected no encoding error found: %v", value, err)
+			}
+			if !bytes.Equal(bs, actual) {
+				t.Fatalf("%T: expected \n%x\n but found \n%x\n", value, bs, actual)
+			}
+		}
+	})
+}
+
+//failOnPanic fails the test when err was made from a panic. The panic does not crash the caller but it is still a bug.
+func failOnPanic(t *testing.T, value interface{}, err error) {
+	t.Helper()
+	if err != nil && strings.HasPrefix(err.Error(), panicPrefix) {
+		t.Fatalf("%T: %v", value, err)
+	}
+}
@@ -1,5 +1,8 @@
 module github.com/nathanhack/binary
 
-go 1.13
+go 1.18
 
-require github.com/nathanhack/bitsetbuffer v0.0.0-20210427021742-66257cc07bb4
+require (
+	github.com/nathanhack/bitsetbuffer v0.0.0-20210427021742-66257cc07bb4
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,42 @@
+//Package bitfields splits numbers into the bit fields the code generators declare them as.
+package bitfields
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Natural returns the width in bits of an integer or bool kind, e.g. 16 for `uint16`.
+func Natural(kind string) int {
+	if kind == "bool" {
+		return 8
+	}
+	natural, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(kind, "u"), "int"))
+	return natural
+}
+
+//BigEndian splits a big endian number of width bits into the bit fields it is written as: the partial byte first,
+// followed by the whole bytes from the most significant. It returns the widths of the fields and the expression joining
+// the fields, called name(i), back into the number, e.g. `(x_0 << 8) | x_1`.
+func BigEndian(width int, name func(i int) string) ([]int, string) {
+	var parts []int
+	if width%8 != 0 {
+		parts = append(parts, width%8)
+	}
+	for i := 0; i < width/8; i++ {
+		parts = append(parts, 8)
+	}
+
+	terms := make([]string, 0, len(parts))
+	shift := width
+	for i, n := range parts {
+		shift -= n
+		if shift > 0 {
+			terms = append(terms, fmt.Sprintf("(%v << %v)", name(i), shift))
+		} else {
+			terms = append(terms, name(i))
+		}
+	}
+	return parts, strings.Join(terms, " | ")
+}
@@ -0,0 +1,36 @@
+package bitfields
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBigEndian(t *testing.T) {
+	name := func(i int) string { return fmt.Sprintf("x_%v", i) }
+	tests := []struct {
+		width int
+		parts []int
+		expr  string
+	}{
+		{16, []int{8, 8}, "(x_0 << 8) | x_1"},
+		{13, []int{5, 8}, "(x_0 << 8) | x_1"},
+		{20, []int{4, 8, 8}, "(x_0 << 16) | (x_1 << 8) | x_2"},
+		{8, []int{8}, "x_0"},
+	}
+	for _, test := range tests {
+		parts, expr := BigEndian(test.width, name)
+		if !reflect.DeepEqual(test.parts, parts) || test.expr != expr {
+			t.Errorf("%v: expected %v %q but found %v %q", test.width, test.parts, test.expr, parts, expr)
+		}
+	}
+}
+
+func TestNatural(t *testing.T) {
+	tests := map[string]int{"bool": 8, "uint8": 8, "int16": 16, "uint32": 32, "int64": 64}
+	for kind, expected := range tests {
+		if actual := Natural(kind); actual != expected {
+			t.Errorf("%v: expected %v but found %v", kind, expected, actual)
+		}
+	}
+}
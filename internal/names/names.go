@@ -0,0 +1,45 @@
+//Package names converts between Go names and the lower case identifiers used by other tools.
+package names
+
+import (
+	"strings"
+	"unicode"
+)
+
+//Snake returns the snake case identifier of a Go name, e.g. `TotalLength` becomes `total_length` and `IHL` becomes
+// `ihl`.
+func Snake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			//a new word starts at an upper case letter following a lower case letter or digit, or at the last upper
+			// case letter of an acronym followed by a lower case letter
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+//Camel returns the exported Go name of a snake case identifier, e.g. `total_length` becomes `TotalLength`.
+func Camel(id string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range id {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package names
+
+import "testing"
+
+func TestSnake(t *testing.T) {
+	tests := map[string]string{
+		"TotalLength": "total_length",
+		"IHL":         "ihl",
+		"HTTPServer":  "http_server",
+		"Field2Name":  "field2_name",
+		"x":           "x",
+	}
+	for name, expected := range tests {
+		if actual := Snake(name); actual != expected {
+			t.Errorf("Snake(%q): expected %q but found %q", name, expected, actual)
+		}
+	}
+}
+
+func TestCamel(t *testing.T) {
+	tests := map[string]string{
+		"total_length": "TotalLength",
+		"ihl":          "Ihl",
+		"a__b":         "AB",
+	}
+	for id, expected := range tests {
+		if actual := Camel(id); actual != expected {
+			t.Errorf("Camel(%q): expected %q but found %q", id, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+//Package tagrules holds the rules for the tags of encoded structs. They are shared by the checks done with reflection
+// and the ones done on source code, so both only need to describe the fields of a struct.
+package tagrules
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//Keys are the tags of a field, each may also be a setting of its `binary` tag.
+var Keys = []string{"omit", "bits", "endian", "size", "strlen", "min", "max", "oneof"}
+
+//StdKeys are the tags read by the options for the types of the standard library.
+var StdKeys = []string{"time", "duration", "ip", "mac", "bigint"}
+
+//Setting is a setting of a `binary` tag.
+type Setting struct {
+	Key, Value string
+	//Bare is true for a key given without a value, e.g. omit
+	Bare bool
+}
+
+//ParseBinary returns the settings of a `binary` tag, a list of key=value pairs separated by commas, along with the
+// problems found.
+func ParseBinary(s string) ([]Setting, []string) {
+	var settings []Setting
+	var problems []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" {
+			problems = append(problems, fmt.Sprintf("binary tag %q has a setting without a key", s))
+			continue
+		}
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("binary tag %q sets %v more than once", s, key))
+			continue
+		}
+		seen[key] = true
+		settings = append(settings, Setting{Key: key, Value: value, Bare: !found})
+	}
+	return settings, problems
+}
+
+//ParseDefaults returns the endianness and bit order set by the `binary` tag s of a blank struct{} field, which sets the
+// defaults of its struct, along with the problems found.
+func ParseDefaults(s string) (string, string, []string) {
+	settings, problems := ParseBinary(s)
+	endian, bitOrder := "", ""
+	for _, setting := range settings {
+		switch setting.Key {
+		case "endian":
+			if setting.Value != "little" && setting.Value != "big" {
+				problems = append(problems, fmt.Sprintf("unsupported endian value: %v", setting.Value))
+			}
+			endian = setting.Value
+		case "bitorder":
+			if setting.Value != "lsb" && setting.Value != "msb" {
+				problems = append(problems, fmt.Sprintf("unsupported bitorder value: %v", setting.Value))
+			}
+			bitOrder = setting.Value
+		default:
+			problems = append(problems, fmt.Sprintf("unknown struct binary tag setting %v", setting.Key))
+		}
+	}
+	return endian, bitOrder, problems
+}
+
+//Expand returns tag with the settings of its `binary` tag added as the separate tags, along with the problems found.
+// A setting may also be given by its separate tag as long as the values are the same.
+func Expand(tag reflect.StructTag) (reflect.StructTag, []string) {
+	s, has := tag.Lookup("binary")
+	if !has {
+		return tag, nil
+	}
+
+	settings, problems := ParseBinary(s)
+	expanded := string(tag)
+	for _, setting := range settings {
+		switch {
+		case !isKey(setting.Key):
+			problems = append(problems, fmt.Sprintf("unknown binary tag setting %v", setting.Key))
+			continue
+		case setting.Key == "omit" && setting.Value != "":
+			problems = append(problems, "binary tag setting omit takes no value")
+			continue
+		case setting.Key != "omit" && setting.Value == "":
+			problems = append(problems, fmt.Sprintf("binary tag setting %v has no value", setting.Key))
+			continue
+		case setting.Key == "endian" && setting.Value != "little" && setting.Value != "big":
+			problems = append(problems, fmt.Sprintf("unsupported endian value: %v", setting.Value))
+			continue
+		}
+
+		if legacy, has := tag.Lookup(setting.Key); has {
+			if legacy != setting.Value {
+				problems = append(problems, fmt.Sprintf("binary tag sets %v=%v but the %v tag is %q", setting.Key, setting.Value, setting.Key, legacy))
+			}
+			continue
+		}
+		expanded += fmt.Sprintf(" %v:%v", setting.Key, strconv.Quote(setting.Value))
+	}
+	return reflect.StructTag(strings.TrimSpace(expanded)), problems
+}
+
+func isKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+//Field describes a field of a struct for Check.
+type Field struct {
+	Name string
+	Tag  reflect.StructTag
+	//Kind is the kind of the field after going through pointers, Item is the kind of the values in it after also going
+	// through arrays and slices. Named types have the kind of their underlying type.
+	Kind, Item reflect.Kind
+	//Custom is true when the type of the field, or of its items, encodes itself with MarshalBits and UnmarshalBits
+	Custom bool
+	//Empty is true when the type of the field is a struct without fields, such as struct{}
+	Empty bool
+}
+
+//MarksDefaults reports if f is a blank struct{} field, whose `binary` tag sets the defaults of its struct. The
+// `binary` tag of any other field, blank padding fields included, is the same as the separate tags.
+func MarksDefaults(f Field) bool {
+	return f.Name == "_" && f.Kind == reflect.Struct && f.Empty
+}
+
+//Problem is a problem found in the tags of the field at Index.
+type Problem struct {
+	Index   int
+	Message string
+}
+
+//Check returns the problems found in the tags of the fields of a struct, given in the order they are declared. Sizes
+// may also refer to the fields encoded before the struct, earlier holds their kinds by name. When earlier is nil these
+// are not known and names that are not fields of the struct are not reported.
+func Check(fields []Field, earlier map[string]reflect.Kind) []Problem {
+	c := checker{declared: map[string]int{}, earlier: earlier}
+	for i, f := range fields {
+		c.index = i
+		c.field(fields, f)
+		c.declared[f.Name] = i
+	}
+	return c.problems
+}
+
+type checker struct {
+	problems []Problem
+	index    int
+	marked   bool
+	//declared are the indexes of the fields by name
+	declared map[string]int
+	earlier  map[string]reflect.Kind
+}
+
+func (c *checker) report(format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{Index: c.index, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) field(fields []Field, f Field) {
+	if s, has := f.Tag.Lookup("binary"); has && MarksDefaults(f) {
+		if c.marked {
+			c.report("more than one binary tag on a blank struct{} field")
+		}
+		c.marked = true
+		_, _, problems := ParseDefaults(s)
+		for _, problem := range problems {
+			c.report("%v", problem)
+		}
+		return
+	}
+
+	for _, key := range TagKeys(f.Tag) {
+		if like := misspelled(key); like != "" {
+			c.report("unknown tag %v, did you mean %v?", key, like)
+		}
+	}
+
+	tag, problems := Expand(f.Tag)
+	for _, problem := range problems {
+		c.report("%v", problem)
+	}
+	if _, has := tag.Lookup("omit"); has {
+		for _, key := range Keys[1:] {
+			if _, has := tag.Lookup(key); has {
+				c.report("omit along with %v, the field is not encoded", key)
+			}
+		}
+		return
+	}
+
+	if f.Name != "_" && !isExported(f.Name) {
+		c.report("unexported field is encoded as its zero value and skipped when decoding")
+	}
+	if !f.Custom {
+		switch f.Item {
+		case reflect.Int, reflect.Uint, reflect.Uintptr, reflect.Complex64, reflect.Complex128, reflect.Map,
+			reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			c.report("%v not supported", f.Item)
+			return
+		}
+	}
+
+	if value, has := tag.Lookup("endian"); has && value != "little" && value != "big" {
+		c.report("unsupported endian value: %v", value)
+	}
+	minimum, minErr := strconv.ParseFloat(tag.Get("min"), 64)
+	maximum, maxErr := strconv.ParseFloat(tag.Get("max"), 64)
+	if minErr == nil && maxErr == nil && minimum > maximum {
+		c.report("min %v is greater than max %v", tag.Get("min"), tag.Get("max"))
+	}
+
+	for _, key := range []string{"size", "strlen", "bits"} {
+		value, has := tag.Lookup(key)
+		if !has {
+			continue
+		}
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			c.reference(fields, key, value)
+		}
+	}
+
+	if s, has := tag.Lookup("bits"); has && !f.Custom {
+		c.bits(f.Item, s)
+	}
+}
+
+//reference checks the field named by the tag key, which must be an integer field declared before the field checked.
+func (c *checker) reference(fields []Field, key, name string) {
+	var kind reflect.Kind
+	if i, has := c.declared[name]; has {
+		tag, _ := Expand(fields[i].Tag)
+		if _, has := tag.Lookup("omit"); has {
+			c.report("%v refers to %v, which is omitted", key, name)
+			return
+		}
+		kind = fields[i].Kind
+	} else {
+		if fields[c.index].Name == name {
+			c.report("%v refers to the field itself", key)
+			return
+		}
+		for j := c.index + 1; j < len(fields); j++ {
+			if fields[j].Name == name {
+				c.report("%v refers to %v, which is declared after it", key, name)
+				return
+			}
+		}
+		if c.earlier == nil {
+			return
+		}
+		if kind, has = c.earlier[name]; !has {
+			c.report("%v must either be a number or the name of a field declared before it, there is no field %v", key, name)
+			return
+		}
+	}
+
+	switch kind {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		c.report("%v refers to %v, which is not an integer", key, name)
+	}
+}
+
+//bits checks the number of bits s of a value of kind k.
+func (c *checker) bits(k reflect.Kind, s string) {
+	width, signed := 0, false
+	switch k {
+	case reflect.Bool, reflect.Uint8:
+		width = 8
+	case reflect.Uint16:
+		width = 16
+	case reflect.Uint32:
+		width = 32
+	case reflect.Uint64:
+		width = 64
+	case reflect.Int8:
+		width, signed = 8, true
+	case reflect.Int16:
+		width, signed = 16, true
+	case reflect.Int32:
+		width, signed = 32, true
+	case reflect.Int64:
+		width, signed = 64, true
+	default:
+		c.report("bits not supported on %v", k)
+		return
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	switch {
+	case err != nil:
+		//a reference to another field, its value is only known when encoding
+	case n > uint64(width):
+		c.report("bits %v is more than the %v bits of %v", n, width, k)
+	case signed && n < 2:
+		c.report("bits %v on the signed %v, it needs at least 2 bits", n, k)
+	}
+}
+
+func isExported(name string) bool {
+	return name != "" && strings.ToUpper(name[:1]) == name[:1] && strings.ToLower(name[:1]) != name[:1]
+}
+
+//TagKeys returns the keys of tag, which follows the convention of reflect.StructTag.
+func TagKeys(tag reflect.StructTag) []string {
+	var keys []string
+	s := string(tag)
+	for s != "" {
+		s = strings.TrimLeft(s, " ")
+		i := 0
+		for i < len(s) && s[i] > ' ' && s[i] != ':' && s[i] != '"' && s[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(s) || s[i] != ':' || s[i+1] != '"' {
+			break
+		}
+		keys = append(keys, s[:i])
+		s = s[i+1:]
+
+		i = 1
+		for i < len(s) && s[i] != '"' {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		s = s[i+1:]
+	}
+	return keys
+}
+
+//Uses reports if tag has any of the tags of an encoded field, or one that looks like a misspelling of them.
+func Uses(tag reflect.StructTag) bool {
+	for _, key := range TagKeys(tag) {
+		if key == "binary" || isKey(key) || misspelled(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+//misspelled returns the tag key looks like a misspelling of, an empty string if there is none. Short keys may be one
+// edit away, longer ones two.
+func misspelled(key string) string {
+	candidates := append(append([]string{"binary"}, Keys...), StdKeys...)
+	for _, k := range candidates {
+		if k == key {
+			return ""
+		}
+	}
+	for _, k := range candidates {
+		allowed := 1
+		if len(k) > 4 {
+			allowed = 2
+		}
+		if distance(strings.ToLower(key), k) <= allowed {
+			return k
+		}
+	}
+	return ""
+}
+
+//distance returns the number of insertions, deletions, substitutions and swaps of neighbouring letters that change a
+// into b.
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minOf(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minOf(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minOf(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
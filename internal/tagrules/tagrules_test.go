@@ -0,0 +1,43 @@
+package tagrules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagKeys(t *testing.T) {
+	tests := map[reflect.StructTag][]string{
+		``:                                  nil,
+		`bits:"4"`:                          {"bits"},
+		`json:"a,omitempty" endian:"big"`:   {"json", "endian"},
+		`binary:"bits=4,oneof=1 2" omit:""`: {"binary", "omit"},
+		`size:"a\"b" strlen:"3"`:            {"size", "strlen"},
+		`bits:"4" broken`:                   {"bits"},
+	}
+	for tag, expected := range tests {
+		if actual := TagKeys(tag); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("TagKeys(%q): expected %q but found %q", tag, expected, actual)
+		}
+	}
+}
+
+func TestMisspelled(t *testing.T) {
+	tests := map[string]string{
+		"endain": "endian",
+		"bist":   "bits",
+		"Size":   "size",
+		"strln":  "strlen",
+		"binray": "binary",
+		"bits":   "",
+		"json":   "",
+		"yaml":   "",
+		"xml":    "",
+		"mac":    "",
+		"tiem":   "time",
+	}
+	for key, expected := range tests {
+		if actual := misspelled(key); actual != expected {
+			t.Errorf("misspelled(%q): expected %q but found %q", key, expected, actual)
+		}
+	}
+}
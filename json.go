@@ -0,0 +1,508 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//BinaryToJSON decodes data into a new value of the type of prototype, a struct or a pointer to one, and returns it as
+// JSON. The fields are written in order by name, or by the name of their json tag, and differ from encoding/json in
+// the ways that keep the JSON readable and exact:
+//
+//   - byte arrays and slices are hex strings
+//   - enum types registered with RegisterEnum are their names, or numbers for values without a name
+//   - flags types registered with RegisterFlags are lists of the names of the set flags
+//   - floats that are not finite are the strings `NaN`, `+Inf` and `-Inf`
+//   - every field is written, json tags of `-` and omitempty do not leave fields out
+//   - unexported fields are named `_` and their name, blank fields `_` and their index, e.g. `_1`
+//
+// Types implementing json.Marshaler are written by it. JSONToBinary reads the JSON back, giving the same data, the
+// padding and reserved bits of blank fields included.
+func BinaryToJSON(data []byte, prototype interface{}, options ...EncDecOption) ([]byte, error) {
+	if data == nil {
+		return nil, fmt.Errorf("nil parameters not allowed")
+	}
+	buf, err := bits.NewFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	js, _, err := bitsToJSON(buf, prototype, 7, options...)
+	return js, err
+}
+
+//BitsToJSON is the same as BinaryToJSON but decodes from buf, also returning the number of bits decoded, so records
+// one after another in buf can be read in turn.
+func BitsToJSON(buf *bits.BitSetBuffer, prototype interface{}, options ...EncDecOption) ([]byte, int, error) {
+	return bitsToJSON(buf, prototype, 0, options...)
+}
+
+//bitsToJSON converts the value decoded from buf to JSON, padding is the number of trailing bits allowed with
+// DisallowTrailingData.
+func bitsToJSON(buf *bits.BitSetBuffer, prototype interface{}, padding int, options ...EncDecOption) ([]byte, int, error) {
+	t, err := prototypeType(prototype)
+	if err != nil {
+		return nil, 0, err
+	}
+	v := reflect.New(t)
+	hidden := newHiddenFields()
+	n, err := decodeToBits(buf, v.Interface(), padding, append(options[:len(options):len(options)], hidden, hidden.path)...)
+	if err != nil {
+		return nil, n, err
+	}
+
+	var out bytes.Buffer
+	if err := writeJSON(&out, v.Elem(), "", hidden); err != nil {
+		return nil, n, err
+	}
+	return out.Bytes(), n, nil
+}
+
+//JSONToBinary reads JSON written by BinaryToJSON, or the same written by hand, into a new value of the type of
+// prototype and returns it encoded. Missing fields are left as zero and unknown fields are an error. Flags may also be
+// given as a number, as can enums.
+func JSONToBinary(data []byte, prototype interface{}, options ...EncDecOption) ([]byte, error) {
+	t, err := prototypeType(prototype)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("more than one JSON value")
+	}
+
+	v := reflect.New(t)
+	hidden := newHiddenFields()
+	if err := readJSON(v.Elem(), x, "", hidden); err != nil {
+		return nil, err
+	}
+	return Encode(v.Interface(), append(options[:len(options):len(options)], hidden, hidden.path)...)
+}
+
+//prototypeType returns the struct type of prototype.
+func prototypeType(prototype interface{}) (reflect.Type, error) {
+	if prototype == nil {
+		return nil, fmt.Errorf("nil parameters not allowed")
+	}
+	t := reflect.TypeOf(prototype)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is not a struct", t)
+	}
+	return t, nil
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+//jsonName returns the name of the struct field in JSON.
+func jsonName(sf reflect.StructField) string {
+	switch {
+	case sf.Name == "_":
+		return "_" + strconv.Itoa(sf.Index[0])
+	case sf.PkgPath != "":
+		return "_" + sf.Name
+	}
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+//jsonFields returns the fields of the struct type t written to JSON.
+func jsonFields(t reflect.Type) []int {
+	var fields []int
+	for _, sf := range getStructInfo(t).fields {
+		fields = append(fields, sf.Index[0])
+	}
+	return fields
+}
+
+//hiddenFields holds the values of the unexported fields of the value converted to or from JSON by their path, e.g.
+// `Items[3]._1`. Decode can not set them and Encode would use what they hold, so the values are kept here instead.
+type hiddenFields struct {
+	values map[string]reflect.Value
+	//path is the path of the value being encoded or decoded, it is put in the options along with the hiddenFields
+	path *fieldPath
+}
+
+func newHiddenFields() *hiddenFields {
+	return &hiddenFields{values: map[string]reflect.Value{}, path: &fieldPath{}}
+}
+
+func (h *hiddenFields) Type() reflect.Type {
+	return nil
+}
+
+func (h *hiddenFields) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (h *hiddenFields) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (h *hiddenFields) setting() {}
+
+//hiddenKey returns the key of the unexported field sf of the struct at path.
+func hiddenKey(path string, sf reflect.StructField) string {
+	return joinPath(path, jsonName(sf))
+}
+
+//getHiddenFields returns the hiddenFields of the options, nil when there is none.
+func getHiddenFields(options []EncDecOption) *hiddenFields {
+	for _, option := range options {
+		if h, ok := option.(*hiddenFields); ok {
+			return h
+		}
+	}
+	return nil
+}
+
+//encodedField returns the value of the field sf of v to encode, for unexported fields the one held by the
+// hiddenFields of the options if any.
+func encodedField(v reflect.Value, sf reflect.StructField, options []EncDecOption) reflect.Value {
+	vf := v.Field(sf.Index[0])
+	if sf.PkgPath == "" {
+		return vf
+	}
+	if h := getHiddenFields(options); h != nil {
+		if value, has := h.values[hiddenKey(h.path.current(), sf)]; has {
+			return value
+		}
+	}
+	return vf
+}
+
+//bytesKind reports if t is an array or slice of bytes, which are written as hex.
+func bytesKind(t reflect.Type) bool {
+	if (t.Kind() != reflect.Array && t.Kind() != reflect.Slice) || t.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	_, flags := LookupFlags(t.Elem())
+	_, enum := LookupEnum(t.Elem())
+	return !flags && !enum
+}
+
+//writeJSON writes v, the value at path, as JSON to out with the values of unexported fields found in hidden.
+func writeJSON(out *bytes.Buffer, v reflect.Value, path string, hidden *hiddenFields) error {
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || (v.CanAddr() && reflect.PtrTo(t).Implements(jsonMarshalerType)) {
+		if v.Kind() != reflect.Ptr && v.CanAddr() {
+			v = v.Addr()
+		}
+		bs, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		out.Write(bs)
+		return nil
+	}
+
+	if bytesKind(t) {
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		bs := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(bs), v)
+		fmt.Fprintf(out, "%q", hex.EncodeToString(bs))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return writeJSON(out, v.Elem(), path, hidden)
+	case reflect.Struct:
+		out.WriteByte('{')
+		n := 0
+		for _, i := range jsonFields(t) {
+			vf := v.Field(i)
+			if t.Field(i).PkgPath != "" {
+				//only the unexported fields that were decoded have values to write
+				value, has := hidden.values[hiddenKey(path, t.Field(i))]
+				if !has {
+					continue
+				}
+				vf = value
+			}
+			if n > 0 {
+				out.WriteByte(',')
+			}
+			n++
+			name, _ := json.Marshal(jsonName(t.Field(i)))
+			out.Write(name)
+			out.WriteByte(':')
+			if err := writeJSON(out, vf, joinPath(path, t.Field(i).Name), hidden); err != nil {
+				return fmt.Errorf("%v: %v", t.Field(i).Name, err)
+			}
+		}
+		out.WriteByte('}')
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		out.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := writeJSON(out, v.Index(i), fmt.Sprintf("%v[%v]", path, i), hidden); err != nil {
+				return fmt.Errorf("[%v]: %v", i, err)
+			}
+		}
+		out.WriteByte(']')
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if names, has := LookupFlags(t); has {
+			flags := names.Names(v.Uint())
+			if reserved := names.Reserved(v.Uint()); reserved != 0 {
+				flags = append(flags, fmt.Sprintf("%#x", reserved))
+			}
+			bs, _ := json.Marshal(flags)
+			out.Write(bs)
+			return nil
+		}
+		if names, has := LookupEnum(t); has {
+			if name, has := names.Name(v.Uint()); has {
+				fmt.Fprintf(out, "%q", name)
+				return nil
+			}
+		}
+		out.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			out.WriteString(`"NaN"`)
+		case math.IsInf(f, 1):
+			out.WriteString(`"+Inf"`)
+		case math.IsInf(f, -1):
+			out.WriteString(`"-Inf"`)
+		default:
+			out.WriteString(strconv.FormatFloat(f, 'g', -1, t.Bits()))
+		}
+	case reflect.Bool, reflect.String:
+		bs, err := json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		out.Write(bs)
+	default:
+		return fmt.Errorf("%v not supported", t)
+	}
+	return nil
+}
+
+//readJSON sets v, the value at path, to x, a value decoded from JSON with numbers as json.Number. The values of
+// unexported fields are put in hidden.
+func readJSON(v reflect.Value, x interface{}, path string, hidden *hiddenFields) error {
+	t := v.Type()
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		bs, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(bs)
+	}
+
+	if x == nil {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+
+	if bytesKind(t) {
+		s, ok := x.(string)
+		if !ok {
+			return fmt.Errorf("expected a hex string but found %v", x)
+		}
+		bs, err := hex.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		if t.Kind() == reflect.Array {
+			if len(bs) != t.Len() {
+				return fmt.Errorf("expected %v bytes but found %v", t.Len(), len(bs))
+			}
+		} else {
+			v.Set(reflect.MakeSlice(t, len(bs), len(bs)))
+		}
+		reflect.Copy(v, reflect.ValueOf(bs))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(t.Elem()))
+		return readJSON(v.Elem(), x, path, hidden)
+	case reflect.Struct:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected an object but found %v", x)
+		}
+		known := map[string]bool{}
+		for _, i := range jsonFields(t) {
+			name := jsonName(t.Field(i))
+			known[name] = true
+			fx, has := m[name]
+			if !has {
+				continue
+			}
+			vf := v.Field(i)
+			if t.Field(i).PkgPath != "" {
+				vf = reflect.New(vf.Type()).Elem()
+				hidden.values[hiddenKey(path, t.Field(i))] = vf
+			}
+			if err := readJSON(vf, fx, joinPath(path, t.Field(i).Name), hidden); err != nil {
+				return fmt.Errorf("%v: %v", t.Field(i).Name, err)
+			}
+		}
+		for name := range m {
+			if !known[name] {
+				return fmt.Errorf("unknown field %v in %v", name, t)
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		items, ok := x.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected a list but found %v", x)
+		}
+		if v.Kind() == reflect.Array {
+			if len(items) != v.Len() {
+				return fmt.Errorf("expected %v items but found %v", v.Len(), len(items))
+			}
+		} else {
+			v.Set(reflect.MakeSlice(t, len(items), len(items)))
+		}
+		for i, item := range items {
+			if err := readJSON(v.Index(i), item, fmt.Sprintf("%v[%v]", path, i), hidden); err != nil {
+				return fmt.Errorf("[%v]: %v", i, err)
+			}
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := readUint(t, x)
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%v does not fit %v", u, t)
+		}
+		v.SetUint(u)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return fmt.Errorf("expected a number but found %v", x)
+		}
+		i, err := strconv.ParseInt(string(n), 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		var err error
+		switch x := x.(type) {
+		case json.Number:
+			f, err = strconv.ParseFloat(string(x), t.Bits())
+		case string:
+			switch x {
+			case "NaN":
+				f = math.NaN()
+			case "+Inf":
+				f = math.Inf(1)
+			case "-Inf":
+				f = math.Inf(-1)
+			default:
+				err = fmt.Errorf("expected a number but found %q", x)
+			}
+		default:
+			err = fmt.Errorf("expected a number but found %v", x)
+		}
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return fmt.Errorf("expected true or false but found %v", x)
+		}
+		v.SetBool(b)
+	case reflect.String:
+		s, ok := x.(string)
+		if !ok {
+			return fmt.Errorf("expected a string but found %v", x)
+		}
+		v.SetString(s)
+	default:
+		return fmt.Errorf("%v not supported", t)
+	}
+	return nil
+}
+
+//readUint returns the unsigned integer of type t in x. Flags may be a list of names and numbers, enums a name.
+func readUint(t reflect.Type, x interface{}) (uint64, error) {
+	switch x := x.(type) {
+	case json.Number:
+		return strconv.ParseUint(string(x), 10, t.Bits())
+	case string:
+		if names, has := LookupEnum(t); has {
+			if u, has := names.Value(x); has {
+				return u, nil
+			}
+		}
+		return 0, fmt.Errorf("%q is not a value of %v", x, t)
+	case []interface{}:
+		names, has := LookupFlags(t)
+		if !has {
+			break
+		}
+		var u uint64
+		for _, item := range x {
+			switch item := item.(type) {
+			case string:
+				if flag, has := names.Flag(item); has {
+					u |= flag
+					continue
+				}
+				bit, err := strconv.ParseUint(item, 0, t.Bits())
+				if err != nil {
+					return 0, fmt.Errorf("%q is not a flag of %v", item, t)
+				}
+				u |= bit
+			case json.Number:
+				bit, err := strconv.ParseUint(string(item), 10, t.Bits())
+				if err != nil {
+					return 0, err
+				}
+				u |= bit
+			default:
+				return 0, fmt.Errorf("%v is not a flag of %v", item, t)
+			}
+		}
+		return u, nil
+	}
+	return 0, fmt.Errorf("expected a number but found %v", x)
+}
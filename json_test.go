@@ -0,0 +1,206 @@
+package binary
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type jsonItem struct {
+	ID   uint16 `endian:"big"`
+	Data []byte `size:"2"`
+}
+
+type jsonMessage struct {
+	Version  uint8   `bits:"4"`
+	Reserved uint8   `bits:"1"`
+	Flags    ipFlags `bits:"3"`
+	Type     msgType
+	Types    [2]msgType
+	Offset   int16
+	Big      uint64
+	Ratio    float32
+	Scale    float64
+	Ok       bool
+	Name     string `strlen:"4"`
+	Hidden   uint8  `json:"-"`
+	Renamed  uint8  `json:"renamed,omitempty"`
+	Skipped  uint8  `omit:""`
+	Source   [4]byte
+	Next     *jsonItem
+	Items    []jsonItem `size:"2"`
+	Payload  []byte
+}
+
+var jsonInput = jsonMessage{
+	Version: 4, Reserved: 1, Flags: ipDontFrag | ipMoreFrag, Type: msgPong, Types: [2]msgType{msgData, 9},
+	Offset: -300, Big: math.MaxUint64, Ratio: float32(math.Inf(-1)), Scale: 0.1, Ok: true, Name: "abcd",
+	Hidden: 5, Renamed: 0, Source: [4]byte{10, 0, 0, 1}, Next: &jsonItem{ID: 1, Data: []byte{0xff, 0}},
+	Items:   []jsonItem{{ID: 2, Data: []byte{1, 2}}, {ID: 3, Data: []byte{3, 4}}},
+	Payload: []byte{0xde, 0xad, 0xbe, 0xef},
+}
+
+const jsonOutput = `{"Version":4,"Reserved":1,"Flags":["DontFrag","MoreFrag"],"Type":"Pong","Types":["Data",9],` +
+	`"Offset":-300,"Big":18446744073709551615,"Ratio":"-Inf","Scale":0.1,"Ok":true,"Name":"abcd","Hidden":5,` +
+	`"renamed":0,"Source":"0a000001","Next":{"ID":1,"Data":"ff00"},` +
+	`"Items":[{"ID":2,"Data":"0102"},{"ID":3,"Data":"0304"}],"Payload":"deadbeef"}`
+
+func TestBinaryToJSON(t *testing.T) {
+	data, err := Encode(&jsonInput)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	js, err := BinaryToJSON(data, jsonMessage{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if string(js) != jsonOutput {
+		t.Fatalf("expected\n%v\nbut found\n%s", jsonOutput, js)
+	}
+	if !json.Valid(js) {
+		t.Fatalf("expected valid JSON")
+	}
+
+	actual, err := JSONToBinary(js, &jsonMessage{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(data, actual) {
+		t.Fatalf("expected\n%x\nbut found\n%x", data, actual)
+	}
+}
+
+func TestJSONHiddenFields(t *testing.T) {
+	type inner struct {
+		A    uint8 `bits:"4"`
+		_    uint8 `bits:"4"`
+		kept uint8
+	}
+	type padded struct {
+		Version uint8 `bits:"4"`
+		_       uint8 `bits:"4"`
+		Items   [2]inner
+		_       uint16
+	}
+
+	//the blank and unexported fields hold bits Encode of a padded would not write
+	data := []byte{0xa4, 0x31, 7, 0xb2, 8, 0xcd, 0xab}
+	js, err := BinaryToJSON(data, padded{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expected := `{"Version":4,"_1":10,"Items":[{"A":1,"_1":3,"_kept":7},{"A":2,"_1":11,"_kept":8}],"_3":43981}`
+	if string(js) != expected {
+		t.Fatalf("expected\n%v\nbut found\n%s", expected, js)
+	}
+
+	actual, err := JSONToBinary(js, padded{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(data, actual) {
+		t.Fatalf("expected\n%x\nbut found\n%x", data, actual)
+	}
+
+	//left out they are zero
+	if actual, err = JSONToBinary([]byte(`{"Version":4}`), padded{}); err != nil || !reflect.DeepEqual([]byte{4, 0, 0, 0, 0, 0, 0}, actual) {
+		t.Fatalf("expected zeros but found %x: %v", actual, err)
+	}
+	if _, err := JSONToBinary([]byte(`{"_1":"x"}`), padded{}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestJSONHiddenFieldsUnsized(t *testing.T) {
+	type item struct {
+		A uint8 `bits:"4"`
+		_ uint8 `bits:"4"`
+	}
+	type message struct {
+		Items []item
+	}
+
+	//the items past the first ones made are appended, which moves the ones before them
+	var data []byte
+	for i := 1; i <= 12; i++ {
+		data = append(data, 0xf0|byte(i))
+	}
+	js, err := BinaryToJSON(data, message{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if n := strings.Count(string(js), `"_1":15`); n != 12 {
+		t.Fatalf("expected the padding of 12 items but found %v in %s", n, js)
+	}
+	actual, err := JSONToBinary(js, message{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(data, actual) {
+		t.Fatalf("expected\n%x\nbut found\n%x", data, actual)
+	}
+}
+
+func TestJSONToBinary(t *testing.T) {
+	type fixture struct {
+		Flags ipFlags
+		Type  msgType
+		Nan   float64
+		Ptr   *uint8
+		Bytes []byte
+	}
+
+	//flags and enums may be given as numbers, missing fields are zero
+	data, err := JSONToBinary([]byte(`{"Flags": [2, "MoreFrag"], "Type": 7, "Nan": "NaN", "Ptr": null}`), fixture{})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	var actual fixture
+	if err := Decode(data, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if actual.Flags != ipDontFrag|ipMoreFrag || actual.Type != msgData || !math.IsNaN(actual.Nan) || actual.Ptr == nil {
+		t.Fatalf("expected the fixture values but found %#v", actual)
+	}
+
+	tests := []string{
+		``,
+		`[]`,
+		`{} {}`,
+		`{"Unknown": 1}`,
+		`{"Flags": ["Nope"]}`,
+		`{"Flags": 1.5}`,
+		`{"Flags": [true]}`,
+		`{"Type": "Nope"}`,
+		`{"Type": 256}`,
+		`{"Nan": "nan"}`,
+		`{"Nan": true}`,
+		`{"Bytes": "xyz"}`,
+		`{"Bytes": [1, 2]}`,
+	}
+	for _, test := range tests {
+		if _, err := JSONToBinary([]byte(test), fixture{}); err == nil {
+			t.Fatalf("%q: expected an error", test)
+		}
+	}
+
+	for _, test := range []string{`{"Source": "0102"}`, `{"Items": [{"ID": -1}]}`, `{"Offset": 40000}`, `{"Ok": 1}`, `{"Name": 1}`, `{"Types": ["Data"]}`} {
+		_, err := JSONToBinary([]byte(test), jsonMessage{})
+		if err == nil {
+			t.Fatalf("%q: expected an error", test)
+		}
+	}
+
+	if _, err := JSONToBinary([]byte(`{}`), nil); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := BinaryToJSON([]byte{1}, 3); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := BinaryToJSON([]byte{1}, jsonMessage{}); err == nil || !strings.Contains(err.Error(), "Type") {
+		t.Fatalf("expected a decoding error but found %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package kaitai
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nathanhack/binary"
+	"github.com/nathanhack/binary/internal/bitfields"
+	"github.com/nathanhack/binary/internal/names"
+	"gopkg.in/yaml.v3"
+)
+
+//Export returns the .ksy spec of t, a tagged struct or a pointer to one. The options are the ones given to Encode and
+// Decode, values they handle can not be exported.
+//
+// Numbers that start on a byte boundary and use their whole width are exported as the Kaitai byte types (e.g.
+// `u2be`), all others as bit types (e.g. `b13`). Kaitai has no signed or big endian bit types, so these are read as
+// unsigned parts and combined in an instance with the name of the field.
+func Export(t reflect.Type, options ...binary.EncDecOption) ([]byte, error) {
+	schema, err := binary.Describe(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	e := exporter{types: map[string]*spec{}}
+	root := &spec{Meta: &meta{ID: names.Snake(schema.Name), BitEndian: "le"}}
+	if err := validID(root.Meta.ID); err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	if err := e.seq(root, schema.Fields, &pos, nil); err != nil {
+		return nil, err
+	}
+	if len(e.types) > 0 {
+		root.Types = e.types
+	}
+	return yaml.Marshal(root)
+}
+
+type exporter struct {
+	types map[string]*spec
+}
+
+//seq adds the fields to s. pos is the bit position within the current byte, -1 when it is not known. scopes are the
+// names of the fields decoded before, of the enclosing structs first.
+func (e *exporter) seq(s *spec, fields []binary.FieldSchema, pos *int, scopes [][]string) error {
+	scopes = append(scopes, nil)
+	for _, f := range fields {
+		id := names.Snake(f.Name)
+		attrs, instances, err := e.attr(id, f, pos, scopes)
+		if err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+		s.Seq = append(s.Seq, attrs...)
+		for name, inst := range instances {
+			if s.Instances == nil {
+				s.Instances = map[string]instance{}
+			}
+			s.Instances[name] = inst
+		}
+		scopes[len(scopes)-1] = append(scopes[len(scopes)-1], f.Name)
+	}
+	if s.Seq == nil {
+		s.Seq = []attr{}
+	}
+	return nil
+}
+
+//attr returns the attributes reading the field f and the instances needed to combine them.
+func (e *exporter) attr(id string, f binary.FieldSchema, pos *int, scopes [][]string) ([]attr, map[string]instance, error) {
+	if err := validID(id); err != nil {
+		return nil, nil, err
+	}
+
+	align := *pos
+	*pos = nextPos(align, f)
+
+	switch f.Kind {
+	case "bool", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		return e.number(id, f, align)
+	case "float32", "float64":
+		if align != 0 {
+			return nil, nil, fmt.Errorf("floats must start on a byte boundary")
+		}
+		a := attr{ID: id, Type: "f" + strconv.Itoa(*f.Bits/8) + endianSuffix(f.Endian), Valid: validOf(f, false)}
+		return []attr{a}, nil, nil
+	case "string":
+		if align != 0 {
+			return nil, nil, fmt.Errorf("strings must start on a byte boundary")
+		}
+		a := attr{ID: id, Type: "str", Encoding: "UTF-8", Valid: validOf(f, true)}
+		if err := setSize(&a, f.StrLen, scopes); err != nil {
+			return nil, nil, err
+		}
+		return []attr{a}, nil, nil
+	case "array", "slice":
+		count := f.Size
+		if f.Kind == "array" {
+			count = strconv.Itoa(f.Length)
+		}
+
+		//bytes are read in one go
+		if f.Elem.Kind == "uint8" && f.Elem.Bits != nil && *f.Elem.Bits == 8 && align == 0 && f.Elem.Min == "" &&
+			f.Elem.Max == "" && len(f.Elem.OneOf) == 0 {
+			a := attr{ID: id}
+			if err := setSize(&a, count, scopes); err != nil {
+				return nil, nil, err
+			}
+			return []attr{a}, nil, nil
+		}
+
+		//the items only share the alignment of the first when they are whole bytes
+		itemPos := align
+		if m, ok := modBits(*f.Elem); !ok || m != 0 {
+			itemPos = -1
+		}
+		items, instances, err := e.attr(id, *f.Elem, &itemPos, scopes)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(items) != 1 || len(instances) > 0 {
+			return nil, nil, fmt.Errorf("items of %v can not be exported, use a type Kaitai can read directly", f.Elem.Type)
+		}
+
+		a := items[0]
+		if count == "" {
+			a.Repeat = "eos"
+		} else {
+			expr, err := sizeExpr(count, scopes)
+			if err != nil {
+				return nil, nil, err
+			}
+			a.Repeat = "expr"
+			a.RepeatExpr = expr
+		}
+		return []attr{a}, nil, nil
+	case "struct":
+		if align != 0 {
+			return nil, nil, fmt.Errorf("structs must start on a byte boundary")
+		}
+		name := f.Type[strings.LastIndex(f.Type, ".")+1:]
+		if strings.HasPrefix(f.Type, "struct") {
+			name = f.Name
+		}
+		typeID := names.Snake(name)
+		if err := validID(typeID); err != nil {
+			return nil, nil, err
+		}
+
+		if _, has := e.types[typeID]; !has {
+			s := &spec{}
+			e.types[typeID] = s
+			start := 0
+			if err := e.seq(s, f.Fields, &start, scopes); err != nil {
+				return nil, nil, err
+			}
+		}
+		return []attr{{ID: id, Type: typeID}}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("%v of kind %v can not be exported", f.Type, f.Kind)
+}
+
+//number returns the attributes of an integer or bool starting at the bit position align.
+func (e *exporter) number(id string, f binary.FieldSchema, align int) ([]attr, map[string]instance, error) {
+	if f.BitsFrom != "" {
+		return nil, nil, fmt.Errorf("bits from the field %v can not be exported", f.BitsFrom)
+	}
+
+	width := *f.Bits
+	if width == 0 {
+		return nil, nil, nil
+	}
+	natural := bitfields.Natural(f.Kind)
+	signed := strings.HasPrefix(f.Kind, "int")
+	big := f.Endian == "big"
+	doc := flagsDoc(f)
+
+	//whole numbers on a byte boundary have a byte type
+	if align == 0 && width == natural {
+		a := attr{ID: id, Doc: doc, Valid: validOf(f, false)}
+		switch {
+		case f.Kind == "bool":
+			a.Type = "u1"
+			a.Doc = joinDoc("a bool, any value but 0 is true", doc)
+		case signed:
+			a.Type = "s" + strconv.Itoa(width/8)
+		default:
+			a.Type = "u" + strconv.Itoa(width/8)
+		}
+		if width > 8 {
+			a.Type += endianSuffix(f.Endian)
+		}
+		return []attr{a}, nil, nil
+	}
+
+	if !big || width <= 8 {
+		if !signed {
+			return []attr{{ID: id, Type: "b" + strconv.Itoa(width), Doc: doc, Valid: validOf(f, false)}}, nil, nil
+		}
+		raw := id + "_raw"
+		return []attr{{ID: raw, Type: "b" + strconv.Itoa(width)}}, map[string]instance{
+			id: {Value: signExpr(raw, width), Doc: doc},
+		}, nil
+	}
+
+	if signed && width == 64 {
+		return nil, nil, fmt.Errorf("big endian 64 bit signed numbers must start on a byte boundary")
+	}
+
+	raw := func(i int) string { return fmt.Sprintf("%v_raw%v", id, i) }
+	parts, value := bitfields.BigEndian(width, raw)
+	attrs := make([]attr, 0, len(parts))
+	for i, n := range parts {
+		attrs = append(attrs, attr{ID: raw(i), Type: "b" + strconv.Itoa(n)})
+	}
+	if signed {
+		value = signExpr("("+value+")", width)
+	}
+	return attrs, map[string]instance{id: {Value: value, Doc: doc}}, nil
+}
+
+//nextPos returns the bit position within the byte after f, which starts at pos.
+func nextPos(pos int, f binary.FieldSchema) int {
+	if pos < 0 {
+		return -1
+	}
+	m, ok := modBits(f)
+	if !ok {
+		return -1
+	}
+	return (pos + m) % 8
+}
+
+//modBits returns the size of f modulo 8.
+func modBits(f binary.FieldSchema) (int, bool) {
+	if f.Bits != nil {
+		return *f.Bits % 8, true
+	}
+
+	switch f.Kind {
+	case "string":
+		return 0, true
+	case "slice":
+		if m, ok := modBits(*f.Elem); ok && m == 0 {
+			return 0, true
+		}
+	case "array":
+		if m, ok := modBits(*f.Elem); ok {
+			return m * f.Length % 8, true
+		}
+	case "struct":
+		total := 0
+		for _, field := range f.Fields {
+			m, ok := modBits(field)
+			if !ok {
+				return 0, false
+			}
+			total += m
+		}
+		return total % 8, true
+	}
+	return 0, false
+}
+
+//setSize sets the size of a, from a `size` or `strlen` tag.
+func setSize(a *attr, size string, scopes [][]string) error {
+	if size == "" {
+		a.SizeEOS = true
+		return nil
+	}
+	expr, err := sizeExpr(size, scopes)
+	if err != nil {
+		return err
+	}
+	a.Size = expr
+	return nil
+}
+
+//sizeExpr returns the Kaitai expression of a size, either a number or the name of a field decoded before.
+func sizeExpr(size string, scopes [][]string) (interface{}, error) {
+	if n, err := strconv.ParseUint(size, 10, 63); err == nil {
+		return int(n), nil
+	}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for _, name := range scopes[i] {
+			if name == size {
+				return strings.Repeat("_parent.", len(scopes)-1-i) + names.Snake(name), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("size %v is not a field decoded before", size)
+}
+
+func signExpr(raw string, width int) string {
+	return fmt.Sprintf("%v >= %v ? %v - %v : %v", raw, uint64(1)<<uint(width-1), raw, uint64(1)<<uint(width), raw)
+}
+
+func endianSuffix(endian string) string {
+	if endian == "big" {
+		return "be"
+	}
+	return "le"
+}
+
+//validOf returns the Kaitai valid of the min, max and oneof conditions.
+func validOf(f binary.FieldSchema, quote bool) *valid {
+	value := func(s string) interface{} {
+		if quote {
+			return strconv.Quote(s)
+		}
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return int(n)
+		}
+		return s
+	}
+
+	if f.Min == "" && f.Max == "" && len(f.OneOf) == 0 {
+		return nil
+	}
+	v := &valid{}
+	if f.Min != "" {
+		v.Min = value(f.Min)
+	}
+	if f.Max != "" {
+		v.Max = value(f.Max)
+	}
+	for _, s := range f.OneOf {
+		v.AnyOf = append(v.AnyOf, value(s))
+	}
+	return v
+}
+
+func flagsDoc(f binary.FieldSchema) string {
+	if len(f.Flags) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(f.Flags))
+	for _, flag := range f.Flags {
+		names = append(names, fmt.Sprintf("%v (bit %v)", flag.Name, flag.Bit))
+	}
+	return "flags: " + strings.Join(names, ", ")
+}
+
+func joinDoc(docs ...string) string {
+	var parts []string
+	for _, d := range docs {
+		if d != "" {
+			parts = append(parts, d)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
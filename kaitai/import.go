@@ -0,0 +1,437 @@
+package kaitai
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nathanhack/binary/internal/names"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	byteTypeRe  = regexp.MustCompile(`^([us])([1248])(le|be)?$`)
+	floatTypeRe = regexp.MustCompile(`^f([48])(le|be)?$`)
+	bitTypeRe   = regexp.MustCompile(`^b([0-9]+)(le|be)?$`)
+	refRe       = regexp.MustCompile(`^(_parent\.)*([a-z][a-z0-9_]*)$`)
+)
+
+//Import returns the Go source of tagged structs decoding the same data as the .ksy spec. The struct of the spec is
+// named after its id and the structs of its types after theirs, all in package pkg.
+//
+// Only specs where every size is a number or a field decoded before can be imported, and bit types must use
+// `bit-endian: le`. Blank padding fields are added where Kaitai aligns to the next byte after bit types. Instances and
+// enums are left out, their values are the fields they are computed from.
+func Import(ksy []byte, pkg string) ([]byte, error) {
+	var root spec
+	if err := yaml.Unmarshal(ksy, &root); err != nil {
+		return nil, err
+	}
+	if root.Meta == nil || root.Meta.ID == "" {
+		return nil, fmt.Errorf("meta/id is missing")
+	}
+
+	im := importer{meta: *root.Meta, types: map[string]*spec{}}
+	if err := im.collect(&root); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by kaitai.Import from %v. DO NOT EDIT.\n\npackage %v\n", root.Meta.ID, pkg)
+	if err := im.write(&out, root.Meta.ID, &root); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(im.types))
+	for name := range im.types {
+		ids = append(ids, name)
+	}
+	sort.Strings(ids)
+	for _, name := range ids {
+		if err := im.write(&out, name, im.types[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	return format.Source(out.Bytes())
+}
+
+type importer struct {
+	meta  meta
+	types map[string]*spec
+}
+
+//collect adds the types of s, and the types within them, to the importer.
+func (im *importer) collect(s *spec) error {
+	for name, t := range s.Types {
+		if _, has := im.types[name]; has {
+			return fmt.Errorf("the type %v is defined more than once", name)
+		}
+		if name == im.meta.ID {
+			return fmt.Errorf("the type %v has the same name as the spec", name)
+		}
+		im.types[name] = t
+		if err := im.collect(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//write writes the struct of the spec s named id.
+func (im *importer) write(out *bytes.Buffer, id string, s *spec) error {
+	fmt.Fprintln(out)
+	if s.Doc != "" {
+		writeComment(out, "", s.Doc)
+	}
+	if len(s.Instances) > 0 {
+		ids := make([]string, 0, len(s.Instances))
+		for name := range s.Instances {
+			ids = append(ids, name)
+		}
+		sort.Strings(ids)
+		fmt.Fprintf(out, "//%v leaves out the instances %v.\n", names.Camel(id), strings.Join(ids, ", "))
+	}
+	fmt.Fprintf(out, "type %v struct {\n", names.Camel(id))
+	offset := 0
+	for _, a := range s.Seq {
+		field, err := im.field(a)
+		if err != nil {
+			return fmt.Errorf("%v.%v: %v", id, a.ID, err)
+		}
+		padding, next, err := im.advance(a, offset, map[string]bool{})
+		if err != nil {
+			return fmt.Errorf("%v.%v: %v", id, a.ID, err)
+		}
+		offset = next
+		if padding > 0 {
+			fmt.Fprintf(out, "\t_ uint8 `bits:\"%v\"`\n", padding)
+		}
+		if a.Doc != "" {
+			writeComment(out, "\t", a.Doc)
+		}
+		fmt.Fprintf(out, "\t%v\n", field)
+	}
+	fmt.Fprintln(out, "}")
+	return nil
+}
+
+//advance returns the bits of padding needed before a, which starts offset bits into a byte, and how many bits into a
+// byte it ends. Kaitai aligns everything but bit types to the next byte, the padding does the same for the Go struct.
+// visiting are the types a is within.
+func (im *importer) advance(a attr, offset int, visiting map[string]bool) (padding, next int, err error) {
+	m := bitTypeRe.FindStringSubmatch(a.Type)
+	if m == nil {
+		if offset > 0 {
+			padding = 8 - offset
+		}
+		if _, has := im.types[a.Type]; has {
+			end, err := im.end(a.Type, visiting)
+			if err != nil {
+				return 0, 0, err
+			}
+			if end > 0 {
+				return 0, 0, fmt.Errorf("the type %v ends in the middle of a byte, which is not supported", a.Type)
+			}
+		}
+		return padding, 0, nil
+	}
+
+	n, _ := strconv.Atoi(m[1])
+	switch a.Repeat {
+	case "":
+	case "expr":
+		count, literal, err := importExpr(a.RepeatExpr)
+		if err != nil {
+			return 0, 0, err
+		}
+		if literal {
+			c, _ := strconv.Atoi(count)
+			n *= c
+		} else if n%8 != 0 {
+			return 0, 0, fmt.Errorf("repeating %v by a field ends in the middle of a byte, which is not supported", a.Type)
+		}
+	default:
+		if n%8 != 0 {
+			return 0, 0, fmt.Errorf("repeating %v until the end ends in the middle of a byte, which is not supported", a.Type)
+		}
+	}
+	return 0, (offset + n) % 8, nil
+}
+
+//end returns how many bits into a byte the type name ends, visiting are the types it is within.
+func (im *importer) end(name string, visiting map[string]bool) (int, error) {
+	if visiting[name] {
+		//a type holding itself ends where it does, which is checked by the outermost call
+		return 0, nil
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	offset := 0
+	for _, a := range im.types[name].Seq {
+		_, next, err := im.advance(a, offset, visiting)
+		if err != nil {
+			return 0, fmt.Errorf("%v.%v: %v", name, a.ID, err)
+		}
+		offset = next
+	}
+	return offset, nil
+}
+
+//field returns the Go field declaration of a.
+func (im *importer) field(a attr) (string, error) {
+	switch {
+	case a.Contents != nil:
+		return "", fmt.Errorf("contents is not supported")
+	case a.Terminator != nil:
+		return "", fmt.Errorf("terminator is not supported")
+	case a.If != "":
+		return "", fmt.Errorf("if is not supported")
+	case a.Process != "":
+		return "", fmt.Errorf("process is not supported")
+	case a.RepeatUntil != "":
+		return "", fmt.Errorf("repeat-until is not supported")
+	}
+	if err := validID(a.ID); err != nil {
+		return "", err
+	}
+
+	var tags []string
+	goType := ""
+	switch {
+	case a.Type == "" || a.Type == "str":
+		if a.Repeat != "" {
+			return "", fmt.Errorf("repeated sizes are not supported")
+		}
+		goType = "string"
+		key := "strlen"
+		if a.Type == "" {
+			goType = "[]byte"
+			key = "size"
+		}
+		switch {
+		case a.SizeEOS:
+		case a.Size != nil:
+			size, literal, err := importExpr(a.Size)
+			if err != nil {
+				return "", err
+			}
+			if literal && a.Type == "" {
+				goType = "[" + size + "]byte"
+			} else {
+				tags = append(tags, fmt.Sprintf(`%v:"%v"`, key, size))
+			}
+		default:
+			return "", fmt.Errorf("size or size-eos is needed")
+		}
+	default:
+		if a.Size != nil || a.SizeEOS {
+			return "", fmt.Errorf("sizes of %v are not supported", a.Type)
+		}
+		t, typeTags, err := im.typeOf(a.Type)
+		if err != nil {
+			return "", err
+		}
+		goType = t
+		tags = append(tags, typeTags...)
+	}
+
+	switch a.Repeat {
+	case "":
+	case "eos":
+		goType = "[]" + goType
+	case "expr":
+		size, literal, err := importExpr(a.RepeatExpr)
+		if err != nil {
+			return "", err
+		}
+		if literal {
+			goType = "[" + size + "]" + goType
+		} else {
+			goType = "[]" + goType
+			tags = append(tags, fmt.Sprintf(`size:"%v"`, size))
+		}
+	default:
+		return "", fmt.Errorf("repeat %v is not supported", a.Repeat)
+	}
+
+	validTags, err := importValid(a.Valid)
+	if err != nil {
+		return "", err
+	}
+	tags = append(tags, validTags...)
+
+	field := names.Camel(a.ID) + " " + goType
+	if len(tags) > 0 {
+		field += " `" + strings.Join(tags, " ") + "`"
+	}
+	return field, nil
+}
+
+//typeOf returns the Go type and tags of a Kaitai type.
+func (im *importer) typeOf(t string) (string, []string, error) {
+	endian := func(suffix string) (string, error) {
+		if suffix == "" {
+			suffix = im.meta.Endian
+		}
+		switch suffix {
+		case "le":
+			return "", nil
+		case "be":
+			return `endian:"big"`, nil
+		}
+		return "", fmt.Errorf("%v needs an endian", t)
+	}
+
+	if m := byteTypeRe.FindStringSubmatch(t); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		goType := fmt.Sprintf("int%v", 8*n)
+		if m[1] == "u" {
+			goType = "u" + goType
+		}
+		if n == 1 {
+			return goType, nil, nil
+		}
+		tag, err := endian(m[3])
+		if err != nil || tag == "" {
+			return goType, nil, err
+		}
+		return goType, []string{tag}, nil
+	}
+
+	if m := floatTypeRe.FindStringSubmatch(t); m != nil {
+		goType := "float32"
+		if m[1] == "8" {
+			goType = "float64"
+		}
+		tag, err := endian(m[2])
+		if err != nil || tag == "" {
+			return goType, nil, err
+		}
+		return goType, []string{tag}, nil
+	}
+
+	if m := bitTypeRe.FindStringSubmatch(t); m != nil {
+		bitEndian := m[2]
+		if bitEndian == "" {
+			bitEndian = im.meta.BitEndian
+		}
+		if bitEndian != "le" {
+			return "", nil, fmt.Errorf("%v: only bit-endian le is supported", t)
+		}
+
+		n, _ := strconv.Atoi(m[1])
+		goType := ""
+		switch {
+		case n == 1:
+			goType = "bool"
+		case n <= 8:
+			goType = "uint8"
+		case n <= 16:
+			goType = "uint16"
+		case n <= 32:
+			goType = "uint32"
+		case n <= 64:
+			goType = "uint64"
+		default:
+			return "", nil, fmt.Errorf("%v is larger than 64 bits", t)
+		}
+		return goType, []string{fmt.Sprintf(`bits:"%v"`, n)}, nil
+	}
+
+	if _, has := im.types[t]; has {
+		return names.Camel(t), nil, nil
+	}
+	return "", nil, fmt.Errorf("type %v is not supported", t)
+}
+
+//importExpr returns the size of a Kaitai expression, either a number or the Go name of a field. literal is true for
+// numbers.
+func importExpr(expr interface{}) (size string, literal bool, err error) {
+	switch e := expr.(type) {
+	case int:
+		if e < 0 {
+			return "", false, fmt.Errorf("size %v is negative", e)
+		}
+		return strconv.Itoa(e), true, nil
+	case string:
+		if n, err := strconv.ParseUint(e, 0, 63); err == nil {
+			return strconv.FormatUint(n, 10), true, nil
+		}
+		if m := refRe.FindStringSubmatch(strings.TrimSpace(e)); m != nil {
+			return names.Camel(m[2]), false, nil
+		}
+	}
+	return "", false, fmt.Errorf("expression %v is not supported, only numbers and fields are", expr)
+}
+
+//importValid returns the min, max and oneof tags of v.
+func importValid(v *valid) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	value := func(x interface{}) (string, error) {
+		switch x := x.(type) {
+		case int:
+			return strconv.Itoa(x), nil
+		case uint64:
+			return strconv.FormatUint(x, 10), nil
+		case float64:
+			return strconv.FormatFloat(x, 'g', -1, 64), nil
+		case string:
+			if s, err := strconv.Unquote(x); err == nil && !strings.ContainsAny(s, "\"`") {
+				return s, nil
+			}
+			if _, err := strconv.ParseInt(x, 0, 64); err == nil {
+				return x, nil
+			}
+		}
+		return "", fmt.Errorf("valid %v is not supported, only numbers and strings are", x)
+	}
+
+	var tags []string
+	var oneof []string
+	for _, item := range append([]interface{}{v.Eq}, v.AnyOf...) {
+		if item == nil {
+			continue
+		}
+		s, err := value(item)
+		if err != nil {
+			return nil, err
+		}
+		if strings.ContainsAny(s, " \"`") {
+			return nil, fmt.Errorf("valid %q can not be a oneof tag", s)
+		}
+		oneof = append(oneof, s)
+	}
+	if v.Min != nil {
+		s, err := value(v.Min)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, fmt.Sprintf(`min:"%v"`, s))
+	}
+	if v.Max != nil {
+		s, err := value(v.Max)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, fmt.Sprintf(`max:"%v"`, s))
+	}
+	if len(oneof) > 0 {
+		tags = append(tags, fmt.Sprintf(`oneof:"%v"`, strings.Join(oneof, " ")))
+	}
+	return tags, nil
+}
+
+func writeComment(out *bytes.Buffer, indent, doc string) {
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		fmt.Fprintf(out, "%v//%v\n", indent, line)
+	}
+}
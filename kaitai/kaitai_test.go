@@ -0,0 +1,391 @@
+package kaitai
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nathanhack/binary"
+)
+
+type ipFlags uint8
+
+var _ = binary.RegisterFlags(ipFlags(0), "", "DontFrag", "MoreFrag")
+
+type ipv4Header struct {
+	Version        uint8   `bits:"4" oneof:"4"`
+	IHL            uint8   `bits:"4" min:"5"`
+	DSCP           uint8   `bits:"6"`
+	ECN            uint8   `bits:"2"`
+	TotalLength    uint16  `endian:"big"`
+	Identification uint16  `endian:"big"`
+	Flags          ipFlags `bits:"3"`
+	FragOffset     uint16  `bits:"13" endian:"big"`
+	TTL            uint8
+	Protocol       uint8
+	Checksum       uint16 `endian:"big"`
+	Source         [4]byte
+	Destination    [4]byte
+}
+
+const ipv4KSY = `meta:
+    id: ipv4_header
+    bit-endian: le
+seq:
+    - id: version
+      type: b4
+      valid:
+        any-of:
+            - 4
+    - id: ihl
+      type: b4
+      valid:
+        min: 5
+    - id: dscp
+      type: b6
+    - id: ecn
+      type: b2
+    - id: total_length
+      type: u2be
+    - id: identification
+      type: u2be
+    - id: flags
+      type: b3
+      doc: 'flags: DontFrag (bit 1), MoreFrag (bit 2)'
+    - id: frag_offset_raw0
+      type: b5
+    - id: frag_offset_raw1
+      type: b8
+    - id: ttl
+      type: u1
+    - id: protocol
+      type: u1
+    - id: checksum
+      type: u2be
+    - id: source
+      size: 4
+    - id: destination
+      size: 4
+instances:
+    frag_offset:
+        value: (frag_offset_raw0 << 8) | frag_offset_raw1
+`
+
+const ipv4Go = "// Code generated by kaitai.Import from ipv4_header. DO NOT EDIT.\n" + `
+package gen
+
+// Ipv4Header leaves out the instances frag_offset.
+type Ipv4Header struct {
+	Version        uint8  ` + "`bits:\"4\" oneof:\"4\"`" + `
+	Ihl            uint8  ` + "`bits:\"4\" min:\"5\"`" + `
+	Dscp           uint8  ` + "`bits:\"6\"`" + `
+	Ecn            uint8  ` + "`bits:\"2\"`" + `
+	TotalLength    uint16 ` + "`endian:\"big\"`" + `
+	Identification uint16 ` + "`endian:\"big\"`" + `
+	//flags: DontFrag (bit 1), MoreFrag (bit 2)
+	Flags          uint8 ` + "`bits:\"3\"`" + `
+	FragOffsetRaw0 uint8 ` + "`bits:\"5\"`" + `
+	FragOffsetRaw1 uint8 ` + "`bits:\"8\"`" + `
+	Ttl            uint8
+	Protocol       uint8
+	Checksum       uint16 ` + "`endian:\"big\"`" + `
+	Source         [4]byte
+	Destination    [4]byte
+}
+`
+
+type item struct {
+	ID   uint16
+	Data []byte `size:"Count"`
+}
+
+type message struct {
+	Kind   string `strlen:"3" oneof:"abc xyz"`
+	Count  uint8
+	Delta  int8   `bits:"5"`
+	Signed int16  `bits:"11" endian:"big"`
+	Items  []item `size:"Count"`
+	Values []int32
+}
+
+func TestExport(t *testing.T) {
+	actual, err := Export(reflect.TypeOf(ipv4Header{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if string(actual) != ipv4KSY {
+		t.Fatalf("expected \n%v\n but found \n%v\n", ipv4KSY, string(actual))
+	}
+
+	actual, err = Export(reflect.TypeOf(&message{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	for _, expected := range []string{
+		"type: str\n      size: 3\n      encoding: UTF-8",
+		`- '"abc"'`,
+		"- id: delta_raw\n      type: b5",
+		"value: 'delta_raw >= 16 ? delta_raw - 32 : delta_raw'",
+		"type: item\n      repeat: expr\n      repeat-expr: count",
+		"type: s4le\n      repeat: eos",
+		"size: _parent.count",
+	} {
+		if !strings.Contains(string(actual), expected) {
+			t.Fatalf("expected %q in \n%v", expected, string(actual))
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	actual, err := Import([]byte(ipv4KSY), "gen")
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if string(actual) != ipv4Go {
+		t.Fatalf("expected \n%v\n but found \n%v\n", ipv4Go, string(actual))
+	}
+
+	//the imported struct reads the same bits as the original
+	original := ipv4Header{Version: 4, IHL: 5, TotalLength: 20, Flags: 2, FragOffset: 0x1234, TTL: 64, Protocol: 6,
+		Source: [4]byte{10, 0, 0, 1}, Destination: [4]byte{10, 0, 0, 2}}
+	expected, err := binary.Encode(original)
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+	imported := reflect.New(generatedTypes(t, actual)["Ipv4Header"])
+	if err := binary.Decode(expected, imported.Interface()); err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	fields := imported.Elem()
+	if fields.FieldByName("FragOffsetRaw0").Uint() != 0x12 || fields.FieldByName("FragOffsetRaw1").Uint() != 0x34 ||
+		fields.FieldByName("Ttl").Uint() != 64 {
+		t.Fatalf("expected the original values but found %#v", fields.Interface())
+	}
+	bs, err := binary.Encode(imported.Interface())
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+	if !bytes.Equal(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+}
+
+func TestImportAlignment(t *testing.T) {
+	ksy := `
+meta:
+  id: packet
+  bit-endian: le
+seq:
+  - id: kind
+    type: b3
+  - id: length
+    type: u1
+  - id: flags
+    type: b2
+  - id: level
+    type: b2
+  - id: body
+    size: 2
+  - id: pair
+    type: pair
+types:
+  pair:
+    seq:
+      - id: low
+        type: b4
+      - id: high
+        type: b4
+`
+	actual, err := Import([]byte(ksy), "gen")
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	for _, expected := range []string{
+		"Kind   uint8 `bits:\"3\"`\n\t_      uint8 `bits:\"5\"`\n\tLength uint8\n",
+		"Level  uint8 `bits:\"2\"`\n\t_      uint8 `bits:\"4\"`\n\tBody   [2]byte\n",
+	} {
+		if !strings.Contains(string(actual), expected) {
+			t.Fatalf("expected %q in \n%v", expected, string(actual))
+		}
+	}
+
+	//Kaitai reads length from the second byte and body from the fourth
+	data := []byte{0x05, 0x7f, 0x0e, 0xaa, 0xbb, 0x21}
+	packet := reflect.New(generatedTypes(t, actual)["Packet"])
+	if err := binary.Decode(data, packet.Interface()); err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	fields := packet.Elem()
+	if fields.FieldByName("Kind").Uint() != 5 || fields.FieldByName("Length").Uint() != 0x7f ||
+		fields.FieldByName("Flags").Uint() != 2 || fields.FieldByName("Level").Uint() != 3 ||
+		!bytes.Equal(fields.FieldByName("Body").Slice(0, 2).Bytes(), []byte{0xaa, 0xbb}) ||
+		fields.FieldByName("Pair").FieldByName("High").Uint() != 2 {
+		t.Fatalf("expected the values Kaitai reads but found %#v", fields.Interface())
+	}
+
+	//a type holding itself is followed through once
+	tree := "meta: {id: tree}\nseq: [{id: n, type: u1}, {id: kids, type: tree_node, repeat: expr, repeat-expr: n}]\n" +
+		"types: {tree_node: {seq: [{id: n, type: u1}, {id: kids, type: tree_node, repeat: expr, repeat-expr: n}]}}"
+	if _, err := Import([]byte(tree), "gen"); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+}
+
+//generatedTypes returns the struct types declared in the Go source src, built with reflect so data can be decoded
+// with them. Only the types Import generates are handled.
+func generatedTypes(t *testing.T, src []byte) map[string]reflect.Type {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("expected no parse error found: %v", err)
+	}
+
+	specs := map[string]*ast.StructType{}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				specs[ts.Name.Name] = ts.Type.(*ast.StructType)
+			}
+		}
+	}
+
+	basic := map[string]reflect.Type{
+		"bool": reflect.TypeOf(false), "string": reflect.TypeOf(""), "byte": reflect.TypeOf(byte(0)),
+		"uint8": reflect.TypeOf(uint8(0)), "uint16": reflect.TypeOf(uint16(0)), "uint32": reflect.TypeOf(uint32(0)),
+		"uint64": reflect.TypeOf(uint64(0)), "int8": reflect.TypeOf(int8(0)), "int16": reflect.TypeOf(int16(0)),
+		"int32": reflect.TypeOf(int32(0)), "int64": reflect.TypeOf(int64(0)),
+		"float32": reflect.TypeOf(float32(0)), "float64": reflect.TypeOf(float64(0)),
+	}
+	types := map[string]reflect.Type{}
+	var typeOf func(expr ast.Expr) reflect.Type
+	typeOf = func(expr ast.Expr) reflect.Type {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			if b, has := basic[e.Name]; has {
+				return b
+			}
+			if built, has := types[e.Name]; has {
+				return built
+			}
+			var fields []reflect.StructField
+			for _, f := range specs[e.Name].Fields.List {
+				tag := ""
+				if f.Tag != nil {
+					tag, _ = strconv.Unquote(f.Tag.Value)
+				}
+				for _, name := range f.Names {
+					sf := reflect.StructField{Name: name.Name, Type: typeOf(f.Type), Tag: reflect.StructTag(tag)}
+					if !name.IsExported() {
+						sf.PkgPath = file.Name.Name
+					}
+					fields = append(fields, sf)
+				}
+			}
+			types[e.Name] = reflect.StructOf(fields)
+			return types[e.Name]
+		case *ast.ArrayType:
+			if e.Len == nil {
+				return reflect.SliceOf(typeOf(e.Elt))
+			}
+			n, err := strconv.Atoi(e.Len.(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatalf("expected no error found: %v", err)
+			}
+			return reflect.ArrayOf(n, typeOf(e.Elt))
+		}
+		t.Fatalf("unexpected type %#v", expr)
+		return nil
+	}
+	for name := range specs {
+		typeOf(ast.NewIdent(name))
+	}
+	return types
+}
+
+func TestImportTypes(t *testing.T) {
+	ksy := `
+meta:
+  id: packet
+  endian: be
+seq:
+  - id: magic
+    type: u4
+    valid:
+      eq: 0x1234
+  - id: len_name
+    type: u1
+  - id: name
+    type: str
+    size: len_name
+    encoding: ASCII
+  - id: ratio
+    type: f4le
+  - id: entries
+    type: entry
+    repeat: expr
+    repeat-expr: 2
+  - id: rest
+    size-eos: true
+types:
+  entry:
+    seq:
+      - id: value
+        type: s2
+      - id: body
+        size: _parent.len_name
+`
+	actual, err := Import([]byte(ksy), "gen")
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	for _, expected := range []string{
+		"Magic   uint32 `endian:\"big\" oneof:\"4660\"`",
+		"Name    string `strlen:\"LenName\"`",
+		"Ratio   float32\n",
+		"Entries [2]Entry",
+		"Rest    []byte",
+		"Value int16  `endian:\"big\"`",
+		"Body  []byte `size:\"LenName\"`",
+	} {
+		if !strings.Contains(string(actual), expected) {
+			t.Fatalf("expected %q in \n%v", expected, string(actual))
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	type unaligned struct {
+		A uint8  `bits:"3"`
+		B string `strlen:"2"`
+	}
+	type sizedByBits struct {
+		N uint8
+		V uint32 `bits:"N"`
+	}
+	for _, v := range []interface{}{unaligned{}, sizedByBits{}, struct{ I interface{} }{}} {
+		if _, err := Export(reflect.TypeOf(v)); err == nil {
+			t.Fatalf("%T: expected an error", v)
+		}
+	}
+
+	for _, ksy := range []string{
+		"seq: []",
+		"meta: {id: a}\nseq: [{id: b, type: b3}]",
+		"meta: {id: a, bit-endian: le}\nseq: [{id: b, type: u1}, {id: c, size: b * 2}]",
+		"meta: {id: a}\nseq: [{id: b, contents: [1, 2]}]",
+		"meta: {id: a}\nseq: [{id: b, type: u2}]",
+		"meta: {id: a}\nseq: [{id: b, type: unknown}]",
+		"meta: {id: a, bit-endian: le}\nseq: [{id: n, type: u1}, {id: b, type: b3, repeat: expr, repeat-expr: n}]",
+		"meta: {id: a, bit-endian: le}\nseq: [{id: b, type: c}]\ntypes: {c: {seq: [{id: d, type: b3}]}}",
+	} {
+		if _, err := Import([]byte(ksy), "gen"); err == nil {
+			t.Fatalf("%v: expected an error", ksy)
+		}
+	}
+}
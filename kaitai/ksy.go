@@ -0,0 +1,73 @@
+//Package kaitai converts between tagged structs and Kaitai Struct (.ksy) specs. Export writes the spec of a struct
+// so it can be used with the Kaitai visualizers and compilers, Import generates tagged structs from a spec.
+//
+// Both directions use the parts of Kaitai that match how this library lays out bits: bits are packed starting at
+// the least significant bit of each byte (`bit-endian: le`) and values are not aligned to bytes.
+package kaitai
+
+import (
+	"fmt"
+)
+
+//spec is a .ksy file or one of the types within it.
+type spec struct {
+	Meta      *meta                  `yaml:"meta,omitempty"`
+	Doc       string                 `yaml:"doc,omitempty"`
+	Seq       []attr                 `yaml:"seq"`
+	Instances map[string]instance    `yaml:"instances,omitempty"`
+	Types     map[string]*spec       `yaml:"types,omitempty"`
+	Enums     map[string]interface{} `yaml:"enums,omitempty"`
+}
+
+type meta struct {
+	ID        string `yaml:"id"`
+	Endian    string `yaml:"endian,omitempty"`
+	BitEndian string `yaml:"bit-endian,omitempty"`
+}
+
+type attr struct {
+	ID         string      `yaml:"id"`
+	Type       string      `yaml:"type,omitempty"`
+	Size       interface{} `yaml:"size,omitempty"`
+	SizeEOS    bool        `yaml:"size-eos,omitempty"`
+	Encoding   string      `yaml:"encoding,omitempty"`
+	Repeat     string      `yaml:"repeat,omitempty"`
+	RepeatExpr interface{} `yaml:"repeat-expr,omitempty"`
+	Valid      *valid      `yaml:"valid,omitempty"`
+	Doc        string      `yaml:"doc,omitempty"`
+
+	//the keys below are not supported by Import, they are read so it can say so
+	Contents    interface{} `yaml:"contents,omitempty"`
+	Terminator  interface{} `yaml:"terminator,omitempty"`
+	If          string      `yaml:"if,omitempty"`
+	Process     string      `yaml:"process,omitempty"`
+	RepeatUntil string      `yaml:"repeat-until,omitempty"`
+}
+
+type valid struct {
+	Eq    interface{}   `yaml:"eq,omitempty"`
+	Min   interface{}   `yaml:"min,omitempty"`
+	Max   interface{}   `yaml:"max,omitempty"`
+	AnyOf []interface{} `yaml:"any-of,omitempty"`
+}
+
+type instance struct {
+	Value string `yaml:"value"`
+	Doc   string `yaml:"doc,omitempty"`
+}
+
+//validID checks id is a Kaitai identifier.
+func validID(id string) error {
+	for i, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case i > 0 && (r == '_' || (r >= '0' && r <= '9')):
+		default:
+			return fmt.Errorf("%q is not a valid identifier", id)
+		}
+	}
+	if id == "" {
+		return fmt.Errorf("empty identifier")
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//binarylint checks the tags of structs encoded with github.com/nathanhack/binary. It runs alone or with go vet:
+//
+//	binarylint ./...
+//	go vet -vettool=$(which binarylint) ./...
+package main
+
+import (
+	"github.com/nathanhack/binary/lint"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(lint.Analyzer)
+}
@@ -0,0 +1,16 @@
+module github.com/nathanhack/binary/lint
+
+go 1.22.0
+
+require (
+	github.com/nathanhack/binary v0.0.0-00010101000000-000000000000
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
+
+//the tag rules are shared with the library in the same repository
+replace github.com/nathanhack/binary => ../
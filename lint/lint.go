@@ -0,0 +1,146 @@
+//Package lint has an analyzer that checks the tags of encoded structs in source code, the same as binary.CheckTags
+// does with reflection. It checks every struct with at least one of the tags of an encoded field, or a tag that looks
+// like a misspelling of them. Each struct is checked on its own, so sizes naming a field that is not in the struct are
+// left alone as they may name a field of a struct holding it. The binarylint command runs it, alone or with go vet:
+//
+//	go vet -vettool=$(which binarylint) ./...
+package lint
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+
+	"github.com/nathanhack/binary/internal/tagrules"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+//Analyzer reports the problems in the tags of encoded structs.
+var Analyzer = &analysis.Analyzer{
+	Name:     "binarytags",
+	Doc:      "check the tags of structs encoded with github.com/nathanhack/binary",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		node := n.(*ast.StructType)
+		st, ok := pass.TypesInfo.TypeOf(node).(*types.Struct)
+		if !ok {
+			return
+		}
+
+		used := false
+		fields := make([]tagrules.Field, st.NumFields())
+		for i := range fields {
+			f := st.Field(i)
+			tag := reflect.StructTag(st.Tag(i))
+			used = used || tagrules.Uses(tag)
+			kind, item := f.Type(), f.Type()
+			for isPointer(kind) {
+				kind = kind.Underlying().(*types.Pointer).Elem()
+			}
+			for {
+				switch u := item.Underlying().(type) {
+				case *types.Pointer:
+					item = u.Elem()
+					continue
+				case *types.Array:
+					item = u.Elem()
+					continue
+				case *types.Slice:
+					item = u.Elem()
+					continue
+				}
+				break
+			}
+			fields[i] = tagrules.Field{Name: f.Name(), Tag: tag, Kind: kindOf(kind), Item: kindOf(item), Custom: hasCustomEncoding(item),
+				Empty: isEmptyStruct(f.Type())}
+		}
+		if !used {
+			return
+		}
+
+		for _, problem := range tagrules.Check(fields, nil) {
+			pass.Reportf(fieldNode(node, problem.Index).Pos(), "%v: %v", fields[problem.Index].Name, problem.Message)
+		}
+	})
+	return nil, nil
+}
+
+func isEmptyStruct(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Struct)
+	return ok && s.NumFields() == 0
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Pointer)
+	return ok
+}
+
+//fieldNode returns the node declaring the field at index, fields declared together share a node.
+func fieldNode(node *ast.StructType, index int) ast.Node {
+	for _, field := range node.Fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		if index < n {
+			if len(field.Names) == 0 {
+				return field
+			}
+			return field.Names[index]
+		}
+		index -= n
+	}
+	return node
+}
+
+//kindOf returns the reflect.Kind values of type t would have.
+func kindOf(t types.Type) reflect.Kind {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[u.Kind()]
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Array:
+		return reflect.Array
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	case *types.Signature:
+		return reflect.Func
+	case *types.Interface:
+		return reflect.Interface
+	}
+	return reflect.Invalid
+}
+
+var basicKinds = map[types.BasicKind]reflect.Kind{
+	types.Bool: reflect.Bool, types.Int: reflect.Int, types.Int8: reflect.Int8, types.Int16: reflect.Int16,
+	types.Int32: reflect.Int32, types.Int64: reflect.Int64, types.Uint: reflect.Uint, types.Uint8: reflect.Uint8,
+	types.Uint16: reflect.Uint16, types.Uint32: reflect.Uint32, types.Uint64: reflect.Uint64,
+	types.Uintptr: reflect.Uintptr, types.Float32: reflect.Float32, types.Float64: reflect.Float64,
+	types.Complex64: reflect.Complex64, types.Complex128: reflect.Complex128, types.String: reflect.String,
+	types.UnsafePointer: reflect.UnsafePointer,
+}
+
+//hasCustomEncoding reports if values of type t encode themselves with MarshalBits and UnmarshalBits.
+func hasCustomEncoding(t types.Type) bool {
+	has := func(name string) bool {
+		obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+		_, ok := obj.(*types.Func)
+		return ok
+	}
+	return has("MarshalBits") && has("UnmarshalBits")
+}
+
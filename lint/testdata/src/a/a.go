@@ -0,0 +1,43 @@
+package a
+
+type Flags uint8
+
+type Custom map[string]int
+
+func (c Custom) MarshalBits() ([]bool, error) { return nil, nil }
+func (c *Custom) UnmarshalBits([]bool) error  { return nil }
+
+type Header struct {
+	_       struct{} `binary:"endian=big,order=msb"` // want `_: unknown struct binary tag setting order`
+	Length  uint16   `endain:"big"`                  // want `Length: unknown tag endain, did you mean endian\?`
+	Data    []byte   `size:"Count"`                  // want `Data: size refers to Count, which is declared after it`
+	Count   uint8
+	Name    string         `strlen:"Data"` // want `Name: strlen refers to Data, which is not an integer`
+	Ratio   float32        `bits:"16"`     // want `Ratio: bits not supported on float32`
+	Wide    Flags          `bits:"9"`      // want `Wide: bits 9 is more than the 8 bits of uint8`
+	Sign    *int16         `bits:"1"`      // want `Sign: bits 1 on the signed int16, it needs at least 2 bits`
+	Items   []int8         `bits:"Count"`
+	hidden  uint8          `bits:"4"` // want `hidden: unexported field is encoded as its zero value and skipped when decoding`
+	_       uint8          `bits:"4"`
+	_       uint8          `binary:"bits=4"`
+	Counts  map[string]int // want `Counts: map not supported`
+	Mine    Custom
+	A, B    int    `bits:"8"`                       // want `A: int not supported` `B: int not supported`
+	Offset  uint16 `binary:"bits=13,endian=middle"` // want `Offset: unsupported endian value: middle`
+	Range   uint8  `binary:"min=10,max=2"`          // want `Range: min 10 is greater than max 2`
+	Skipped uint8  `binary:"omit" bits:"3"`         // want `Skipped: omit along with bits, the field is not encoded`
+}
+
+// Plain has no tags of an encoded struct, so it is not checked.
+type Plain struct {
+	Size  int            `json:"size"`
+	Table map[string]int `json:"table"`
+	name  string
+}
+
+func inline() {
+	_ = struct {
+		N uint8
+		V []byte `size:"V"` // want `V: size refers to the field itself`
+	}{}
+}
@@ -0,0 +1,338 @@
+package binary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//setting is implemented by options that change how a value is walked instead of handling a type. They are passed in
+// with the other options but their Type() is nil.
+type setting interface {
+	EncDecOption
+	setting()
+}
+
+//DecodeOptions changes how Decode and DecodeToBits walk the value being decoded. It is passed in with the other
+// options, e.g. Decode(data, &msg, &DecodeOptions{StopAfter: "MsgType"}) or Decode(data, &msg, StopAfter("MsgType")).
+// When more than one is given the fields set in the later ones take precedence.
+//
+// Field paths are the field names from the top level struct joined with `.`, e.g. `Header.MsgType`. Slice and array
+// items share the path of the field holding them.
+type DecodeOptions struct {
+	//StopAfter is the path of the field after which decoding stops, leaving the buffer positioned right after it.
+	StopAfter string
+	//Fields when not empty are the paths of the only fields decoded, a struct field selects all the fields within it.
+	// The other fields are skipped over, using their sizes when known, and are left untouched in the value.
+	Fields []string
+	//DisallowTrailingData makes it an error for the data to continue after the value is decoded.
+	DisallowTrailingData bool
+	//ReuseAllocations decodes into the slices and pointers already in the value instead of allocating new ones. Slice
+	// items are decoded in place within the existing capacity, so anything else sharing the memory sees the changes.
+	ReuseAllocations bool
+
+	//The limits below guard against hostile input. Zero means no limit.
+
+	//MaxSliceLen is the largest number of items a decoded slice may have.
+	MaxSliceLen int
+	//MaxStringLen is the longest a decoded string may be in bytes.
+	MaxStringLen int
+	//MaxDepth is how deeply fields may be nested, every struct, pointer, array and slice adds a level.
+	MaxDepth int
+	//MaxTotalAllocBytes is the most memory decoding may allocate for slices, strings and pointers.
+	MaxTotalAllocBytes int
+	//Context when set cancels decoding once it is done.
+	Context context.Context
+}
+
+func (o *DecodeOptions) Type() reflect.Type {
+	return nil
+}
+
+func (o *DecodeOptions) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (o *DecodeOptions) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (o *DecodeOptions) setting() {}
+
+//StopAfter returns DecodeOptions that stops decoding after the field with the given path.
+func StopAfter(path string) *DecodeOptions {
+	return &DecodeOptions{StopAfter: path}
+}
+
+//OnlyFields returns DecodeOptions that only decodes the fields with the given paths.
+func OnlyFields(paths ...string) *DecodeOptions {
+	return &DecodeOptions{Fields: paths}
+}
+
+//DisallowTrailingData returns DecodeOptions that fails decoding when data is left over after the value.
+func DisallowTrailingData() *DecodeOptions {
+	return &DecodeOptions{DisallowTrailingData: true}
+}
+
+//ReuseAllocations returns DecodeOptions that decodes into the existing slices and pointers of the value.
+func ReuseAllocations() *DecodeOptions {
+	return &DecodeOptions{ReuseAllocations: true}
+}
+
+//errStopDecoding is passed up through DecodeField once the StopAfter field is decoded.
+var errStopDecoding = errors.New("decoding stopped")
+
+//decodeState is the state of a single call to DecodeToBits. It is carried in the options so it reaches every call to
+// DecodeField, including the ones made by StructEncDec and InterfaceEncDec decoders.
+type decodeState struct {
+	opts      DecodeOptions
+	path      []string
+	depth     int
+	calls     int
+	allocated int
+	//dump records the decoded values for DumpBytes
+	dump *dumper
+}
+
+func (s *decodeState) Type() reflect.Type {
+	return nil
+}
+
+func (s *decodeState) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (s *decodeState) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (s *decodeState) setting() {}
+
+//withDecodeState returns the options with a decodeState added when any DecodeOptions were given. When the options
+// already hold a decodeState, from an outer call to DecodeToBits, it is returned and top is false.
+func withDecodeState(options []EncDecOption) (result []EncDecOption, state *decodeState, top bool) {
+	if s := getDecodeState(options); s != nil {
+		return options, s, false
+	}
+
+	eachSetting(options, func(item EncDecOption) {
+		o, ok := item.(*DecodeOptions)
+		if !ok || o == nil {
+			return
+		}
+		if state == nil {
+			state = &decodeState{}
+		}
+		if o.StopAfter != "" {
+			state.opts.StopAfter = o.StopAfter
+		}
+		if len(o.Fields) > 0 {
+			state.opts.Fields = o.Fields
+		}
+		if o.DisallowTrailingData {
+			state.opts.DisallowTrailingData = true
+		}
+		if o.ReuseAllocations {
+			state.opts.ReuseAllocations = true
+		}
+		if o.MaxSliceLen > 0 {
+			state.opts.MaxSliceLen = o.MaxSliceLen
+		}
+		if o.MaxStringLen > 0 {
+			state.opts.MaxStringLen = o.MaxStringLen
+		}
+		if o.MaxDepth > 0 {
+			state.opts.MaxDepth = o.MaxDepth
+		}
+		if o.MaxTotalAllocBytes > 0 {
+			state.opts.MaxTotalAllocBytes = o.MaxTotalAllocBytes
+		}
+		if o.Context != nil {
+			state.opts.Context = o.Context
+		}
+	})
+
+	if state == nil {
+		return options, nil, true
+	}
+	return append(options[:len(options):len(options)], state), state, true
+}
+
+func getDecodeState(options []EncDecOption) *decodeState {
+	for _, item := range options {
+		if s, ok := item.(*decodeState); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *decodeState) partial() bool {
+	return s != nil && (s.opts.StopAfter != "" || len(s.opts.Fields) > 0)
+}
+
+func (s *decodeState) reuse() bool {
+	return s != nil && s.opts.ReuseAllocations
+}
+
+func (s *decodeState) push(name string) string {
+	s.path = append(s.path, name)
+	return strings.Join(s.path, ".")
+}
+
+func (s *decodeState) pop() {
+	s.path = s.path[:len(s.path)-1]
+}
+
+//selected reports if the field at path is to be decoded.
+func (s *decodeState) selected(path string) bool {
+	if len(s.opts.Fields) == 0 {
+		return true
+	}
+	for _, field := range s.opts.Fields {
+		if field == path || strings.HasPrefix(path, field+".") || strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+//decodeFields decodes the fields of the struct v, msb is set when the struct holding it has bitorder=msb.
+func decodeFields(t reflect.Type, v reflect.Value, buf *bits.BitSetBuffer, sizeMap map[string]int, msb bool, options ...EncDecOption) error {
+	fields, inner, err := fieldsOf(t, options)
+	if err != nil {
+		return err
+	}
+	is, err := isMSB(inner)
+	if err != nil {
+		return fmt.Errorf("%v: %v", t, err)
+	}
+	switched := is != msb
+	if switched && getReadCursor(options).bitPos(buf)%8 != 0 {
+		return fmt.Errorf("%v: a struct with a different bit order must start on a byte boundary", t)
+	}
+	options = inner
+
+	state := getDecodeState(options)
+	for _, sf := range fields {
+		vf := v.Field(sf.Index[0])
+
+		//unexported fields can not be set so they are skipped over, or decoded aside when converting to JSON
+		if !vf.CanSet() {
+			if h := getHiddenFields(options); h != nil {
+				key := hiddenKey(h.path.current(), sf)
+				value := reflect.New(sf.Type).Elem()
+				if err := DecodeField(sf.Name, sf.Type, value, sf.Tag, buf, sizeMap, options...); err != nil {
+					return err
+				}
+				h.values[key] = value
+				continue
+			}
+			if err := skipField(sf.Name, sf.Type, sf.Tag, buf, sizeMap, options...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if state == nil {
+			if err := DecodeField(sf.Name, sf.Type, vf, sf.Tag, buf, sizeMap, options...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := decodeSelectedField(state, sf, vf, buf, sizeMap, options...); err != nil {
+			return err
+		}
+	}
+	if switched && getReadCursor(options).bitPos(buf)%8 != 0 {
+		return fmt.Errorf("%v: a struct with a different bit order must end on a byte boundary", t)
+	}
+	return nil
+}
+
+func decodeSelectedField(state *decodeState, sf reflect.StructField, vf reflect.Value, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	path := state.push(sf.Name)
+	defer state.pop()
+
+	var err error
+	if state.selected(path) {
+		err = DecodeField(sf.Name, sf.Type, vf, sf.Tag, buf, sizeMap, options...)
+	} else {
+		err = skipField(sf.Name, sf.Type, sf.Tag, buf, sizeMap, options...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if path == state.opts.StopAfter {
+		return errStopDecoding
+	}
+	return nil
+}
+
+//enter is called as DecodeField starts on a value and leave when it is done.
+func (s *decodeState) enter(fieldName string) error {
+	s.depth++
+	if s.opts.MaxDepth > 0 && s.depth > s.opts.MaxDepth {
+		return fmt.Errorf("%v: nested deeper than MaxDepth %v", fieldName, s.opts.MaxDepth)
+	}
+
+	//checking the context on every value is more costly than the values themselves
+	s.calls++
+	if s.opts.Context != nil && s.calls%64 == 1 {
+		if err := s.opts.Context.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *decodeState) leave() {
+	s.depth--
+}
+
+func (s *decodeState) checkSliceLen(fieldName string, n int) error {
+	if s != nil && s.opts.MaxSliceLen > 0 && n > s.opts.MaxSliceLen {
+		return fmt.Errorf("%v: slice length %v exceeds MaxSliceLen %v", fieldName, n, s.opts.MaxSliceLen)
+	}
+	return nil
+}
+
+func (s *decodeState) checkStringLen(fieldName string, n uint64) error {
+	if s != nil && s.opts.MaxStringLen > 0 && n > uint64(s.opts.MaxStringLen) {
+		return fmt.Errorf("%v: string length %v exceeds MaxStringLen %v", fieldName, n, s.opts.MaxStringLen)
+	}
+	return nil
+}
+
+//alloc accounts for count items of size bytes about to be allocated.
+func (s *decodeState) alloc(fieldName string, count, size int) error {
+	if s == nil || s.opts.MaxTotalAllocBytes <= 0 {
+		return nil
+	}
+
+	left := s.opts.MaxTotalAllocBytes - s.allocated
+	if size > 0 && count > left/size {
+		return fmt.Errorf("%v: allocating %v items of %v bytes exceeds MaxTotalAllocBytes %v", fieldName, count, size, s.opts.MaxTotalAllocBytes)
+	}
+	s.allocated += count * size
+	return nil
+}
+
+//checkFits returns an error if count items of t can not fit in the bits left in buf. Every item is taken to use at
+// least one bit unless t always encodes to no bits.
+func checkFits(fieldName string, count int, t reflect.Type, tag reflect.StructTag, buf *bits.BitSetBuffer, options []EncDecOption) error {
+	if n, ok := staticBits(t, tag, options); ok && n == 0 {
+		return nil
+	}
+	n := maxInt(minBits(t, tag, options, nil), 1)
+	if remaining := getReadCursor(options).remaining(buf); count > remaining/n {
+		return fmt.Errorf("%v: %v items do not fit in the %v bits remaining", fieldName, count, remaining)
+	}
+	return nil
+}
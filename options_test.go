@@ -0,0 +1,321 @@
+package binary
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+type routedHeader struct {
+	MsgType uint8
+	Flags   uint8 `bits:"4"`
+	Spare   uint8 `bits:"4"`
+}
+
+type routedItem struct {
+	ID    uint16
+	Count uint8
+	Data  []byte `size:"Count"`
+}
+
+type routedMessage struct {
+	Header  routedHeader
+	Length  uint16
+	Payload []byte       `size:"Length"`
+	Items   []routedItem `size:"2"`
+	Trailer uint32
+}
+
+func routedInput() routedMessage {
+	return routedMessage{
+		Header:  routedHeader{MsgType: 7, Flags: 3, Spare: 1},
+		Length:  3,
+		Payload: []byte{1, 2, 3},
+		Items: []routedItem{
+			{ID: 1, Count: 1, Data: []byte{9}},
+			{ID: 2, Count: 2, Data: []byte{8, 7}},
+		},
+		Trailer: 0xdeadbeef,
+	}
+}
+
+func TestStopAfter(t *testing.T) {
+	bs, err := Encode(routedInput())
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+
+	buf, err := bits.NewFromBytes(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual routedMessage
+	err = DecodeToBits(buf, &actual, StopAfter("Header.MsgType"))
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+
+	expected := routedMessage{Header: routedHeader{MsgType: 7}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
+	}
+
+	//the buffer is left right after MsgType
+	var rest struct {
+		Flags uint8 `bits:"4"`
+	}
+	err = DecodeToBits(buf, &rest)
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	if rest.Flags != 3 {
+		t.Fatalf("expected 3 but found %v", rest.Flags)
+	}
+}
+
+func TestOnlyFields(t *testing.T) {
+	input := routedInput()
+	bs, err := Encode(input)
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+
+	tests := []struct {
+		options  *DecodeOptions
+		expected routedMessage
+	}{
+		{
+			OnlyFields("Header.MsgType", "Trailer"),
+			routedMessage{Header: routedHeader{MsgType: 7}, Trailer: 0xdeadbeef},
+		},
+		{
+			OnlyFields("Header"),
+			routedMessage{Header: input.Header},
+		},
+		{
+			OnlyFields("Items.ID"),
+			routedMessage{Items: []routedItem{{ID: 1}, {ID: 2}}},
+		},
+		{
+			&DecodeOptions{Fields: []string{"Length", "Items"}, StopAfter: "Items"},
+			routedMessage{Length: 3, Items: input.Items},
+		},
+	}
+
+	for _, test := range tests {
+		var actual routedMessage
+		err = Decode(bs, &actual, test.options)
+		if err != nil {
+			t.Fatalf("expected no decoding error found: %v", err)
+		}
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Fatalf("expected \n%#v\n but found \n%#v\n", test.expected, actual)
+		}
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	type sized struct {
+		Count uint32
+		Data  []uint16 `size:"Count"`
+	}
+	type named struct {
+		Length uint8
+		Name   string `strlen:"Length"`
+	}
+	type unsized struct {
+		Data []byte
+	}
+	type nested struct {
+		V0 struct {
+			V1 struct {
+				V2 uint8
+			}
+		}
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		data    []byte
+		value   interface{}
+		options *DecodeOptions
+	}{
+		//a hostile size is caught before anything is allocated
+		{[]byte{0xff, 0xff, 0xff, 0xff, 1, 0}, &sized{}, nil},
+		{[]byte{3, 0, 0, 0, 1, 0, 2, 0, 3, 0}, &sized{}, &DecodeOptions{MaxSliceLen: 2}},
+		{[]byte{3, 0, 0, 0, 1, 0, 2, 0, 3, 0}, &sized{}, &DecodeOptions{MaxTotalAllocBytes: 4}},
+		{[]byte{1, 2, 3}, &unsized{}, &DecodeOptions{MaxSliceLen: 2}},
+		{[]byte{200, 'a', 'b'}, &named{}, nil},
+		{[]byte{2, 'a', 'b'}, &named{}, &DecodeOptions{MaxStringLen: 1}},
+		{[]byte{1}, &nested{}, &DecodeOptions{MaxDepth: 2}},
+		{[]byte{1}, &nested{}, &DecodeOptions{Context: canceled}},
+	}
+
+	for i, test := range tests {
+		options := []EncDecOption{}
+		if test.options != nil {
+			options = append(options, test.options)
+		}
+		if err := Decode(test.data, test.value, options...); err == nil {
+			t.Fatalf("%v: expected an error", i)
+		}
+	}
+
+	//within the limits decoding works
+	var actual sized
+	err := Decode([]byte{2, 0, 0, 0, 1, 0, 2, 0}, &actual, &DecodeOptions{
+		MaxSliceLen:        2,
+		MaxDepth:           3,
+		MaxTotalAllocBytes: 4,
+		Context:            context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	expected := sized{Count: 2, Data: []uint16{1, 2}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
+	}
+}
+
+func TestReuseAllocations(t *testing.T) {
+	type item struct {
+		Count uint8
+		Data  []byte `size:"Count"`
+	}
+	type message struct {
+		Header *routedHeader
+		Count  uint8
+		Items  []item `size:"Count"`
+		Rest   []uint16
+	}
+
+	first, err := Encode(message{
+		Header: &routedHeader{MsgType: 1},
+		Count:  2,
+		Items:  []item{{2, []byte{1, 2}}, {1, []byte{3}}},
+		Rest:   []uint16{1, 2, 3},
+	})
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+	expected := message{
+		Header: &routedHeader{MsgType: 2},
+		Count:  1,
+		Items:  []item{{1, []byte{4}}},
+		Rest:   []uint16{5},
+	}
+	second, err := Encode(expected)
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+
+	//without the option a known size is allocated exactly
+	var actual message
+	if err := Decode(first, &actual); err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	if cap(actual.Items) != 2 || cap(actual.Items[0].Data) != 2 {
+		t.Fatalf("expected exact capacities but found %v and %v", cap(actual.Items), cap(actual.Items[0].Data))
+	}
+
+	header, items, data, rest := actual.Header, &actual.Items[0], &actual.Items[0].Data[0], &actual.Rest[0]
+	if err := Decode(second, &actual, ReuseAllocations()); err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
+	}
+	if actual.Header != header || &actual.Items[0] != items || &actual.Items[0].Data[0] != data || &actual.Rest[0] != rest {
+		t.Fatalf("expected the existing allocations to be reused")
+	}
+
+	//the next message needs more room than there is
+	if err := Decode(first, &actual, ReuseAllocations()); err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	if len(actual.Items) != 2 || len(actual.Rest) != 3 || actual.Items[1].Data[0] != 3 {
+		t.Fatalf("expected the first message but found %#v", actual)
+	}
+}
+
+func TestDecodeHugeSize(t *testing.T) {
+	type item struct {
+		Len  uint8
+		Name string `strlen:"Len"`
+	}
+	type message struct {
+		Count uint32
+		Items []item `size:"Count"`
+	}
+
+	//the items are not made ready up front, the decode fails when the data runs out
+	var actual message
+	for _, data := range [][]byte{{0xff, 0xff, 0xff, 0x7f}, {0xff, 0xff, 0xff, 0x7f, 1, 'a', 0}} {
+		if err := Decode(data, &actual); err == nil {
+			t.Fatalf("%x: expected an error", data)
+		}
+	}
+}
+
+func TestDecodeDynamicItemsFit(t *testing.T) {
+	type item struct {
+		Len  uint8
+		Name string `strlen:"Len"`
+	}
+	type message struct {
+		Count uint8
+		Items []item `size:"Count"`
+	}
+
+	//every item takes at least the 8 bits of Len, so 3 items can not be in the 16 bits left
+	var actual message
+	err := Decode([]byte{3, 0, 0}, &actual)
+	if err == nil || !strings.Contains(err.Error(), "3 items do not fit in the 16 bits remaining") {
+		t.Fatalf("expected the items not to fit but found: %v", err)
+	}
+
+	if err := Decode([]byte{2, 0, 1, 'a'}, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expected := message{Count: 2, Items: []item{{}, {Len: 1, Name: "a"}}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %v but found %v", expected, actual)
+	}
+}
+
+func BenchmarkDecodeReuse(b *testing.B) {
+	bs, err := Encode(routedInput())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var actual routedMessage
+	options := ReuseAllocations()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := Decode(bs, &actual, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type routedNode struct {
+	ID   uint8
+	Next *routedNode
+}
+
+func TestOnlyFieldsSelfReferential(t *testing.T) {
+	//skipping Next can not use a static size, it runs out of data the same as decoding it would
+	var actual routedNode
+	if err := Decode([]byte{1, 2, 3}, &actual, OnlyFields("ID")); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,240 @@
+package binary
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Schema describes the wire layout of a struct as Encode writes it and Decode reads it.
+type Schema struct {
+	//Name is the name of the struct type.
+	Name string `json:"name" yaml:"name"`
+	//Bits is the size of the struct when it is the same for every value.
+	Bits *int `json:"bits,omitempty" yaml:"bits,omitempty"`
+	//Fields are the fields in the order they are encoded, omitted fields are left out.
+	Fields []FieldSchema `json:"fields" yaml:"fields"`
+}
+
+//FieldSchema describes a field, or the items of an array or slice field.
+type FieldSchema struct {
+	//Name is the field name, it is empty for items.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	//Type is the Go type, e.g. `uint16` or `[]main.Item`.
+	Type string `json:"type" yaml:"type"`
+	//Kind is the Go kind, e.g. `uint16` or `slice`. It is `custom` for values encoded by a BitsMarshaler or an option.
+	Kind string `json:"kind" yaml:"kind"`
+	//Pointer is true when the field is a pointer to the type, which is encoded the same as the type.
+	Pointer bool `json:"pointer,omitempty" yaml:"pointer,omitempty"`
+	//Offset is the bit offset from the start of the top level struct, or from the start of the item for the fields
+	// within items. It is only set while every field before it has a static size.
+	Offset *int `json:"offset,omitempty" yaml:"offset,omitempty"`
+	//Bits is the size of the field when it is the same for every value.
+	Bits *int `json:"bits,omitempty" yaml:"bits,omitempty"`
+	//BitsFrom is the field holding the number of bits, from a `bits` tag naming a field.
+	BitsFrom string `json:"bitsFrom,omitempty" yaml:"bitsFrom,omitempty"`
+	//Endian is `little` or `big` for numbers.
+	Endian string `json:"endian,omitempty" yaml:"endian,omitempty"`
+	//Size is the `size` tag of a slice, either a number of items or the field holding it. An empty size on a slice
+	// means it takes the rest of the data.
+	Size string `json:"size,omitempty" yaml:"size,omitempty"`
+	//StrLen is the `strlen` tag of a string, either a number of bytes or the field holding it. An empty strlen means
+	// the string takes the rest of the data.
+	StrLen string `json:"strlen,omitempty" yaml:"strlen,omitempty"`
+	//Length is the number of items of an array.
+	Length int `json:"length,omitempty" yaml:"length,omitempty"`
+	//Min, Max and OneOf are the conditions on the value from the `min`, `max` and `oneof` tags.
+	Min   string   `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   string   `json:"max,omitempty" yaml:"max,omitempty"`
+	OneOf []string `json:"oneof,omitempty" yaml:"oneof,omitempty"`
+	//Flags are the named bits of a flags type registered with RegisterFlags.
+	Flags []FlagBit `json:"flags,omitempty" yaml:"flags,omitempty"`
+	//Tag is the struct tag of the field.
+	Tag string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	//Elem describes the items of an array or slice.
+	Elem *FieldSchema `json:"elem,omitempty" yaml:"elem,omitempty"`
+	//Fields describes the fields of a struct.
+	Fields []FieldSchema `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+//FlagBit is a named bit of a flags type, bit 0 being the least significant bit.
+type FlagBit struct {
+	Name string `json:"name" yaml:"name"`
+	Bit  int    `json:"bit" yaml:"bit"`
+}
+
+//Describe returns the Schema of t, a struct or a pointer to one. The options are the ones given to Encode and Decode,
+// values they handle are described as custom.
+func Describe(t reflect.Type, options ...EncDecOption) (schema Schema, err error) {
+	defer recoverError(&err)
+
+	if t == nil {
+		return Schema{}, fmt.Errorf("nil parameters not allowed")
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return Schema{}, fmt.Errorf("%v is not a struct", t)
+	}
+
+	d := describer{options: options}
+	offset := 0
+	fields, err := d.fields(t, &offset)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	schema = Schema{Name: t.Name(), Fields: fields}
+	if n, ok := staticBits(t, "", options); ok {
+		schema.Bits = &n
+	}
+	return schema, nil
+}
+
+//JSON returns the schema as indented JSON.
+func (s Schema) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+//YAML returns the schema as YAML.
+func (s Schema) YAML() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+type describer struct {
+	options []EncDecOption
+	//structs are the structs being described, used to catch recursive types
+	structs []reflect.Type
+}
+
+//fields describes the fields of the struct t starting at offset, which is advanced past them. An offset of -1 is
+// unknown, which it becomes after a field without a static size.
+func (d *describer) fields(t reflect.Type, offset *int) ([]FieldSchema, error) {
+	for _, s := range d.structs {
+		if s == t {
+			return nil, fmt.Errorf("%v is recursive", t)
+		}
+	}
+	d.structs = append(d.structs, t)
+	defer func() { d.structs = d.structs[:len(d.structs)-1] }()
+
+	structFields, options, err := fieldsOf(t, d.options)
+	if err != nil {
+		return nil, err
+	}
+	if msb, err := isMSB(options); err != nil || msb {
+		if err == nil {
+			err = fmt.Errorf("bitorder=msb is not supported by schemas, they pack bits from the least significant bit")
+		}
+		return nil, fmt.Errorf("%v: %v", t, err)
+	}
+	outer := d.options
+	d.options = options
+	defer func() { d.options = outer }()
+
+	fields := make([]FieldSchema, 0, len(structFields))
+	for _, sf := range structFields {
+		field, err := d.field(sf.Name, sf.Type, sf.Tag, offset)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", sf.Name, err)
+		}
+		field.Tag = string(sf.Tag)
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+//field describes a value of t with the tag starting at offset, which is advanced past it.
+func (d *describer) field(name string, t reflect.Type, tag reflect.StructTag, offset *int) (FieldSchema, error) {
+	f := FieldSchema{Name: name, Type: t.String()}
+	start := *offset
+	if start >= 0 {
+		f.Offset = &start
+	}
+	n, static := staticBits(t, tag, d.options)
+	if static {
+		f.Bits = &n
+	}
+	if static && start >= 0 {
+		*offset = start + n
+	} else {
+		*offset = -1
+	}
+
+	for t.Kind() == reflect.Ptr {
+		f.Pointer = true
+		t = t.Elem()
+	}
+	f.Kind = t.Kind().String()
+	if hasCustomCoding(t, d.options) {
+		f.Kind = "custom"
+		return f, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		if s, has := tag.Lookup("bits"); has && !static {
+			f.BitsFrom = s
+		}
+		if t.Kind() != reflect.Bool {
+			endianness, err := getEndianness(tag, d.options)
+			if err != nil {
+				return f, err
+			}
+			f.Endian = "little"
+			if endianness == binary.BigEndian {
+				f.Endian = "big"
+			}
+		}
+		if names, has := LookupFlags(t); has {
+			for i, name := range names.names {
+				if name != "" {
+					f.Flags = append(f.Flags, FlagBit{Name: name, Bit: i})
+				}
+			}
+		}
+		f.Min = tag.Get("min")
+		f.Max = tag.Get("max")
+		if s, has := tag.Lookup("oneof"); has {
+			f.OneOf = strings.Fields(s)
+		}
+	case reflect.String:
+		f.StrLen = tag.Get("strlen")
+		f.Min = tag.Get("min")
+		f.Max = tag.Get("max")
+		if s, has := tag.Lookup("oneof"); has {
+			f.OneOf = strings.Fields(s)
+		}
+	case reflect.Struct:
+		inner := start
+		fields, err := d.fields(t, &inner)
+		if err != nil {
+			return f, err
+		}
+		f.Fields = fields
+	case reflect.Array, reflect.Slice:
+		if t.Kind() == reflect.Array {
+			f.Length = t.Len()
+		} else {
+			f.Size = tag.Get("size")
+		}
+		//the offsets within items are from the start of the item
+		inner := 0
+		elem, err := d.field("", t.Elem(), tag, &inner)
+		if err != nil {
+			return f, err
+		}
+		f.Elem = &elem
+	case reflect.Interface:
+		return f, fmt.Errorf("interface %v needs an InterfaceEncDec option", t)
+	default:
+		return f, fmt.Errorf("%v not supported", t)
+	}
+	return f, nil
+}
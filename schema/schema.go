@@ -0,0 +1,495 @@
+//Package schema encodes and decodes messages whose layout is defined at run time, e.g. loaded from configuration, in
+// place of a Go struct. A Struct lists the fields with the same settings as the tags, Compile builds it once into a
+// Layout, and messages are read into and written from records, maps from the field names to the values.
+//
+//	header, err := schema.Compile(schema.Struct{Name: "Header", Fields: []schema.Field{
+//		{Name: "version", Kind: "uint8", Bits: 4},
+//		{Name: "kind", Kind: "uint8", Bits: 4, OneOf: []string{"1", "2"}},
+//		{Name: "length", Kind: "uint16", Endian: "big"},
+//		{Name: "payload", Kind: "slice", Size: "length", Elem: &schema.Field{Kind: "uint8"}},
+//	}})
+//	...
+//	record, err := header.Decode(data)
+//
+// The layout is the one of a struct with the same fields and tags, the engine encoding and decoding it is the one of
+// Encode and Decode. Parse reads a Struct from JSON or YAML, such as the schemas written by binary.Describe.
+package schema
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nathanhack/binary"
+	"github.com/nathanhack/binary/internal/names"
+	"gopkg.in/yaml.v3"
+)
+
+//Record holds the values of a Struct by field name. Numbers are held as the Go type of their kind, e.g. uint16, byte
+// arrays and slices as []byte, other arrays and slices as []interface{} and nested structs as Records.
+type Record = map[string]interface{}
+
+//Struct is a struct layout defined at run time. The keys of its JSON and YAML are the ones of binary.Schema.
+type Struct struct {
+	//Name is the name of the struct, used in errors.
+	Name string `json:"name" yaml:"name"`
+	//Fields are the fields in the order they are encoded.
+	Fields []Field `json:"fields" yaml:"fields"`
+}
+
+//Field is a field of a Struct, or the items of an array or slice field. The settings are the ones of the tags of the
+// same name and only apply to the kinds the tags do.
+type Field struct {
+	//Name is the field name, it is empty for items. It is the name referred to by size, strlen and bits.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	//Kind is one of bool, uint8, uint16, uint32, uint64, int8, int16, int32, int64, float32, float64, string, array,
+	// slice or struct.
+	Kind string `json:"kind" yaml:"kind"`
+	//Bits is the number of bits of a bool or integer, zero being the width of the kind.
+	Bits int `json:"bits,omitempty" yaml:"bits,omitempty"`
+	//BitsFrom is the field holding the number of bits of a bool or integer.
+	BitsFrom string `json:"bitsFrom,omitempty" yaml:"bitsFrom,omitempty"`
+	//Endian is `little`, the default, or `big`.
+	Endian string `json:"endian,omitempty" yaml:"endian,omitempty"`
+	//Size is the number of items of a slice or the field holding it. An empty size means the slice takes the rest of
+	// the data.
+	Size string `json:"size,omitempty" yaml:"size,omitempty"`
+	//StrLen is the number of bytes of a string or the field holding it. An empty strlen means the string takes the
+	// rest of the data.
+	StrLen string `json:"strlen,omitempty" yaml:"strlen,omitempty"`
+	//Len is the number of items of an array.
+	Len int `json:"length,omitempty" yaml:"length,omitempty"`
+	//Min, Max and OneOf are the conditions checked on encode and decode.
+	Min   string   `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   string   `json:"max,omitempty" yaml:"max,omitempty"`
+	OneOf []string `json:"oneof,omitempty" yaml:"oneof,omitempty"`
+	//Elem is the items of an array or slice.
+	Elem *Field `json:"elem,omitempty" yaml:"elem,omitempty"`
+	//Fields are the fields of a struct.
+	Fields []Field `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+//Layout is a Struct with its struct type built, made by Compile. Building the type is the costly part, so a Layout
+// is made once and kept for the messages. It is not changed once made and may be used by many goroutines at once.
+type Layout struct {
+	s Struct
+	t reflect.Type
+}
+
+//Compile checks s and builds the struct type with its layout. Field names that are not exported Go names, e.g.
+// `total_length`, are made into ones, `TotalLength`, with a json tag of the name from s.
+func Compile(s Struct) (*Layout, error) {
+	if len(s.Fields) == 0 {
+		return nil, fmt.Errorf("%v has no fields", s.Name)
+	}
+	if err := checkFields(s.Fields); err != nil {
+		return nil, err
+	}
+	t, err := structType(s.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return &Layout{s: s, t: t}, nil
+}
+
+//Parse reads a Struct written as JSON or YAML and compiles it. It may be written by hand or be a binary.Schema written
+// by Schema.JSON or Schema.YAML, of which only the name, kind and the keys giving the layout are used.
+func Parse(data []byte) (*Layout, error) {
+	var s Struct
+	//JSON is also YAML
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return Compile(s)
+}
+
+//Struct returns the Struct the layout was compiled from.
+func (l *Layout) Struct() Struct {
+	return l.s
+}
+
+//Type returns the struct type with the layout. Values of it can be used with Encode and Decode directly.
+func (l *Layout) Type() reflect.Type {
+	return l.t
+}
+
+//checkFields checks for the mistakes the tags built from the fields would not show.
+func checkFields(fields []Field) error {
+	for _, f := range fields {
+		if f.Name == "" {
+			return fmt.Errorf("a field has no name")
+		}
+		if err := checkField(f); err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func checkField(f Field) error {
+	switch f.Kind {
+	case "struct":
+		if len(f.Fields) == 0 {
+			return fmt.Errorf("struct has no fields")
+		}
+		return checkFields(f.Fields)
+	case "array", "slice":
+		if f.Elem == nil {
+			return fmt.Errorf("%v has no elem", f.Kind)
+		}
+		if f.Kind == "array" && f.Len <= 0 {
+			return fmt.Errorf("array has no len")
+		}
+		return checkField(*f.Elem)
+	case "float32", "float64":
+		//the width of the kind is what binary.Describe writes
+		if (f.Bits != 0 && f.Bits != kindTypes[f.Kind].Bits()) || f.BitsFrom != "" {
+			return fmt.Errorf("bits not supported on %v", f.Kind)
+		}
+	}
+	if f.Endian != "" && f.Endian != "little" && f.Endian != "big" {
+		return fmt.Errorf("endian must be little or big, not %q", f.Endian)
+	}
+	return nil
+}
+
+var kindTypes = map[string]reflect.Type{
+	"bool":    reflect.TypeOf(false),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"string":  reflect.TypeOf(""),
+}
+
+//structType returns the struct type of the fields.
+func structType(fields []Field) (reflect.Type, error) {
+	structFields := make([]reflect.StructField, 0, len(fields))
+	seen := map[string]bool{}
+	for _, f := range fields {
+		name, err := goName(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%v: more than one field named %v", f.Name, name)
+		}
+		seen[name] = true
+
+		t, err := fieldType(f)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", f.Name, err)
+		}
+		tag, err := fieldTag(f)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", f.Name, err)
+		}
+		if name != f.Name {
+			tag = strings.TrimSpace(fmt.Sprintf("%v json:%q", tag, f.Name))
+		}
+		structFields = append(structFields, reflect.StructField{Name: name, Type: t, Tag: reflect.StructTag(tag)})
+	}
+	return reflect.StructOf(structFields), nil
+}
+
+//fieldType returns the type of the field or item f.
+func fieldType(f Field) (reflect.Type, error) {
+	switch f.Kind {
+	case "struct":
+		return structType(f.Fields)
+	case "array", "slice":
+		t, err := fieldType(*f.Elem)
+		if err != nil {
+			return nil, err
+		}
+		if f.Kind == "array" {
+			return reflect.ArrayOf(f.Len, t), nil
+		}
+		return reflect.SliceOf(t), nil
+	case "custom":
+		return nil, fmt.Errorf("custom values have no layout to build")
+	}
+	t, has := kindTypes[f.Kind]
+	if !has {
+		return nil, fmt.Errorf("unknown kind %q", f.Kind)
+	}
+	return t, nil
+}
+
+//fieldTag returns the tag of f. The number tags of the items of arrays and slices go on the field holding them.
+func fieldTag(f Field) (string, error) {
+	var tags []string
+	add := func(key, value string) {
+		tags = append(tags, fmt.Sprintf("%v:%q", key, value))
+	}
+	ref := func(key, value string) error {
+		if value == "" {
+			return nil
+		}
+		if _, err := strconv.ParseUint(value, 10, 63); err == nil {
+			add(key, value)
+			return nil
+		}
+		name, err := goName(value)
+		if err != nil {
+			return err
+		}
+		add(key, name)
+		return nil
+	}
+
+	switch f.Kind {
+	case "array", "slice":
+		if err := ref("size", f.Size); err != nil {
+			return "", err
+		}
+		elem := f.Elem
+		for elem.Kind == "array" || elem.Kind == "slice" {
+			elem = elem.Elem
+		}
+		if elem.Kind == "struct" {
+			break
+		}
+		f = *elem
+		fallthrough
+	case "bool", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64", "float32", "float64", "string":
+		switch {
+		case f.BitsFrom != "":
+			if err := ref("bits", f.BitsFrom); err != nil {
+				return "", err
+			}
+		case f.Bits != 0 && naturalBits(f.Kind) >= 0 && f.Bits != naturalBits(f.Kind):
+			add("bits", strconv.Itoa(f.Bits))
+		}
+		if f.Endian == "big" {
+			add("endian", f.Endian)
+		}
+		if err := ref("strlen", f.StrLen); err != nil {
+			return "", err
+		}
+		if f.Min != "" {
+			add("min", f.Min)
+		}
+		if f.Max != "" {
+			add("max", f.Max)
+		}
+		if len(f.OneOf) > 0 {
+			add("oneof", strings.Join(f.OneOf, " "))
+		}
+	}
+	return strings.Join(tags, " "), nil
+}
+
+//naturalBits returns the bits a value of an integer or bool kind has without a bits tag, and -1 for other kinds which
+// take no bits tag.
+func naturalBits(kind string) int {
+	switch kind {
+	case "bool":
+		return 8
+	case "string", "float32", "float64":
+		return -1
+	}
+	return kindTypes[kind].Bits()
+}
+
+//goName returns the exported Go name of a schema name.
+func goName(name string) (string, error) {
+	if token.IsIdentifier(name) && token.IsExported(name) {
+		return name, nil
+	}
+	goName := names.Camel(name)
+	if !token.IsIdentifier(goName) || !token.IsExported(goName) {
+		return "", fmt.Errorf("%q can not be made into a Go name", name)
+	}
+	return goName, nil
+}
+
+//Decode decodes data into a Record, the same as binary.Decode decodes into a struct.
+func (l *Layout) Decode(data []byte, options ...binary.EncDecOption) (Record, error) {
+	r, _, err := l.DecodeN(data, options...)
+	return r, err
+}
+
+//DecodeN is the same as Decode but also returns the number of bits decoded.
+func (l *Layout) DecodeN(data []byte, options ...binary.EncDecOption) (Record, int, error) {
+	v := reflect.New(l.t)
+	n, err := binary.DecodeN(data, v.Interface(), options...)
+	if err != nil {
+		return nil, n, err
+	}
+	return record(l.s.Fields, v.Elem()), n, nil
+}
+
+//Encode encodes the record, the same as binary.Encode encodes a struct. Missing fields are encoded as zero and fields
+// not in the layout are an error. Numbers may be of any Go number type as long as the value fits, so records decoded
+// from JSON can be encoded.
+func (l *Layout) Encode(r Record, options ...binary.EncDecOption) ([]byte, error) {
+	v := reflect.New(l.t)
+	if err := setRecord(l.s.Fields, v.Elem(), r); err != nil {
+		return nil, err
+	}
+	return binary.Encode(v.Interface(), options...)
+}
+
+//record returns the Record of the struct value v with the fields.
+func record(fields []Field, v reflect.Value) Record {
+	r := make(Record, len(fields))
+	for i, f := range fields {
+		r[f.Name] = value(f, v.Field(i))
+	}
+	return r
+}
+
+//value returns the value of f held in v as it is in a Record.
+func value(f Field, v reflect.Value) interface{} {
+	switch f.Kind {
+	case "struct":
+		return record(f.Fields, v)
+	case "array", "slice":
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			bs := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(bs), v)
+			return bs
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = value(*f.Elem, v.Index(i))
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+//setRecord sets the struct value v with the fields to the Record r.
+func setRecord(fields []Field, v reflect.Value, r Record) error {
+	known := make(map[string]bool, len(fields))
+	for i, f := range fields {
+		known[f.Name] = true
+		x, has := r[f.Name]
+		if !has {
+			continue
+		}
+		if err := setValue(f, v.Field(i), x); err != nil {
+			return fmt.Errorf("%v: %v", f.Name, err)
+		}
+	}
+
+	var unknown []string
+	for name := range r {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown fields %v", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+//setValue sets v, holding a value of f, to x.
+func setValue(f Field, v reflect.Value, x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	xv := reflect.ValueOf(x)
+
+	switch f.Kind {
+	case "struct":
+		r, ok := x.(Record)
+		if !ok {
+			return fmt.Errorf("expected a record but found %T", x)
+		}
+		return setRecord(f.Fields, v, r)
+	case "array", "slice":
+		if xv.Kind() != reflect.Slice && xv.Kind() != reflect.Array {
+			return fmt.Errorf("expected a slice but found %T", x)
+		}
+		if f.Kind == "array" && xv.Len() != v.Len() {
+			return fmt.Errorf("expected %v items but found %v", v.Len(), xv.Len())
+		}
+		if f.Kind == "slice" {
+			v.Set(reflect.MakeSlice(v.Type(), xv.Len(), xv.Len()))
+		}
+		for i := 0; i < xv.Len(); i++ {
+			if err := setValue(*f.Elem, v.Index(i), xv.Index(i).Interface()); err != nil {
+				return fmt.Errorf("[%v]: %v", i, err)
+			}
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return setNumber(v, xv)
+	}
+	if xv.Type() != v.Type() {
+		return fmt.Errorf("expected a %v but found %T", v.Type(), x)
+	}
+	v.Set(xv)
+	return nil
+}
+
+//setNumber sets the number v to the number xv, which must fit.
+func setNumber(v, xv reflect.Value) error {
+	var i int64
+	var u uint64
+	var fl float64
+	switch xv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, u, fl = xv.Int(), uint64(xv.Int()), float64(xv.Int())
+		if i < 0 && isUint(v) {
+			return fmt.Errorf("%v does not fit %v", i, v.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, u, fl = int64(xv.Uint()), xv.Uint(), float64(xv.Uint())
+		if i < 0 && !isUint(v) {
+			return fmt.Errorf("%v does not fit %v", u, v.Type())
+		}
+	case reflect.Float32, reflect.Float64:
+		fl = xv.Float()
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			if fl != float64(int64(fl)) && fl != float64(uint64(fl)) {
+				return fmt.Errorf("%v is not a whole number", fl)
+			}
+			if fl < 0 && isUint(v) {
+				return fmt.Errorf("%v does not fit %v", fl, v.Type())
+			}
+			i, u = int64(fl), uint64(fl)
+		}
+	default:
+		return fmt.Errorf("expected a number but found %v", xv.Type())
+	}
+
+	switch {
+	case isUint(v):
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%v does not fit %v", u, v.Type())
+		}
+		v.SetUint(u)
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		v.SetFloat(fl)
+	default:
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%v does not fit %v", i, v.Type())
+		}
+		v.SetInt(i)
+	}
+	return nil
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
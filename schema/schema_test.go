@@ -0,0 +1,310 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nathanhack/binary"
+)
+
+var headerStruct = Struct{Name: "Header", Fields: []Field{
+	{Name: "version", Kind: "uint8", Bits: 4},
+	{Name: "kind", Kind: "uint8", Bits: 4, OneOf: []string{"1", "2"}},
+	{Name: "length", Kind: "uint16", Endian: "big"},
+	{Name: "payload", Kind: "slice", Size: "length", Elem: &Field{Kind: "uint8"}},
+	{Name: "points", Kind: "array", Len: 2, Elem: &Field{Kind: "struct", Fields: []Field{
+		{Name: "x", Kind: "int8", Bits: 6},
+		{Name: "y", Kind: "int8", Bits: 2, Min: "-1"},
+	}}},
+	{Name: "label_len", Kind: "uint8"},
+	{Name: "label", Kind: "string", StrLen: "label_len"},
+	{Name: "ratio", Kind: "float32"},
+	{Name: "ok", Kind: "bool", Bits: 1},
+}}
+
+//goHeader is header declared as a Go struct.
+type goHeader struct {
+	Version  uint8  `bits:"4"`
+	Kind     uint8  `bits:"4" oneof:"1 2"`
+	Length   uint16 `endian:"big"`
+	Payload  []byte `size:"Length"`
+	Points   [2]goPoint
+	LabelLen uint8
+	Label    string `strlen:"LabelLen"`
+	Ratio    float32
+	Ok       bool `bits:"1"`
+}
+
+type goPoint struct {
+	X int8 `bits:"6"`
+	Y int8 `bits:"2" min:"-1"`
+}
+
+var goValue = goHeader{Version: 4, Kind: 1, Length: 2, Payload: []byte{0xaa, 0xbb},
+	Points: [2]goPoint{{X: -5, Y: 1}, {X: 31, Y: -1}}, LabelLen: 3, Label: "abc", Ratio: 1.5, Ok: true}
+
+var headerRecord = Record{
+	"version": uint8(4), "kind": uint8(1), "length": uint16(2), "payload": []byte{0xaa, 0xbb},
+	"points": []interface{}{Record{"x": int8(-5), "y": int8(1)}, Record{"x": int8(31), "y": int8(-1)}},
+	"label_len": uint8(3), "label": "abc", "ratio": float32(1.5), "ok": true,
+}
+
+//compile returns the Layout of s, failing the test when it can not be compiled.
+func compile(t *testing.T, s Struct) *Layout {
+	t.Helper()
+	layout, err := Compile(s)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	return layout
+}
+
+func TestDecode(t *testing.T) {
+	header := compile(t, headerStruct)
+	data, err := binary.Encode(&goValue)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	r, n, err := header.DecodeN(data)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if n != 8*len(data)-7 {
+		t.Fatalf("expected %v bits but found %v", 8*len(data)-7, n)
+	}
+	if !reflect.DeepEqual(headerRecord, r) {
+		t.Fatalf("expected\n%#v\nbut found\n%#v", headerRecord, r)
+	}
+
+	if _, err := header.Decode(data[:4]); err == nil {
+		t.Fatalf("expected an error")
+	}
+	data[0] = 0x34
+	if _, err := header.Decode(data); err == nil || !strings.Contains(err.Error(), "not one of") {
+		t.Fatalf("expected the oneof error but found %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	header := compile(t, headerStruct)
+	expected, err := binary.Encode(&goValue)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	data, err := header.Encode(headerRecord)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, data) {
+		t.Fatalf("expected %v but found %v", expected, data)
+	}
+
+	//a record read from JSON has float64 numbers and []interface{} slices
+	var fromJSON Record
+	err = json.Unmarshal([]byte(`{"version": 4, "kind": 1, "length": 2, "payload": [170, 187],
+		"points": [{"x": -5, "y": 1}, {"x": 31, "y": -1}], "label_len": 3, "label": "abc", "ratio": 1.5, "ok": true}`), &fromJSON)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	data, err = header.Encode(fromJSON)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, data) {
+		t.Fatalf("expected %v but found %v", expected, data)
+	}
+
+	//missing fields are zero
+	data, err = header.Encode(Record{"kind": 2})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	r, err := header.Decode(data)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if r["kind"] != uint8(2) || r["version"] != uint8(0) || r["label"] != "" {
+		t.Fatalf("expected zero fields but found %v", r)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	header := compile(t, headerStruct)
+	tests := []Record{
+		{"kind": 3},
+		{"kind": 1, "unknown": 1},
+		{"kind": 1, "version": -1},
+		{"kind": 1, "length": 70000},
+		{"kind": 1, "length": 1.5},
+		{"kind": 1, "label": 5},
+		{"kind": 1, "ok": "yes"},
+		{"kind": 1, "points": []interface{}{Record{}}},
+		{"kind": 1, "points": "none"},
+		{"kind": 1, "points": []interface{}{Record{"x": 1}, 5}},
+	}
+	for _, test := range tests {
+		if _, err := header.Encode(test); err == nil {
+			t.Fatalf("%v: expected an error", test)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	layout := compile(t, headerStruct)
+	typ := layout.Type()
+	if n, fixed := binary.StaticBitSize(typ); fixed {
+		t.Fatalf("expected no static size but found %v", n)
+	}
+	f, _ := typ.FieldByName("LabelLen")
+	if f.Tag != `json:"label_len"` {
+		t.Fatalf("expected a json tag but found %q", f.Tag)
+	}
+	if layout.Struct().Name != "Header" {
+		t.Fatalf("expected the struct compiled but found %v", layout.Struct().Name)
+	}
+
+	tests := []Struct{
+		{Name: "empty"},
+		{Fields: []Field{{Kind: "uint8"}}},
+		{Fields: []Field{{Name: "a", Kind: "array", Elem: &Field{Kind: "uint8"}}}},
+		{Fields: []Field{{Name: "a", Kind: "slice"}}},
+		{Fields: []Field{{Name: "a", Kind: "struct"}}},
+		{Fields: []Field{{Name: "a", Kind: "float32", Bits: 3}}},
+		{Fields: []Field{{Name: "a", Kind: "uint16", Endian: "middle"}}},
+		{Fields: []Field{{Name: "a", Kind: "uint128"}}},
+		{Fields: []Field{{Name: "a", Kind: "custom"}}},
+		{Fields: []Field{{Name: "a", Kind: "slice", Size: "b c", Elem: &Field{Kind: "uint8"}}}},
+		{Fields: []Field{{Name: "a b", Kind: "uint8"}}},
+		{Fields: []Field{{Name: "_", Kind: "uint8"}}},
+		{Fields: []Field{{Name: "a", Kind: "uint8"}, {Name: "A", Kind: "uint8"}}},
+	}
+	for _, test := range tests {
+		if _, err := Compile(test); err == nil {
+			t.Fatalf("%v: expected an error", test)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	layout, err := Parse([]byte(`
+name: record
+fields:
+  - {name: version, kind: uint8, bits: 4}
+  - {name: kind, kind: uint8, bits: 4, oneof: ["1", "2"]}
+  - {name: total_length, kind: uint16, endian: big}
+  - name: payload
+    kind: slice
+    size: total_length
+    elem: {kind: uint8}
+`))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expectedTags := map[string]reflect.StructTag{
+		"Version":     `bits:"4" json:"version"`,
+		"Kind":        `bits:"4" oneof:"1 2" json:"kind"`,
+		"TotalLength": `endian:"big" json:"total_length"`,
+		"Payload":     `size:"TotalLength" json:"payload"`,
+	}
+	for name, tag := range expectedTags {
+		f, has := layout.Type().FieldByName(name)
+		if !has || f.Tag != tag {
+			t.Fatalf("%v: expected the tag %q but found %q", name, tag, f.Tag)
+		}
+	}
+
+	v := reflect.New(layout.Type())
+	if err := binary.Decode([]byte{0x14, 0, 2, 0xaa, 0xbb}, v.Interface()); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	bs, err := json.Marshal(v.Interface())
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	expected := `{"version":4,"kind":1,"total_length":2,"payload":"qrs="}`
+	if string(bs) != expected {
+		t.Fatalf("expected %v but found %s", expected, bs)
+	}
+
+	//JSON is read the same
+	if _, err := Parse([]byte(`{"name": "r", "fields": [{"name": "A", "kind": "uint8"}]}`)); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	for _, test := range []string{`name: empty`, `{`, `{"fields": [{"name": "A", "kind": "custom"}]}`} {
+		if _, err := Parse([]byte(test)); err == nil {
+			t.Fatalf("%q: expected an error", test)
+		}
+	}
+}
+
+//bigHeader is goHeader with big endian numbers from its defaults.
+type bigHeader struct {
+	_      struct{} `binary:"endian=big"`
+	Length uint16
+	Points [2]goPoint
+	Ratio  float32
+	Flags  uint8 `bits:"3"`
+	Count  uint8 `bits:"5"`
+	Items  []uint16 `size:"Count" binary:"endian=little"`
+}
+
+func TestParseDescribed(t *testing.T) {
+	for _, value := range []interface{}{
+		&goValue,
+		&bigHeader{Length: 0x102, Points: [2]goPoint{{X: 3, Y: -1}}, Ratio: 2.5, Flags: 5, Count: 2, Items: []uint16{1, 0x203}},
+	} {
+		described, err := binary.Describe(reflect.TypeOf(value))
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		written, err := described.YAML()
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		layout, err := Parse(written)
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+
+		expected, err := binary.Encode(value)
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		r, err := layout.Decode(expected)
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		actual, err := layout.Encode(r)
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("%T: expected %x but found %x", value, expected, actual)
+		}
+	}
+
+	//the tags are built back the same
+	described, err := binary.Describe(reflect.TypeOf(goHeader{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	written, err := described.JSON()
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	layout, err := Parse(written)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	original := reflect.TypeOf(goHeader{})
+	for i := 0; i < original.NumField(); i++ {
+		f := layout.Type().Field(i)
+		if f.Tag != original.Field(i).Tag {
+			t.Fatalf("%v: expected the tag %q but found %q", f.Name, original.Field(i).Tag, f.Tag)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package binary
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDescribe(t *testing.T) {
+	schema, err := Describe(reflect.TypeOf(&ipv4Header{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if schema.Name != "ipv4Header" || schema.Bits == nil || *schema.Bits != 160 {
+		t.Fatalf("expected a 160 bit ipv4Header but found %v %v", schema.Name, schema.Bits)
+	}
+
+	offsets := []int{0, 4, 8, 14, 16, 32, 48, 51, 64, 72, 80, 96, 128}
+	if len(schema.Fields) != len(offsets) {
+		t.Fatalf("expected %v fields but found %v", len(offsets), len(schema.Fields))
+	}
+	for i, field := range schema.Fields {
+		if field.Offset == nil || *field.Offset != offsets[i] {
+			t.Fatalf("%v: expected offset %v but found %v", field.Name, offsets[i], field.Offset)
+		}
+	}
+
+	flags := schema.Fields[6]
+	expectedFlags := []FlagBit{{"DontFrag", 1}, {"MoreFrag", 2}}
+	if flags.Name != "Flags" || *flags.Bits != 3 || !reflect.DeepEqual(expectedFlags, flags.Flags) {
+		t.Fatalf("expected the flags field but found %#v", flags)
+	}
+	if schema.Fields[7].Endian != "big" || schema.Fields[8].Endian != "little" {
+		t.Fatalf("expected big then little endian but found %v %v", schema.Fields[7].Endian, schema.Fields[8].Endian)
+	}
+	source := schema.Fields[11]
+	if source.Kind != "array" || source.Length != 4 || source.Elem == nil || *source.Elem.Bits != 8 {
+		t.Fatalf("expected an array of 4 bytes but found %#v", source)
+	}
+}
+
+func TestDescribeDynamic(t *testing.T) {
+	schema, err := Describe(reflect.TypeOf(routedMessage{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if schema.Bits != nil {
+		t.Fatalf("expected no static size but found %v", *schema.Bits)
+	}
+
+	header, length, payload, items, trailer := schema.Fields[0], schema.Fields[1], schema.Fields[2], schema.Fields[3], schema.Fields[4]
+	if len(header.Fields) != 3 || *header.Fields[2].Offset != 12 {
+		t.Fatalf("expected the header fields with offsets but found %#v", header.Fields)
+	}
+	if *length.Offset != 16 || *payload.Offset != 32 || payload.Size != "Length" || payload.Bits != nil {
+		t.Fatalf("expected a payload sized by Length but found %#v", payload)
+	}
+	if items.Offset != nil || trailer.Offset != nil || trailer.Bits == nil || *trailer.Bits != 32 {
+		t.Fatalf("expected unknown offsets after the payload but found %#v %#v", items, trailer)
+	}
+
+	//the offsets within items are from the start of the item
+	data := items.Elem.Fields[2]
+	if items.Size != "2" || data.Size != "Count" || data.Offset == nil || *data.Offset != 24 {
+		t.Fatalf("expected items with data at offset 24 but found %#v", items)
+	}
+
+	validated, err := Describe(reflect.TypeOf(validatedHeader{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	version, offsets, kind := validated.Fields[0], validated.Fields[2], validated.Fields[3]
+	if !reflect.DeepEqual(version.OneOf, []string{"4", "6"}) || offsets.Elem.Min != "-10" || kind.StrLen != "3" {
+		t.Fatalf("expected the conditions but found %#v", validated)
+	}
+}
+
+func TestDescribeErrors(t *testing.T) {
+	type node struct {
+		Value uint8
+		Next  *node
+	}
+
+	tests := []reflect.Type{
+		nil,
+		reflect.TypeOf(3),
+		reflect.TypeOf(node{}),
+		reflect.TypeOf(struct{ I interface{} }{}),
+		reflect.TypeOf(struct{ C chan int }{}),
+	}
+	for _, test := range tests {
+		if _, err := Describe(test); err == nil {
+			t.Fatalf("%v: expected an error", test)
+		}
+	}
+}
+
+func TestSchemaJSONAndYAML(t *testing.T) {
+	schema, err := Describe(reflect.TypeOf(routedMessage{}))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	bs, err := schema.JSON()
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !strings.Contains(string(bs), `"offset": 0,`) {
+		t.Fatalf("expected the zero offset to be written but found %s", bs)
+	}
+	var fromJSON Schema
+	if err := json.Unmarshal(bs, &fromJSON); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(schema, fromJSON) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", schema, fromJSON)
+	}
+
+	bs, err = schema.YAML()
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	var fromYAML Schema
+	if err := yaml.Unmarshal(bs, &fromYAML); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(schema, fromYAML) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", schema, fromYAML)
+	}
+}
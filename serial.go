@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	bits "github.com/nathanhack/bitsetbuffer"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -59,10 +60,35 @@ func (i *InterfaceEncDec) DecoderFunc() func(fieldName string, t reflect.Type, v
 	return i.Decoder
 }
 
+//TypeEncDec encodes and decodes the values of any type, such as a named integer, array or slice, in place of how
+// they are encoded by their kind. The type must be the exact type of the values, e.g. net.IP and not []byte.
+type TypeEncDec struct {
+	ValueType reflect.Type
+	Encoder   func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error
+	Decoder   func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error
+	//codec is the FieldCodec of an option made by CodecFor
+	codec FieldCodec
+}
+
+func (e *TypeEncDec) Type() reflect.Type {
+	return e.ValueType
+}
+
+func (e *TypeEncDec) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return e.Encoder
+}
+
+func (e *TypeEncDec) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return e.Decoder
+}
+
 func validateOptions(options ...EncDecOption) error {
 	for _, item := range options {
-		if item.Type() == nil || (item.Type().Kind() != reflect.Struct && item.Type().Kind() != reflect.Interface) {
-			return fmt.Errorf("Type() must not be nil and be either a struct or interface")
+		if _, ok := item.(setting); ok {
+			continue
+		}
+		if item.Type() == nil {
+			return fmt.Errorf("Type() must not be nil")
 		}
 		if item.EncoderFunc() == nil {
 			return fmt.Errorf("EncoderFunc() must not be nil")
@@ -79,21 +105,33 @@ func validateOptions(options ...EncDecOption) error {
 //  StructEncDec options are also a way to change the behaviour of struct encoding for structs that do/can not implement
 //  BitsMarshaler.
 func Encode(st interface{}, options ...EncDecOption) ([]byte, error) {
-	buf, err := EncodeToBits(st, options...)
+	bs, err := AppendEncode([]byte{}, st, options...)
 	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return bs, nil
+}
+
+func EncodeToBits(st interface{}, options ...EncDecOption) (_ *bits.BitSetBuffer, err error) {
+	defer recoverError(&err)
+
+	buf := &bits.BitSetBuffer{}
+	if err := encodeStruct(buf, st, options...); err != nil {
+		return nil, err
+	}
+	return buf, nil
 }
 
-func EncodeToBits(st interface{}, options ...EncDecOption) (*bits.BitSetBuffer, error) {
+//encodeStruct encodes st, a struct or a pointer to one, into buf.
+func encodeStruct(buf bits.BitSetWriter, st interface{}, options ...EncDecOption) error {
 	if st == nil {
-		return nil, fmt.Errorf("nil pointer not alowed")
+		return fmt.Errorf("nil pointer not alowed")
 	}
 
 	if err := validateOptions(options...); err != nil {
-		return nil, err
+		return err
 	}
+	options = withFieldPath(options)
 
 	t := reflect.TypeOf(st)
 	v := reflect.ValueOf(st)
@@ -102,57 +140,57 @@ loop:
 	for {
 		switch t.Kind() {
 		case reflect.Ptr:
+			if v.IsNil() {
+				return fmt.Errorf("nil pointer not alowed")
+			}
 			t = t.Elem()
 			v = v.Elem()
 		case reflect.Struct:
 			break loop
 		default:
-			return nil, fmt.Errorf("invalid value")
+			return fmt.Errorf("invalid value")
 		}
 	}
 
+	if err := validateRoot(v); err != nil {
+		return err
+	}
+
 	//check it we have a BitMarshaler
-	buf := &bits.BitSetBuffer{}
 	processed, err := encMarshaler(v, buf)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if processed {
-		return buf, nil
+		return nil
 	}
 
 	//so we didn't have a BitMarshaler so we'll
 	// work on the Struct Options
-	sizeMap := map[string]int{}
-	processed, err = encStructSpecial("", v, "", buf, sizeMap, options...)
+	sizeMap := getSizeMap()
+	defer putSizeMap(sizeMap)
+	processed, err = encSpecial("", v, "", buf, sizeMap, options...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if processed {
-		return buf, nil
+		return nil
 	}
 
 	//lastly it's just a plain struct so we get to work on the fields
-	for i := 0; i < t.NumField(); i++ {
-		sf := t.Field(i)
-		if _, has := sf.Tag.Lookup("omit"); has {
-			continue
-		}
-		err = EncodeField(sf.Name, sf.Type, v.Field(i), sf.Tag, buf, sizeMap, options...)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return buf, nil
+	return encodeFields(t, v, buf, sizeMap, options...)
 }
 
 func encMarshaler(v reflect.Value, buf bits.BitSetWriter) (bool, error) {
 	modelType := reflect.TypeOf((*BitsMarshaler)(nil)).Elem()
 	t := v.Type()
 	var marshaler BitsMarshaler
-	if t.Implements(modelType) {
+	if !v.CanInterface() || isNil(v) {
+		//unexported fields can not be used and nil values are encoded as their zero value
+		return false, nil
+	} else if t.Implements(modelType) {
 		marshaler = v.Interface().(BitsMarshaler)
 	} else if reflect.PtrTo(t).Implements(modelType) && v.CanAddr() {
 		marshaler = v.Addr().Interface().(BitsMarshaler)
@@ -176,22 +214,39 @@ func encMarshaler(v reflect.Value, buf bits.BitSetWriter) (bool, error) {
 	return true, nil
 }
 
-func encStructSpecial(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) (bool, error) {
-	for _, enc := range options {
-		if enc.Type() == v.Type() {
-			err := enc.EncoderFunc()(fieldName, v, tag, buf, sizeMap, options...)
-			if err != nil {
-				return false, err
-			}
-			return true, nil
-		}
+//encSpecial encodes v with the option for its type, processed is false when there is none.
+func encSpecial(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) (bool, error) {
+	enc := lookupOption(v.Type(), options)
+	if enc == nil {
+		return false, nil
+	}
+	if err := enc.EncoderFunc()(fieldName, v, tag, buf, sizeMap, options...); err != nil {
+		return false, err
+	}
+	recordSize(fieldName, v, sizeMap)
+	return true, nil
+}
+
+//recordSize puts the value of v in sizeMap when it is an integer, the same as when it is encoded by its kind, so the
+// fields after it may refer to it.
+func recordSize(fieldName string, v reflect.Value, sizeMap map[string]int) {
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		sizeMap[fieldName] = int(v.Uint())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sizeMap[fieldName] = int(v.Int())
 	}
-	return false, nil
 }
 
 //EncodeField should be only if it's part of one of the encode function in one of the options (StructEncDec or InterfaceEncDec).  When
 // called on a field it will do correct encoding. Be careful when calling this function in the options as to avoid recursive explosion.
+// A FieldCodec can use EncodeContext.EncodeField in its place.
 func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	if p := getFieldPath(options); p != nil {
+		p.enter(fieldName, t, false)
+		defer p.leave()
+	}
+
 	//we check for the BitsMarshaler
 	processed, err := encMarshaler(v, buf)
 	if err != nil {
@@ -202,7 +257,17 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		return nil
 	}
 
-	endianness, err := getEndianness(tag)
+	//an option for the exact type comes before handling the value by its kind
+	processed, err = encSpecial(fieldName, v, tag, buf, sizeMap, options...)
+	if err != nil {
+		return err
+	}
+
+	if processed {
+		return nil
+	}
+
+	endianness, err := getEndianness(tag, options)
 	if err != nil {
 		return fmt.Errorf("%v: %v", fieldName, err)
 	}
@@ -216,40 +281,17 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return EncodeField(fieldName, t.Elem(), v.Elem(), tag, buf, sizeMap, options...)
 		}
 	case reflect.Interface:
-		for _, enc := range options {
-			if enc.Type() == v.Type() {
-				err := enc.EncoderFunc()(fieldName, v, tag, buf, sizeMap, options...)
-				if err != nil {
-					return err
-				}
-				return nil
-			}
-		}
+		//an option for the interface type is handled above
 		return fmt.Errorf("interface:%v was not found: interface not supported", t.Name())
 	case reflect.Struct:
-		processed, err := encStructSpecial(fieldName, v, tag, buf, sizeMap, options...)
-		if err != nil {
-			return err
-		}
-
-		if processed {
-			return nil
-		}
-
-		m := map[string]int{}
+		m := getSizeMap()
+		defer putSizeMap(m)
 		for k, v := range sizeMap {
 			m[k] = v
 		}
 
-		for i := 0; i < t.NumField(); i++ {
-			sf := t.Field(i)
-			if _, has := sf.Tag.Lookup("omit"); has {
-				continue
-			}
-			err = EncodeField(sf.Name, sf.Type, v.Field(i), sf.Tag, buf, m, options...)
-			if err != nil {
-				return err
-			}
+		if err := encodeFields(t, v, buf, m, options...); err != nil {
+			return err
 		}
 	case reflect.Array:
 		for i := 0; i < v.Len(); i++ {
@@ -331,13 +373,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			tmp := uint64(0)
-			if v.Bool() {
-				tmp = 1
-			}
-			err = bits.WriteUint(buf, bitSize, endianness, tmp)
+			err = writeUint(buf, bitSize, endianness, boolBits(v.Bool()))
 		} else {
-			err = binary.Write(buf, endianness, v.Bool())
+			err = writeUint(buf, 8, endianness, boolBits(v.Bool()))
 		}
 		if err != nil {
 			return fmt.Errorf("%v : %v", fieldName, err)
@@ -350,9 +388,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteUint(buf, bitSize, endianness, v.Uint())
+			err = writeUint(buf, bitSize, endianness, v.Uint())
 		} else {
-			err = binary.Write(buf, endianness, uint8(v.Uint()))
+			err = writeUint(buf, 8, endianness, v.Uint())
 		}
 		if err != nil {
 			return fmt.Errorf("%v : %v", fieldName, err)
@@ -365,9 +403,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteUint(buf, bitSize, endianness, v.Uint())
+			err = writeUint(buf, bitSize, endianness, v.Uint())
 		} else {
-			err = binary.Write(buf, endianness, uint16(v.Uint()))
+			err = writeUint(buf, 16, endianness, v.Uint())
 		}
 		if err != nil {
 			return fmt.Errorf("%v : %v", fieldName, err)
@@ -380,9 +418,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteUint(buf, bitSize, endianness, v.Uint())
+			err = writeUint(buf, bitSize, endianness, v.Uint())
 		} else {
-			err = binary.Write(buf, endianness, uint32(v.Uint()))
+			err = writeUint(buf, 32, endianness, v.Uint())
 		}
 		if err != nil {
 			return fmt.Errorf("%v : %v", fieldName, err)
@@ -395,10 +433,10 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteUint(buf, bitSize, endianness, v.Uint())
+			err = writeUint(buf, bitSize, endianness, v.Uint())
 
 		} else {
-			err = binary.Write(buf, endianness, v.Uint())
+			err = writeUint(buf, 64, endianness, v.Uint())
 		}
 
 		if err != nil {
@@ -412,9 +450,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteInt(buf, bitSize, endianness, v.Int())
+			err = writeInt(buf, bitSize, endianness, v.Int())
 		} else {
-			err = binary.Write(buf, endianness, int8(v.Int()))
+			err = writeUint(buf, 8, endianness, uint64(v.Int()))
 		}
 
 		if err != nil {
@@ -428,9 +466,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteInt(buf, bitSize, endianness, v.Int())
+			err = writeInt(buf, bitSize, endianness, v.Int())
 		} else {
-			err = binary.Write(buf, endianness, int16(v.Int()))
+			err = writeUint(buf, 16, endianness, uint64(v.Int()))
 		}
 
 		if err != nil {
@@ -444,9 +482,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteInt(buf, bitSize, endianness, v.Int())
+			err = writeInt(buf, bitSize, endianness, v.Int())
 		} else {
-			err = binary.Write(buf, endianness, int32(v.Int()))
+			err = writeUint(buf, 32, endianness, uint64(v.Int()))
 		}
 
 		if err != nil {
@@ -460,9 +498,9 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return err
 		}
 		if hasBits {
-			err = bits.WriteInt(buf, bitSize, endianness, v.Int())
+			err = writeInt(buf, bitSize, endianness, v.Int())
 		} else {
-			err = binary.Write(buf, endianness, v.Int())
+			err = writeUint(buf, 64, endianness, uint64(v.Int()))
 		}
 
 		if err != nil {
@@ -473,7 +511,7 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		if has {
 			return fmt.Errorf("bits not supported on float32")
 		}
-		err := binary.Write(buf, endianness, float32(v.Float()))
+		err := writeUint(buf, 32, endianness, uint64(math.Float32bits(float32(v.Float()))))
 		if err != nil {
 			return fmt.Errorf("%v : %v", fieldName, err)
 		}
@@ -482,7 +520,7 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		if has {
 			return fmt.Errorf("bits not supported on float64")
 		}
-		err := binary.Write(buf, endianness, v.Float())
+		err := writeUint(buf, 64, endianness, math.Float64bits(v.Float()))
 		if err != nil {
 			return fmt.Errorf("%v : %v", fieldName, err)
 		}
@@ -493,9 +531,13 @@ func EncodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 	return nil
 }
 
-func getEndianness(tag reflect.StructTag) (binary.ByteOrder, error) {
+//getEndianness returns the endianness from the tag, or when it has none the one from the Defaults in the options.
+func getEndianness(tag reflect.StructTag, options []EncDecOption) (binary.ByteOrder, error) {
 	value, ok := tag.Lookup("endian")
 	if !ok {
+		value = defaultEndian(options)
+	}
+	if value == "" {
 		return binary.LittleEndian, nil
 	}
 	switch value {
@@ -536,35 +578,87 @@ func getBits(tag reflect.StructTag, sizeMap map[string]int, maxLimit, minLimit u
 	return int(value), true, nil
 }
 
+//numberBits is getBits for a number being decoded. When msb is set a number without a bits tag is given all its bits,
+// as it is read bit by bit to take it from the most significant bit of each byte.
+func numberBits(tag reflect.StructTag, sizeMap map[string]int, maxLimit, minLimit uint64, msb bool) (int, bool, error) {
+	numOfBits, hasBits, err := getBits(tag, sizeMap, maxLimit, minLimit)
+	if err == nil && msb && !hasBits {
+		return int(maxLimit), true, nil
+	}
+	return numOfBits, hasBits, err
+}
+
 //Decode is the main function to call to decode struct. To add special decoding use BitsUnmarshaler.
 //  InterfaceEncDec options are available to be passed in to support Interfaces types.
 //  StructEncDec options are also a way to change the behaviour of struct decoding for structs that do/can not implement
 //  BitsUnmarshaler.
 func Decode(data []byte, value interface{}, options ...EncDecOption) error {
+	_, err := DecodeN(data, value, options...)
+	return err
+}
+
+//DecodeN is the same as Decode but also returns the number of bits decoded. With DisallowTrailingData the data may
+// only be followed by the padding bits of the last byte.
+func DecodeN(data []byte, value interface{}, options ...EncDecOption) (int, error) {
 	if data == nil || value == nil {
-		return fmt.Errorf("nil parameters not allowed")
+		return 0, fmt.Errorf("nil parameters not allowed")
 	}
 
 	buf, err := bits.NewFromBytes(data)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return DecodeToBits(buf, value, options...)
+	return decodeToBits(buf, value, 7, options...)
 }
 
 func DecodeToBits(buf *bits.BitSetBuffer, value interface{}, options ...EncDecOption) error {
+	_, err := decodeToBits(buf, value, 0, options...)
+	return err
+}
+
+//DecodeToBitsN is the same as DecodeToBits but also returns the number of bits decoded from buf.
+func DecodeToBitsN(buf *bits.BitSetBuffer, value interface{}, options ...EncDecOption) (int, error) {
+	return decodeToBits(buf, value, 0, options...)
+}
+
+//decodeToBits decodes value from buf, padding is the number of trailing bits allowed with DisallowTrailingData.
+func decodeToBits(buf *bits.BitSetBuffer, value interface{}, padding int, options ...EncDecOption) (n int, err error) {
+	defer recoverError(&err)
+
 	if buf == nil || value == nil {
-		return fmt.Errorf("nil parameters not allowed")
+		return 0, fmt.Errorf("nil parameters not allowed")
+	}
+
+	if err := validateOptions(options...); err != nil {
+		return 0, err
 	}
 
+	options, state, top := withDecodeState(withReadCursor(withFieldPath(options)))
+	cursor := getReadCursor(options)
+	start := cursor.bitPos(buf)
+	err = decodeStruct(buf, value, options...)
+	n = cursor.bitPos(buf) - start
+	if err != nil {
+		return n, err
+	}
+
+	if top && state != nil && state.opts.DisallowTrailingData && state.opts.StopAfter == "" {
+		if remaining := cursor.remaining(buf); remaining > padding {
+			return n, fmt.Errorf("%v bits of trailing data after %v bits decoded", remaining, n)
+		}
+	}
+	return n, nil
+}
+
+func decodeStruct(buf *bits.BitSetBuffer, value interface{}, options ...EncDecOption) error {
 	t := reflect.TypeOf(value)
 	v := reflect.ValueOf(value)
 
 	//we require the struct coming in to be at least pointer to a struct
 	// so we can populate it
 	if t.Kind() != reflect.Ptr {
-		panic("value expected to be a pointer to a structure")
+		return fmt.Errorf("value expected to be a pointer to a structure")
 	}
 
 	//we unwrap until we get to the struct
@@ -572,6 +666,9 @@ loop:
 	for {
 		switch t.Kind() {
 		case reflect.Ptr:
+			if v.IsNil() {
+				return fmt.Errorf("value expected to be a non nil pointer")
+			}
 			t = t.Elem()
 			v = v.Elem()
 		case reflect.Struct:
@@ -588,43 +685,43 @@ loop:
 	}
 
 	if processed {
-		return nil
+		return validateRoot(v)
 	}
 
 	//next we check the options
 	sizeMap := map[string]int{}
-	processed, err = decStructSpecial("", t, v, "", buf, sizeMap, options...)
+	processed, err = decSpecial("", t, v, "", buf, sizeMap, options...)
 	if err != nil {
 		return err
 	}
 
 	if processed {
-		return nil
+		return validateRoot(v)
 	}
 
 	//for the last case we take the struct and unmarshal all the fields
-	for i := 0; i < v.NumField(); i++ {
-		sf := t.Field(i)
-		vf := v.Field(i)
-		if _, has := sf.Tag.Lookup("omit"); has {
-			continue
-		}
-		err := DecodeField(sf.Name, sf.Type, vf, sf.Tag, buf, sizeMap, options...)
-		if err != nil {
-			return err
-		}
+	err = decodeFields(t, v, buf, sizeMap, false, options...)
+	if err != nil && err != errStopDecoding {
+		return err
 	}
 
-	return nil
+	//a partially decoded value is not validated as the fields left out would fail
+	if getDecodeState(options).partial() {
+		return nil
+	}
+	return validateRoot(v)
 }
 
 func decUnmarshaler(v reflect.Value, buf *bits.BitSetBuffer) (bool, error) {
 	modelType := reflect.TypeOf((*BitsUnmarshaler)(nil)).Elem()
 	t := v.Type()
 	var unmarshaler BitsUnmarshaler
-	if t.Implements(modelType) {
+	if !v.CanInterface() || isNil(v) {
+		//unexported fields can not be used and nil values are allocated before decoding
+		return false, nil
+	} else if t.Implements(modelType) {
 		unmarshaler = v.Interface().(BitsUnmarshaler)
-	} else if reflect.PtrTo(t).Implements(modelType) {
+	} else if reflect.PtrTo(t).Implements(modelType) && v.CanAddr() {
 		unmarshaler = v.Addr().Interface().(BitsUnmarshaler)
 	} else {
 		return false, nil
@@ -637,22 +734,49 @@ func decUnmarshaler(v reflect.Value, buf *bits.BitSetBuffer) (bool, error) {
 	return true, nil
 }
 
-func decStructSpecial(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) (bool, error) {
-	for _, dec := range options {
-		if dec.Type() == v.Type() {
-			err := dec.DecoderFunc()(fieldName, t, v, tag, buf, sizeMap, options...)
-			if err != nil {
-				return false, err
-			}
-			return true, nil
-		}
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+//decSpecial decodes v with the option for its type, processed is false when there is none.
+func decSpecial(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) (bool, error) {
+	dec := lookupOption(v.Type(), options)
+	if dec == nil {
+		return false, nil
 	}
-	return false, nil
+	if err := dec.DecoderFunc()(fieldName, t, v, tag, buf, sizeMap, options...); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 //DecodeField should be only if it's part of one of the decode function in one of the options (StructEncDec or InterfaceEncDec).  When
 // called on a field it will do correct decoding. Be careful when calling this function in the options as to avoid recursive explosion.
-func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+// A FieldCodec can use DecodeContext.DecodeField in its place.
+func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) (err error) {
+	if p := getFieldPath(options); p != nil {
+		p.enter(fieldName, t, false)
+		defer p.leave()
+	}
+
+	state := getDecodeState(options)
+	cursor := getReadCursor(options)
+	if state != nil {
+		if err := state.enter(fieldName); err != nil {
+			return err
+		}
+		defer state.leave()
+
+		if state.dump != nil {
+			state.dump.enter(fieldName, t, cursor.bitPos(buf))
+			defer func() { state.dump.leave(v, cursor.bitPos(buf), err) }()
+		}
+	}
+
 	processed, err := decUnmarshaler(v, buf)
 	if err != nil {
 		return err
@@ -662,55 +786,59 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		return nil
 	}
 
-	endianness, err := getEndianness(tag)
+	//an option for the exact type comes before handling the value by its kind
+	if dec := lookupOption(v.Type(), options); dec != nil {
+		if state != nil && state.dump != nil {
+			state.dump.custom()
+		}
+		m := sizeMap
+		if t.Kind() == reflect.Struct {
+			m = make(map[string]int)
+			for k, v := range sizeMap {
+				m[k] = v
+			}
+		}
+		if err := dec.DecoderFunc()(fieldName, t, v, tag, buf, m, options...); err != nil {
+			return err
+		}
+		recordSize(fieldName, v, sizeMap)
+		return nil
+	}
+
+	endianness, err := getEndianness(tag, options)
+	if err != nil {
+		return fmt.Errorf("%v: %v", fieldName, err)
+	}
+	msb, err := isMSB(options)
 	if err != nil {
 		return fmt.Errorf("%v: %v", fieldName, err)
 	}
 
 	switch t.Kind() {
 	case reflect.Ptr:
+		if state.reuse() && !v.IsNil() {
+			return DecodeField(fieldName, t.Elem(), v.Elem(), tag, buf, sizeMap, options...)
+		}
+
+		if err := state.alloc(fieldName, 1, int(t.Elem().Size())); err != nil {
+			return err
+		}
 		val := reflect.New(t.Elem())
 		err := DecodeField(fieldName, t.Elem(), val.Elem(), tag, buf, sizeMap, options...)
-		if err != nil {
+		if err != nil && err != errStopDecoding {
 			return err
 		}
 		v.Set(val)
+		return err
 	case reflect.Interface:
-		for _, enc := range options {
-			if enc.Type() == v.Type() {
-				err := enc.DecoderFunc()(fieldName, t, v, tag, buf, sizeMap, options...)
-				if err != nil {
-					return err
-				}
-				return nil
-			}
-		}
+		//an option for the interface type is handled above
 		return fmt.Errorf("interface:%v was not found: interface not supported", t.Name())
 	case reflect.Struct:
 		m := make(map[string]int)
 		for k, v := range sizeMap {
 			m[k] = v
 		}
-		processed, err := decStructSpecial(fieldName, t, v, tag, buf, m, options...)
-		if err != nil {
-			return err
-		}
-
-		if processed {
-			return nil
-		}
-
-		for i := 0; i < v.NumField(); i++ {
-			sf := t.Field(i)
-			vf := v.Field(i)
-			if _, has := sf.Tag.Lookup("omit"); has {
-				continue
-			}
-			err := DecodeField(sf.Name, sf.Type, vf, sf.Tag, buf, m, options...)
-			if err != nil {
-				return err
-			}
-		}
+		return decodeFields(t, v, buf, m, msb, options...)
 	case reflect.Array:
 		for i := 0; i < v.Len(); i++ {
 			item := v.Index(i)
@@ -722,7 +850,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		all := true
 		suint := 0
 		if s, ok := tag.Lookup("size"); ok {
-			tmp, err := strconv.ParseUint(s, 10, 64)
+			tmp, err := strconv.ParseUint(s, 10, 63)
 			suint = int(tmp)
 			if err != nil {
 				i, has := sizeMap[s]
@@ -738,19 +866,75 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			all = false
 		}
 
-		reflectionValue := reflect.New(t)
-		reflectionValue.Elem().Set(reflect.MakeSlice(t, 0, 10))
-		sliceValuePtr := reflect.ValueOf(reflectionValue.Interface()).Elem()
-		for i := 0; i < suint || (all && !buf.PosAtEnd()); i++ {
-			item := reflect.New(t.Elem())
-			if err := DecodeField("", item.Elem().Type(), item.Elem(), tag, buf, sizeMap, options...); err != nil {
+		//the existing items are reused when they can hold all the items, otherwise when the size is known exactly
+		// that many are allocated
+		reuse := state.reuse() && !v.IsNil() && (all || v.Cap() >= suint)
+		if !all {
+			if err := state.checkSliceLen(fieldName, suint); err != nil {
 				return err
 			}
+			if err := checkFits(fieldName, suint, t.Elem(), tag, buf, options); err != nil {
+				return err
+			}
+			if !reuse {
+				if err := state.alloc(fieldName, suint, int(t.Elem().Size())); err != nil {
+					return err
+				}
+			}
+		}
+
+		var slice reflect.Value
+		switch {
+		case reuse:
+			slice = v.Slice(0, 0)
+		case all:
+			slice = reflect.MakeSlice(t, 0, 10)
+		default:
+			//the size comes from the data, so no more items are made than the bits left can hold, the rest are appended
+			capacity := suint
+			if limit := cursor.remaining(buf) / maxInt(minBits(t.Elem(), tag, options, nil), 1); capacity > limit {
+				capacity = limit
+			}
+			slice = reflect.MakeSlice(t, 0, capacity)
+		}
+		for i := 0; i < suint || (all && !buf.PosAtEnd()); i++ {
+			inPlace := i < slice.Cap()
+			if all {
+				if err := state.checkSliceLen(fieldName, i+1); err != nil {
+					return err
+				}
+				if !inPlace || !reuse {
+					if err := state.alloc(fieldName, 1, int(t.Elem().Size())); err != nil {
+						return err
+					}
+				}
+			}
+
+			var item reflect.Value
+			if inPlace {
+				slice = slice.Slice(0, i+1)
+				item = slice.Index(i)
+			} else {
+				item = reflect.New(t.Elem()).Elem()
+			}
 
-			sliceValuePtr.Set(reflect.Append(sliceValuePtr, item.Elem()))
+			start := cursor.bitPos(buf)
+			err := DecodeField("", t.Elem(), item, tag, buf, sizeMap, options...)
+			if err == nil && all && cursor.bitPos(buf) == start {
+				return fmt.Errorf("%v: items that decode from no bits need a size", fieldName)
+			}
+			if !inPlace {
+				slice = reflect.Append(slice, item)
+			}
+			if err == errStopDecoding {
+				v.Set(slice)
+			}
+			if err != nil {
+				return err
+			}
 		}
 
-		v.Set(sliceValuePtr)
+		v.Set(slice)
 	case reflect.String:
 		all := true
 		suint := uint64(0)
@@ -773,39 +957,47 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		}
 
 		if all {
-			sb := strings.Builder{}
+			//without a strlen the string takes the rest of the buffer
+			suint = uint64(cursor.remaining(buf)+7) / 8
+		} else if remaining := cursor.remaining(buf); suint > uint64(remaining/8) {
+			return fmt.Errorf("%v: strlen of %v does not fit in the %v bits remaining", fieldName, suint, remaining)
+		}
 
-			for {
-				bs := make([]byte, suint)
-				n, err := buf.Read(bs)
+		if err := state.checkStringLen(fieldName, suint); err != nil {
+			return err
+		}
+		if err := state.alloc(fieldName, int(suint), 1); err != nil {
+			return err
+		}
+
+		if all {
+			bs := make([]byte, suint)
+			if suint > 0 {
+				n, err := readByteData(buf, cursor, bs, msb)
 				if err != nil {
 					return fmt.Errorf("%v: %v", fieldName, err)
 				}
-				if n != len(bs) {
-					sb.Write(bs[:n])
-					break
-				}
-				sb.Write(bs)
+				bs = bs[:n]
 			}
-
-			v.SetString(sb.String())
+			v.SetString(string(bs))
 		} else {
 			bs := make([]byte, suint)
-			err := binary.Read(buf, endianness, bs)
+			//the strlen was checked to fit above
+			_, err := readByteData(buf, cursor, bs, msb)
 			if err != nil {
 				return fmt.Errorf("%v: %v", fieldName, err)
 			}
 			v.SetString(string(bs))
 		}
 	case reflect.Bool:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 8, 0)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 8, 0, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
 		var x bool
 		if hasBits {
-			tmp, err := bits.ReadUint(buf, numOfBits, endianness)
+			tmp, err := readOrderedUint(buf, cursor, numOfBits, endianness, msb)
 			if err != nil {
 				return fmt.Errorf("%v: %v", fieldName, err)
 			}
@@ -818,7 +1010,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 
 		v.SetBool(x)
 	case reflect.Uint8:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 8, 0)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 8, 0, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
@@ -826,7 +1018,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		var x uint8
 		if hasBits {
 			var tmp uint64
-			tmp, err = bits.ReadUint(buf, numOfBits, endianness)
+			tmp, err = readOrderedUint(buf, cursor, numOfBits, endianness, msb)
 			x = uint8(tmp)
 		} else {
 			err = binary.Read(buf, endianness, &x)
@@ -835,10 +1027,14 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
+		if err := checkFlags(t, uint64(x)); err != nil {
+			return fmt.Errorf("%v: %v", fieldName, err)
+		}
+
 		sizeMap[fieldName] = int(x)
 		v.SetUint(uint64(x))
 	case reflect.Uint16:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 16, 0)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 16, 0, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
@@ -846,7 +1042,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		var x uint16
 		if hasBits {
 			var tmp uint64
-			tmp, err = bits.ReadUint(buf, numOfBits, endianness)
+			tmp, err = readOrderedUint(buf, cursor, numOfBits, endianness, msb)
 			x = uint16(tmp)
 		} else {
 			err = binary.Read(buf, endianness, &x)
@@ -855,10 +1051,14 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
+		if err := checkFlags(t, uint64(x)); err != nil {
+			return fmt.Errorf("%v: %v", fieldName, err)
+		}
+
 		sizeMap[fieldName] = int(x)
 		v.SetUint(uint64(x))
 	case reflect.Uint32:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 32, 0)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 32, 0, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
@@ -866,7 +1066,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		var x uint32
 		if hasBits {
 			var tmp uint64
-			tmp, err = bits.ReadUint(buf, numOfBits, endianness)
+			tmp, err = readOrderedUint(buf, cursor, numOfBits, endianness, msb)
 			x = uint32(tmp)
 		} else {
 			err = binary.Read(buf, endianness, &x)
@@ -875,17 +1075,21 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
+		if err := checkFlags(t, uint64(x)); err != nil {
+			return fmt.Errorf("%v: %v", fieldName, err)
+		}
+
 		sizeMap[fieldName] = int(x)
 		v.SetUint(uint64(x))
 	case reflect.Uint64:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 64, 0)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 64, 0, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
 		var x uint64
 		if hasBits {
-			x, err = bits.ReadUint(buf, numOfBits, endianness)
+			x, err = readOrderedUint(buf, cursor, numOfBits, endianness, msb)
 		} else {
 			err = binary.Read(buf, endianness, &x)
 		}
@@ -893,10 +1097,14 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
+		if err := checkFlags(t, uint64(x)); err != nil {
+			return fmt.Errorf("%v: %v", fieldName, err)
+		}
+
 		sizeMap[fieldName] = int(x)
 		v.SetUint(x)
 	case reflect.Int8:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 8, 2)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 8, 2, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
@@ -904,7 +1112,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		var x int8
 		if hasBits {
 			var tmp int64
-			tmp, err = bits.ReadInt(buf, numOfBits, endianness)
+			tmp, err = readOrderedInt(buf, cursor, numOfBits, endianness, msb)
 			x = int8(tmp)
 		} else {
 			err = binary.Read(buf, endianness, &x)
@@ -916,7 +1124,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		sizeMap[fieldName] = int(x)
 		v.SetInt(int64(x))
 	case reflect.Int16:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 16, 2)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 16, 2, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
@@ -924,7 +1132,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		var x int16
 		if hasBits {
 			var tmp int64
-			tmp, err = bits.ReadInt(buf, numOfBits, endianness)
+			tmp, err = readOrderedInt(buf, cursor, numOfBits, endianness, msb)
 			x = int16(tmp)
 		} else {
 			err = binary.Read(buf, endianness, &x)
@@ -936,7 +1144,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		sizeMap[fieldName] = int(x)
 		v.SetInt(int64(x))
 	case reflect.Int32:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 32, 2)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 32, 2, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
@@ -944,7 +1152,7 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		var x int32
 		if hasBits {
 			var tmp int64
-			tmp, err = bits.ReadInt(buf, numOfBits, endianness)
+			tmp, err = readOrderedInt(buf, cursor, numOfBits, endianness, msb)
 			x = int32(tmp)
 		} else {
 			err = binary.Read(buf, endianness, &x)
@@ -956,14 +1164,14 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		sizeMap[fieldName] = int(x)
 		v.SetInt(int64(x))
 	case reflect.Int64:
-		numOfBits, hasBits, err := getBits(tag, sizeMap, 64, 2)
+		numOfBits, hasBits, err := numberBits(tag, sizeMap, 64, 2, msb)
 		if err != nil {
 			return fmt.Errorf("%v: %v", fieldName, err)
 		}
 
 		var x int64
 		if hasBits {
-			x, err = bits.ReadInt(buf, numOfBits, endianness)
+			x, err = readOrderedInt(buf, cursor, numOfBits, endianness, msb)
 		} else {
 			err = binary.Read(buf, endianness, &x)
 		}
@@ -980,7 +1188,13 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		}
 
 		var x float32
-		if err := binary.Read(buf, endianness, &x); err != nil {
+		if msb {
+			tmp, err := readOrderedUint(buf, cursor, 32, endianness, msb)
+			if err != nil {
+				return fmt.Errorf("expected to read float32 from %v: %v", fieldName, err)
+			}
+			x = math.Float32frombits(uint32(tmp))
+		} else if err := binary.Read(buf, endianness, &x); err != nil {
 			return fmt.Errorf("expected to read float32 from %v: %v", fieldName, err)
 		}
 
@@ -992,7 +1206,13 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 		}
 
 		var x float64
-		if err := binary.Read(buf, endianness, &x); err != nil {
+		if msb {
+			tmp, err := readOrderedUint(buf, cursor, 64, endianness, msb)
+			if err != nil {
+				return fmt.Errorf("expected to read float64 from %v: %v", fieldName, err)
+			}
+			x = math.Float64frombits(tmp)
+		} else if err := binary.Read(buf, endianness, &x); err != nil {
 			return fmt.Errorf("expected to read float64 from %v: %v", fieldName, err)
 		}
 
@@ -1004,12 +1224,27 @@ func DecodeField(fieldName string, t reflect.Type, v reflect.Value, tag reflect.
 	return nil
 }
 
-//SizeOf returns the minimum number of bytes needed to serialize the structure
+//SizeOf returns the minimum number of bytes needed to serialize the structure. It panics when v can not be encoded.
+//
+// Deprecated: use BitSizeOf, which returns the error instead of panicking, and round its bits up to bytes.
 func SizeOf(v interface{}, options ...EncDecOption) int {
-	bs, err := Encode(v, options...)
+	n, err := BitSizeOf(v, options...)
 	if err != nil {
 		panic(err)
 	}
 
-	return len(bs)
+	return (n + 7) / 8
+}
+
+//panicPrefix starts the errors recoverError makes from a panic. A panic is still a bug, in this package or in an
+// option, and the fuzz tests fail on them.
+const panicPrefix = "recovered from panic"
+
+//recoverError turns a panic into an error, so no input, however malformed, can crash the caller. It is only deferred
+// by the functions callers start from, never by EncodeField and DecodeField, so a panic is not hidden from the calls
+// in between.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%v: %v", panicPrefix, r)
+	}
 }
@@ -3,6 +3,7 @@ package binary
 import (
 	"encoding/binary"
 	"fmt"
+	"net"
 	bits "github.com/nathanhack/bitsetbuffer"
 	"reflect"
 	"strconv"
@@ -695,7 +696,7 @@ func TestSizeOf(t *testing.T) {
 		I11 uint16   `bits:"1"`
 		I12 uint32   `bits:"1"`
 		I13 uint64   `bits:"1"`
-		I14 string   `strlen:"1"'`
+		I14 string   `strlen:"1"`
 		I15 []uint8  `size:"1"`
 		I16 []uint64 `size:"1"`
 		I17 string   `strlen:"I6"`
@@ -1085,3 +1086,275 @@ func TestSignedBits2(t *testing.T) {
 		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
 	}
 }
+
+func TestDecodeN(t *testing.T) {
+	type structure struct {
+		V0 uint8
+		V1 uint16 `bits:"12"`
+	}
+
+	var actual structure
+	n, err := DecodeN([]byte{1, 0x23, 0x01}, &actual)
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	if n != 20 {
+		t.Fatalf("expected 20 bits decoded but found %v", n)
+	}
+
+	expected := structure{V0: 1, V1: 0x123}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
+	}
+
+	//the padding of the last byte is not trailing data
+	_, err = DecodeN([]byte{1, 0x23, 0x01}, &actual, DisallowTrailingData())
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+
+	_, err = DecodeN([]byte{1, 0x23, 0x01, 0}, &actual)
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+
+	n, err = DecodeN([]byte{1, 0x23, 0x01, 0}, &actual, DisallowTrailingData())
+	if err == nil {
+		t.Fatalf("expected a trailing data error")
+	}
+	if n != 20 {
+		t.Fatalf("expected 20 bits decoded but found %v", n)
+	}
+
+	//with a bit buffer every bit counts
+	buf, err := bits.NewFromBytes([]byte{1, 0x23, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = DecodeToBitsN(buf, &actual, DisallowTrailingData())
+	if err == nil {
+		t.Fatalf("expected a trailing data error")
+	}
+	if n != 20 {
+		t.Fatalf("expected 20 bits decoded but found %v", n)
+	}
+}
+
+func TestDecodeUnsizedString(t *testing.T) {
+	type structure struct {
+		V0 uint8
+		V1 string
+	}
+
+	for _, expected := range []structure{{1, "Hello"}, {2, ""}} {
+		bs, err := Encode(expected)
+		if err != nil {
+			t.Fatalf("expected no encoding error found: %v", err)
+		}
+
+		var actual structure
+		err = Decode(bs, &actual)
+		if err != nil {
+			t.Fatalf("expected no decoding error found: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, actual)
+		}
+	}
+}
+
+func TestNoPanics(t *testing.T) {
+	type hidden struct {
+		A       uint8
+		private uint16
+		B       uint8
+	}
+	type wrapped struct {
+		Inner *hidden
+	}
+
+	var nilHidden *hidden
+	var value hidden
+	tests := []func() error{
+		func() error { return DecodeToBits(&bits.BitSetBuffer{}, value) },
+		func() error { return Decode([]byte{1, 2, 3, 4}, nilHidden) },
+		func() error { _, err := Encode(nilHidden); return err },
+		func() error { _, err := BitSizeOf(3); return err },
+		func() error { return Skip(&bits.BitSetBuffer{}, reflect.TypeOf(value)) },
+	}
+	for i, test := range tests {
+		if err := test(); err == nil {
+			t.Fatalf("%v: expected an error", i)
+		}
+	}
+
+	//unexported fields are skipped over on decode
+	err := Decode([]byte{1, 2, 3, 4}, &value)
+	if err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+	expected := hidden{A: 1, B: 4}
+	if !reflect.DeepEqual(expected, value) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", expected, value)
+	}
+
+	//nil pointers encode as their zero value
+	bs, err := Encode(wrapped{})
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+	if !reflect.DeepEqual(bs, []byte{0, 0, 0, 0}) {
+		t.Fatalf("expected 4 zero bytes but found %x", bs)
+	}
+}
+
+type celsius int32
+
+type uuid [16]byte
+
+//tenths encodes celsius as a big endian uint16 of tenths of a degree above -100.
+var tenths = &TypeEncDec{
+	ValueType: reflect.TypeOf(celsius(0)),
+	Encoder: func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+		return binary.Write(buf, binary.BigEndian, uint16((v.Int()+100)*10))
+	},
+	Decoder: func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+		var n uint16
+		if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+			return err
+		}
+		v.SetInt(int64(n)/10 - 100)
+		return nil
+	},
+}
+
+//ipv4 encodes net.IP as its 4 bytes.
+var ipv4 = &TypeEncDec{
+	ValueType: reflect.TypeOf(net.IP{}),
+	Encoder: func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+		_, err := buf.Write(v.Interface().(net.IP).To4())
+		return err
+	},
+	Decoder: func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+		b := make([]byte, 4)
+		if _, err := buf.Read(b); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(net.IPv4(b[0], b[1], b[2], b[3])))
+		return nil
+	},
+}
+
+//reversed encodes uuid with its bytes in reverse order.
+var reversed = &TypeEncDec{
+	ValueType: reflect.TypeOf(uuid{}),
+	Encoder: func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+		id := v.Interface().(uuid)
+		for i := len(id) - 1; i >= 0; i-- {
+			if _, err := buf.Write([]byte{id[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Decoder: func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+		var id uuid
+		if _, err := buf.Read(id[:]); err != nil {
+			return err
+		}
+		for i, j := 0, len(id)-1; i < j; i, j = i+1, j-1 {
+			id[i], id[j] = id[j], id[i]
+		}
+		v.Set(reflect.ValueOf(id))
+		return nil
+	},
+}
+
+func TestTypeEncDec(t *testing.T) {
+	type reading struct {
+		ID       uuid
+		Addr     net.IP
+		Temps    [2]celsius
+		Last     *celsius
+		Count    uint8
+		Previous []celsius `size:"Count"`
+	}
+	last := celsius(-5)
+	input := reading{
+		ID:       uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Addr:     net.IPv4(10, 0, 0, 1),
+		Temps:    [2]celsius{21, -40},
+		Last:     &last,
+		Count:    1,
+		Previous: []celsius{0},
+	}
+	expected := []byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 10, 0, 0, 1, 0x04, 0xba, 0x02, 0x58, 0x03, 0xb6, 1, 0x03, 0xe8}
+
+	options := []EncDecOption{tenths, ipv4, reversed}
+	bs, err := Encode(&input, options...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual reading
+	if err := Decode(bs, &actual, options...); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", input, actual)
+	}
+
+	//a codec finds them the same
+	codec, err := NewCodec(options...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if bs, err = codec.Encode(&input); err != nil || !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n %v", expected, bs, err)
+	}
+
+	//skipping over them decodes them with the option
+	var partial reading
+	if err := Decode(bs, &partial, append(options, OnlyFields("Previous"))...); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input.Previous, partial.Previous) || partial.Addr != nil {
+		t.Fatalf("expected only Previous but found %#v", partial)
+	}
+	if _, fixed := StaticBitSize(reflect.TypeOf(reading{}), options...); fixed {
+		t.Fatalf("expected no static size with custom encoders")
+	}
+
+	//integers encoded by an option may size the fields after them
+	type sized struct {
+		Count celsius
+		Data  []byte `size:"Count"`
+	}
+	bs, err = Encode(&sized{Count: 2, Data: []byte{7, 8}}, options...)
+	if err != nil || !reflect.DeepEqual([]byte{0x03, 0xfc, 7, 8}, bs) {
+		t.Fatalf("expected the data after the count but found %x %v", bs, err)
+	}
+	var sizedActual sized
+	if err := Decode(bs, &sizedActual, options...); err != nil || !reflect.DeepEqual(sized{Count: 2, Data: []byte{7, 8}}, sizedActual) {
+		t.Fatalf("expected the data after the count but found %#v %v", sizedActual, err)
+	}
+
+	//without the options they are encoded by their kind
+	bs, err = Encode(&struct{ T celsius }{21})
+	if err != nil || !reflect.DeepEqual([]byte{21, 0, 0, 0}, bs) {
+		t.Fatalf("expected the int32 encoding but found %x %v", bs, err)
+	}
+}
+
+func TestTypeEncDecErrors(t *testing.T) {
+	if _, err := Encode(&struct{ T celsius }{}, &TypeEncDec{Encoder: tenths.Encoder, Decoder: tenths.Decoder}); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := Encode(&struct{ T celsius }{}, &TypeEncDec{ValueType: tenths.ValueType, Decoder: tenths.Decoder}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
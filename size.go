@@ -0,0 +1,40 @@
+package binary
+
+import (
+	"reflect"
+)
+
+//StaticBitSize returns the number of bits every value of t encodes into, computed from the tags alone. The bool is
+// false when the size depends on the value, e.g. a slice sized by another field, or on custom encoding.
+func StaticBitSize(t reflect.Type, options ...EncDecOption) (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+	return staticBits(t, "", options)
+}
+
+//BitSizeOf returns the number of bits v encodes into. The value is walked the same as Encode but nothing is written.
+func BitSizeOf(v interface{}, options ...EncDecOption) (n int, err error) {
+	defer recoverError(&err)
+
+	counter := &bitCounter{}
+	if err := encodeStruct(counter, v, options...); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+//bitCounter is a BitSetWriter that only counts the bits written to it.
+type bitCounter struct {
+	n int
+}
+
+func (c *bitCounter) Write(bytes []byte) (int, error) {
+	c.n += 8 * len(bytes)
+	return len(bytes), nil
+}
+
+func (c *bitCounter) WriteBits(bits []bool) (int, error) {
+	c.n += len(bits)
+	return len(bits), nil
+}
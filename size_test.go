@@ -0,0 +1,59 @@
+package binary
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStaticBitSize(t *testing.T) {
+	tests := []struct {
+		t        reflect.Type
+		expected int
+		fixed    bool
+	}{
+		{reflect.TypeOf(ipv4Header{}), 160, true},
+		{reflect.TypeOf(&ipv4Header{}), 160, true},
+		{reflect.TypeOf(routedHeader{}), 16, true},
+		{reflect.TypeOf(validatedHeader{}), 72, true},
+		{reflect.TypeOf(routedMessage{}), 0, false},
+		{reflect.TypeOf(fuzzSized{}), 0, false},
+		{reflect.TypeOf(struct{ I interface{} }{}), 0, false},
+	}
+
+	for _, test := range tests {
+		actual, fixed := StaticBitSize(test.t)
+		if actual != test.expected || fixed != test.fixed {
+			t.Fatalf("%v: expected %v %v but found %v %v", test.t, test.expected, test.fixed, actual, fixed)
+		}
+	}
+}
+
+func TestBitSizeOf(t *testing.T) {
+	tests := []interface{}{
+		ipv4Header{},
+		routedInput(),
+		&fuzzSized{Count: 2, Items: []int16{1, 2}, Length: 1, Name: "a", Rest: []byte{1, 2, 3}},
+		struct {
+			A uint8 `bits:"3"`
+		}{},
+	}
+
+	for _, test := range tests {
+		buf, err := EncodeToBits(test)
+		if err != nil {
+			t.Fatalf("expected no encoding error found: %v", err)
+		}
+
+		actual, err := BitSizeOf(test)
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		if actual != len(buf.Set) {
+			t.Fatalf("%T: expected %v but found %v", test, len(buf.Set), actual)
+		}
+	}
+
+	if _, err := BitSizeOf(struct{ I interface{} }{}); err == nil {
+		t.Fatalf("expected an error for an interface without options")
+	}
+}
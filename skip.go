@@ -0,0 +1,409 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+var (
+	marshalerType   = reflect.TypeOf((*BitsMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*BitsUnmarshaler)(nil)).Elem()
+)
+
+//bitPos returns the read position of buf. BitSetBuffer does not export its position, so it is found by reading from
+// copies of buf cut short at different lengths: a copy cut after the position has a bit left to read and one cut at or
+// before it has none.
+func bitPos(buf *bits.BitSetBuffer) int {
+	return searchPos(buf, 0, len(buf.Set))
+}
+
+//before reports if the read position of buf is at or before i, a bit of buf.
+func before(buf *bits.BitSetBuffer, i int) bool {
+	var bit [1]bool
+	cut := *buf
+	cut.Set = buf.Set[:i+1]
+	n, _ := cut.ReadBits(bit[:])
+	return n == 1
+}
+
+//searchPos returns the read position of buf, which is known to be from low to high.
+func searchPos(buf *bits.BitSetBuffer, low, high int) int {
+	for low < high {
+		mid := int(uint(low+high) >> 1)
+		if before(buf, mid) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low
+}
+
+//remainingBits returns the number of bits left to be read from buf.
+func remainingBits(buf *bits.BitSetBuffer) int {
+	return len(buf.Set) - bitPos(buf)
+}
+
+//readCursor keeps the read position last found in the buffer being decoded. Decoding mostly moves forward a few bits
+// at a time, so the position is searched for from there, taking a couple of reads instead of a search of the whole
+// buffer. The position is checked each time, so reads it does not see, e.g. by custom decoding, are found all the same.
+type readCursor struct {
+	buf *bits.BitSetBuffer
+	pos int
+}
+
+func (c *readCursor) Type() reflect.Type {
+	return nil
+}
+
+func (c *readCursor) EncoderFunc() func(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (c *readCursor) DecoderFunc() func(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	return nil
+}
+
+func (c *readCursor) setting() {}
+
+//withReadCursor returns the options with a readCursor added, unless they already hold one from an outer call.
+func withReadCursor(options []EncDecOption) []EncDecOption {
+	if getReadCursor(options) != nil {
+		return options
+	}
+	return append(options[:len(options):len(options)], &readCursor{})
+}
+
+func getReadCursor(options []EncDecOption) *readCursor {
+	for _, item := range options {
+		if c, ok := item.(*readCursor); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+//bitPos returns the read position of buf, c may be nil.
+func (c *readCursor) bitPos(buf *bits.BitSetBuffer) int {
+	if c == nil {
+		return bitPos(buf)
+	}
+	if c.buf != buf || c.pos > len(buf.Set) {
+		c.buf, c.pos = buf, 0
+	}
+
+	switch {
+	case c.pos < len(buf.Set) && before(buf, c.pos):
+		//the position went back, e.g. from a Peek sharing the cursor
+		if c.pos > 0 && before(buf, c.pos-1) {
+			c.pos = searchPos(buf, 0, c.pos)
+		}
+	default:
+		//the position is past the one kept, the steps double until they reach it
+		low, probe, step := c.pos+1, c.pos+1, 1
+		for probe < len(buf.Set) && !before(buf, probe) {
+			low = probe + 1
+			probe += step
+			step *= 2
+		}
+		if probe > len(buf.Set) {
+			probe = len(buf.Set)
+		}
+		if low > probe {
+			low = probe
+		}
+		c.pos = searchPos(buf, low, probe)
+	}
+	return c.pos
+}
+
+//remaining returns the number of bits left to be read from buf, c may be nil.
+func (c *readCursor) remaining(buf *bits.BitSetBuffer) int {
+	return len(buf.Set) - c.bitPos(buf)
+}
+
+//skipBits advances buf by n bits, cursor may be nil.
+func skipBits(buf *bits.BitSetBuffer, cursor *readCursor, n int) error {
+	if remaining := cursor.remaining(buf); n > remaining {
+		return fmt.Errorf("expected to skip %v bits but only %v remain", n, remaining)
+	}
+
+	var scratch [256]bool
+	for n > 0 {
+		chunk := n
+		if chunk > len(scratch) {
+			chunk = len(scratch)
+		}
+		read, err := buf.ReadBits(scratch[:chunk])
+		if err != nil {
+			return err
+		}
+		if read != chunk {
+			return fmt.Errorf("only %v of %v bits read", read, chunk)
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+//hasCustomCoding reports if values of t are encoded or decoded by something other than their tags.
+func hasCustomCoding(t reflect.Type, options []EncDecOption) bool {
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) ||
+		t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType) {
+		return true
+	}
+	return lookupOption(t, options) != nil
+}
+
+//staticBits returns the number of bits a value of t with the tag is encoded into, if that number is the same for
+// every value.
+func staticBits(t reflect.Type, tag reflect.StructTag, options []EncDecOption) (int, bool) {
+	return staticBitsOf(t, tag, options, nil)
+}
+
+//staticBitsOf is staticBits where structs are the structs being walked, a struct holding itself has no static size.
+func staticBitsOf(t reflect.Type, tag reflect.StructTag, options []EncDecOption, structs []reflect.Type) (int, bool) {
+	if hasCustomCoding(t, options) {
+		return 0, false
+	}
+
+	literal := func(key string) (int, bool, bool) {
+		s, has := tag.Lookup(key)
+		if !has {
+			return 0, false, true
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return 0, true, false
+		}
+		return int(n), true, true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, has, ok := literal("bits")
+		if !ok {
+			return 0, false
+		}
+		if has {
+			return n, true
+		}
+		if t.Kind() == reflect.Bool {
+			return 8, true
+		}
+		return t.Bits(), true
+	case reflect.Float32, reflect.Float64:
+		return t.Bits(), true
+	case reflect.String:
+		n, has, ok := literal("strlen")
+		if !ok || !has {
+			return 0, false
+		}
+		return 8 * n, true
+	case reflect.Ptr:
+		return staticBitsOf(t.Elem(), tag, options, structs)
+	case reflect.Array:
+		n, ok := staticBitsOf(t.Elem(), tag, options, structs)
+		if !ok {
+			return 0, false
+		}
+		return n * t.Len(), true
+	case reflect.Slice:
+		size, has, ok := literal("size")
+		if !ok || !has {
+			return 0, false
+		}
+		n, ok := staticBitsOf(t.Elem(), tag, options, structs)
+		if !ok {
+			return 0, false
+		}
+		return n * size, true
+	case reflect.Struct:
+		for _, s := range structs {
+			if s == t {
+				return 0, false
+			}
+		}
+		structs = append(structs, t)
+
+		info := getStructInfo(t)
+		if info.err != nil {
+			return 0, false
+		}
+		total := 0
+		for _, sf := range info.fields {
+			n, ok := staticBitsOf(sf.Type, sf.Tag, options, structs)
+			if !ok {
+				return 0, false
+			}
+			total += n
+		}
+		return total, true
+	}
+	return 0, false
+}
+
+//minBits returns the fewest bits a value of t with the tag decodes from, 0 when it may be none. Like staticBitsOf,
+// structs are the structs being walked.
+func minBits(t reflect.Type, tag reflect.StructTag, options []EncDecOption, structs []reflect.Type) int {
+	if n, ok := staticBitsOf(t, tag, options, structs); ok {
+		return n
+	}
+	if hasCustomCoding(t, options) {
+		return 0
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return minBits(t.Elem(), tag, options, structs)
+	case reflect.Array:
+		return t.Len() * minBits(t.Elem(), tag, options, structs)
+	case reflect.Struct:
+		for _, s := range structs {
+			if s == t {
+				return 0
+			}
+		}
+		structs = append(structs, t)
+
+		info := getStructInfo(t)
+		if info.err != nil {
+			return 0
+		}
+		total := 0
+		for _, sf := range info.fields {
+			total += minBits(sf.Type, sf.Tag, options, structs)
+		}
+		return total
+	}
+	//integers with their bits from a field, and strings and slices with their size from one, may have none
+	return 0
+}
+
+//maxInt returns the larger of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+//skipField advances buf past a value of t without setting any value. Integers are still read so that the fields after
+// them can use their values for sizes.
+func skipField(fieldName string, t reflect.Type, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	if p := getFieldPath(options); p != nil {
+		p.enter(fieldName, t, true)
+		defer p.leave()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.String:
+		if n, ok := staticBits(t, tag, options); ok {
+			return skipBits(buf, getReadCursor(options), n)
+		}
+	}
+
+	if hasCustomCoding(t, options) {
+		return DecodeField(fieldName, t, reflect.New(t).Elem(), tag, buf, sizeMap, options...)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return skipField(fieldName, t.Elem(), tag, buf, sizeMap, options...)
+	case reflect.Struct:
+		m := make(map[string]int)
+		for k, v := range sizeMap {
+			m[k] = v
+		}
+
+		fields, options, err := fieldsOf(t, options)
+		if err != nil {
+			return err
+		}
+		for _, sf := range fields {
+			if err := skipField(sf.Name, sf.Type, sf.Tag, buf, m, options...); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			if err := skipField("", t.Elem(), tag, buf, sizeMap, options...); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.String:
+		key := "size"
+		if t.Kind() == reflect.String {
+			key = "strlen"
+		}
+		s, has := tag.Lookup(key)
+		if !has {
+			//without a size the value takes the rest of the buffer
+			cursor := getReadCursor(options)
+			return skipBits(buf, cursor, cursor.remaining(buf))
+		}
+
+		count, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			i, has := sizeMap[s]
+			switch {
+			case !has:
+				return fmt.Errorf("%v must either be a positive number or a field found prior to this field :%v", key, err)
+			case i < 0:
+				return fmt.Errorf("value of %v is %v, to be used for %v it must be nonnegative", s, i, key)
+			}
+			count = uint64(i)
+		}
+
+		n, ok := 8, true
+		if t.Kind() == reflect.Slice {
+			n, ok = staticBits(t.Elem(), tag, options)
+		}
+		if ok {
+			cursor := getReadCursor(options)
+			if remaining := cursor.remaining(buf); n > 0 && count > uint64(remaining/n) {
+				return fmt.Errorf("%v: %v items do not fit in the %v bits remaining", fieldName, count, remaining)
+			}
+			return skipBits(buf, cursor, n*int(count))
+		}
+		for i := uint64(0); i < count; i++ {
+			if err := skipField("", t.Elem(), tag, buf, sizeMap, options...); err != nil {
+				return err
+			}
+		}
+	default:
+		return DecodeField(fieldName, t, reflect.New(t).Elem(), tag, buf, sizeMap, options...)
+	}
+	return nil
+}
+
+//Peek decodes value from buf the same as DecodeToBits but leaves the position of buf unchanged.
+func Peek(buf *bits.BitSetBuffer, value interface{}, options ...EncDecOption) error {
+	if buf == nil || value == nil {
+		return fmt.Errorf("nil parameters not allowed")
+	}
+
+	//the copy shares the bits but has its own position
+	peek := *buf
+	return DecodeToBits(&peek, value, options...)
+}
+
+//Skip advances buf past a value of type t without constructing it. Fixed size parts are skipped using their sizes and
+// only the integers needed for the sizes of later fields are read.
+func Skip(buf *bits.BitSetBuffer, t reflect.Type, options ...EncDecOption) (err error) {
+	defer recoverError(&err)
+
+	if buf == nil || t == nil {
+		return fmt.Errorf("nil parameters not allowed")
+	}
+
+	if err := validateOptions(options...); err != nil {
+		return err
+	}
+
+	return skipField("", t, "", buf, map[string]int{}, withReadCursor(withFieldPath(options))...)
+}
@@ -0,0 +1,164 @@
+package binary
+
+import (
+	"reflect"
+	"testing"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+func TestPeekAndSkip(t *testing.T) {
+	type header struct {
+		Kind   uint8
+		Length uint16
+	}
+	type frame struct {
+		Kind   uint8
+		Length uint16
+		Data   []byte `size:"Length"`
+	}
+
+	var input []byte
+	for _, f := range []frame{
+		{1, 2, []byte{1, 2}},
+		{2, 3, []byte{3, 4, 5}},
+		{1, 1, []byte{6}},
+	} {
+		bs, err := Encode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input = append(input, bs...)
+	}
+
+	buf, err := bits.NewFromBytes(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kept [][]byte
+	for !buf.PosAtEnd() {
+		var h header
+		if err := Peek(buf, &h); err != nil {
+			t.Fatalf("expected no peek error found: %v", err)
+		}
+
+		if h.Kind != 1 {
+			if err := Skip(buf, reflect.TypeOf(frame{})); err != nil {
+				t.Fatalf("expected no skip error found: %v", err)
+			}
+			continue
+		}
+
+		var f frame
+		if err := DecodeToBits(buf, &f); err != nil {
+			t.Fatalf("expected no decoding error found: %v", err)
+		}
+		if f.Kind != h.Kind || f.Length != h.Length {
+			t.Fatalf("expected peeked header %v but found %v", h, f)
+		}
+		kept = append(kept, f.Data)
+	}
+
+	expected := [][]byte{{1, 2}, {6}}
+	if !reflect.DeepEqual(expected, kept) {
+		t.Fatalf("expected %v but found %v", expected, kept)
+	}
+}
+
+func TestSkipSizes(t *testing.T) {
+	type inner struct {
+		V0 uint8 `bits:"3"`
+		V1 [2]uint16
+	}
+	type outer struct {
+		V0 inner
+		V1 string `strlen:"2"`
+		V2 uint8
+		V3 []uint32 `size:"V2"`
+		V4 []inner  `size:"2"`
+	}
+
+	n, ok := staticBits(reflect.TypeOf(inner{}), "", nil)
+	if !ok || n != 35 {
+		t.Fatalf("expected a static size of 35 but found %v %v", n, ok)
+	}
+	if _, ok := staticBits(reflect.TypeOf(outer{}), "", nil); ok {
+		t.Fatalf("expected outer to not have a static size")
+	}
+
+	input := outer{V2: 2, V3: []uint32{1, 2}, V4: []inner{{}, {}}}
+	bs, err := Encode(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs = append(bs, 0xab)
+
+	buf, err := bits.NewFromBytes(bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//35+16+8+64+70 bits
+	if err := Skip(buf, reflect.TypeOf(outer{})); err != nil {
+		t.Fatalf("expected no skip error found: %v", err)
+	}
+	if remaining := remainingBits(buf); remaining != len(bs)*8-193 {
+		t.Fatalf("expected %v remaining bits but found %v", len(bs)*8-193, remaining)
+	}
+
+	//there are not enough bits left for another
+	if err := Skip(buf, reflect.TypeOf(outer{})); err == nil {
+		t.Fatalf("expected an error skipping past the end")
+	}
+}
+
+func TestBitPos(t *testing.T) {
+	for size := 0; size < 20; size++ {
+		buf, err := bits.NewFromBits(make([]bool, size))
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		for pos := 0; ; pos++ {
+			if actual := bitPos(buf); actual != pos {
+				t.Fatalf("%v bits: expected position %v but found %v", size, pos, actual)
+			}
+			if n, _ := buf.ReadBits([]bool{false}); n == 0 {
+				break
+			}
+		}
+	}
+}
+
+func TestReadCursor(t *testing.T) {
+	buf, err := bits.NewFromBits(make([]bool, 100))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	other, err := bits.NewFromBits(make([]bool, 10))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	//reads of growing sizes, a read of the other buffer and going back to the start are all followed
+	cursor := &readCursor{}
+	for _, n := range []int{0, 1, 2, 3, 5, 8, 13, 21, 40, 7} {
+		if n == 40 {
+			buf.ResetToStart()
+		}
+		if n == 21 {
+			other.ReadBits(make([]bool, 3))
+			if actual := cursor.bitPos(other); actual != 3 {
+				t.Fatalf("expected position 3 of the other buffer but found %v", actual)
+			}
+		}
+		buf.ReadBits(make([]bool, n))
+		if expected, actual := bitPos(buf), cursor.bitPos(buf); expected != actual {
+			t.Fatalf("after reading %v bits: expected position %v but found %v", n, expected, actual)
+		}
+	}
+	buf.ResetToEnd()
+	if actual := cursor.remaining(buf); actual != 0 {
+		t.Fatalf("expected no bits remaining but found %v", actual)
+	}
+}
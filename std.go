@@ -0,0 +1,421 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"time"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//The options below encode common types of the standard library. They are opt-in, pass them in with the other options
+// or all at once with StdEncDecs, e.g. Encode(&msg, binary.StdEncDecs()...). How a value is encoded is chosen by a tag
+// on the field holding it.
+var (
+	//TimeEncDec encodes time.Time as a number chosen by the `time` tag:
+	//   - unix32: seconds since 1970 as a uint32
+	//   - unix64: seconds since 1970 as an int64, the default
+	//   - unixms, unixus and unixns: milli, micro and nanoseconds since 1970 as an int64
+	//   - ntp: an NTP timestamp, a uint64 of seconds since 1900 in the upper 32 bits and the fraction of a second in the
+	//     lower 32 bits
+	//   - gps: seconds since the GPS epoch of 1980-01-06 as a uint32, without correcting for leap seconds
+	//
+	// The number is encoded like any other, so `endian` and `bits` tags apply to it. Times are decoded in UTC and
+	// anything finer than the number holds is dropped.
+	TimeEncDec = &TypeEncDec{ValueType: reflect.TypeOf(time.Time{}), Encoder: encodeTime, Decoder: decodeTime}
+
+	//DurationEncDec encodes time.Duration as an int64 count of the unit in the `duration` tag: ns, the default, us, ms
+	// or s. The count is encoded like any other number and anything finer than the unit is dropped.
+	DurationEncDec = &TypeEncDec{ValueType: reflect.TypeOf(time.Duration(0)), Encoder: encodeDuration, Decoder: decodeDuration}
+
+	//IPEncDec encodes net.IP as 4 bytes with `ip:"v4"` or 16 bytes with `ip:"v6"`, the default. Addresses are always
+	// in network byte order.
+	IPEncDec = &TypeEncDec{ValueType: reflect.TypeOf(net.IP{}), Encoder: encodeIP, Decoder: decodeIP}
+
+	//AddrEncDec encodes netip.Addr the same as IPEncDec. With `ip:"v6"` IPv4 addresses are encoded as IPv4-mapped
+	// IPv6 addresses, which is how they are decoded.
+	AddrEncDec = &TypeEncDec{ValueType: reflect.TypeOf(netip.Addr{}), Encoder: encodeAddr, Decoder: decodeAddr}
+
+	//HardwareAddrEncDec encodes net.HardwareAddr as 6 bytes with `mac:"eui48"`, the default, or 8 bytes with
+	// `mac:"eui64"`.
+	HardwareAddrEncDec = &TypeEncDec{ValueType: reflect.TypeOf(net.HardwareAddr{}), Encoder: encodeHardwareAddr, Decoder: decodeHardwareAddr}
+
+	//BigIntEncDec encodes big.Int, and *big.Int through the pointer, as an unsigned number of the bytes in the
+	// `bigint` tag, either a number or a field found prior to this field. The bytes follow the `endian` tag, negative
+	// numbers and numbers that do not fit are an error.
+	BigIntEncDec = &TypeEncDec{ValueType: reflect.TypeOf(big.Int{}), Encoder: encodeBigInt, Decoder: decodeBigInt}
+)
+
+//StdEncDecs returns the options for the types of the standard library.
+func StdEncDecs() []EncDecOption {
+	return []EncDecOption{TimeEncDec, DurationEncDec, IPEncDec, AddrEncDec, HardwareAddrEncDec, BigIntEncDec}
+}
+
+var (
+	ntpEpoch = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	gpsEpoch = time.Date(1980, time.January, 6, 0, 0, 0, 0, time.UTC)
+)
+
+//encodeNumber encodes n, a number, the same as a field of its type with the tag.
+func encodeNumber(fieldName string, n interface{}, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	v := reflect.ValueOf(n)
+	return EncodeField(fieldName, v.Type(), v, tag, buf, sizeMap, options...)
+}
+
+//decodeNumber decodes into the number n points to the same as a field of its type with the tag.
+func decodeNumber(fieldName string, n interface{}, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	v := reflect.ValueOf(n).Elem()
+	return DecodeField(fieldName, v.Type(), v, tag, buf, sizeMap, options...)
+}
+
+func encodeTime(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	t := v.Interface().(time.Time)
+	var n interface{}
+	switch format := tag.Get("time"); format {
+	case "unix32":
+		if t.Unix() < 0 || t.Unix() > math.MaxUint32 {
+			return fmt.Errorf("%v: %v does not fit in unix32", fieldName, t)
+		}
+		n = uint32(t.Unix())
+	case "", "unix64":
+		n = t.Unix()
+	case "unixms":
+		n = t.UnixMilli()
+	case "unixus":
+		n = t.UnixMicro()
+	case "unixns":
+		n = t.UnixNano()
+	case "ntp":
+		seconds := t.Unix() - ntpEpoch.Unix()
+		if seconds < 0 || seconds > math.MaxUint32 {
+			return fmt.Errorf("%v: %v does not fit in ntp", fieldName, t)
+		}
+		//rounded so the nanoseconds are decoded back the same
+		fraction := (uint64(t.Nanosecond())<<32 + uint64(time.Second)/2) / uint64(time.Second)
+		n = uint64(seconds)<<32 | fraction
+	case "gps":
+		seconds := t.Unix() - gpsEpoch.Unix()
+		if seconds < 0 || seconds > math.MaxUint32 {
+			return fmt.Errorf("%v: %v does not fit in gps", fieldName, t)
+		}
+		n = uint32(seconds)
+	default:
+		return fmt.Errorf("%v: unsupported time value: %v", fieldName, format)
+	}
+	return encodeNumber(fieldName, n, tag, buf, sizeMap, options...)
+}
+
+func decodeTime(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	var result time.Time
+	switch format := tag.Get("time"); format {
+	case "unix32", "gps":
+		var n uint32
+		if err := decodeNumber(fieldName, &n, tag, buf, sizeMap, options...); err != nil {
+			return err
+		}
+		if format == "gps" {
+			result = gpsEpoch.Add(time.Duration(n) * time.Second)
+		} else {
+			result = time.Unix(int64(n), 0)
+		}
+	case "", "unix64", "unixms", "unixus", "unixns":
+		var n int64
+		if err := decodeNumber(fieldName, &n, tag, buf, sizeMap, options...); err != nil {
+			return err
+		}
+		switch format {
+		case "unixms":
+			result = time.UnixMilli(n)
+		case "unixus":
+			result = time.UnixMicro(n)
+		case "unixns":
+			result = time.Unix(0, n)
+		default:
+			result = time.Unix(n, 0)
+		}
+	case "ntp":
+		var n uint64
+		if err := decodeNumber(fieldName, &n, tag, buf, sizeMap, options...); err != nil {
+			return err
+		}
+		nanos := ((n&math.MaxUint32)*uint64(time.Second) + 1<<31) >> 32
+		result = time.Unix(ntpEpoch.Unix()+int64(n>>32), int64(nanos))
+	default:
+		return fmt.Errorf("%v: unsupported time value: %v", fieldName, format)
+	}
+	v.Set(reflect.ValueOf(result.UTC()))
+	return nil
+}
+
+//durationUnit returns the unit of the `duration` tag.
+func durationUnit(fieldName string, tag reflect.StructTag) (time.Duration, error) {
+	switch unit := tag.Get("duration"); unit {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("%v: unsupported duration value: %v", fieldName, unit)
+	}
+}
+
+func encodeDuration(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	unit, err := durationUnit(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	return encodeNumber(fieldName, v.Int()/int64(unit), tag, buf, sizeMap, options...)
+}
+
+func decodeDuration(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	unit, err := durationUnit(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if err := decodeNumber(fieldName, &n, tag, buf, sizeMap, options...); err != nil {
+		return err
+	}
+	v.SetInt(n * int64(unit))
+	return nil
+}
+
+//ipLen returns the number of bytes of an address with the `ip` tag.
+func ipLen(fieldName string, tag reflect.StructTag) (int, error) {
+	switch version := tag.Get("ip"); version {
+	case "v4":
+		return net.IPv4len, nil
+	case "", "v6":
+		return net.IPv6len, nil
+	default:
+		return 0, fmt.Errorf("%v: unsupported ip value: %v", fieldName, version)
+	}
+}
+
+//writeBytes writes all of bs to buf.
+func writeBytes(buf bits.BitSetWriter, bs []byte) error {
+	n, err := buf.Write(bs)
+	if err != nil {
+		return err
+	}
+	if n != len(bs) {
+		return fmt.Errorf("wrote %v expected %v", n, len(bs))
+	}
+	return nil
+}
+
+//readBytes reads n bytes from buf.
+func readBytes(fieldName string, buf *bits.BitSetBuffer, n int, options []EncDecOption) ([]byte, error) {
+	if remaining := getReadCursor(options).remaining(buf); remaining < n*8 {
+		return nil, fmt.Errorf("%v: needed %v bytes but only %v bits are left", fieldName, n, remaining)
+	}
+	bs := make([]byte, n)
+	if _, err := buf.Read(bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+func encodeIP(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := ipLen(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	ip := v.Interface().(net.IP)
+	var bs []byte
+	switch {
+	case len(ip) == 0:
+		bs = make([]byte, n)
+	case n == net.IPv4len:
+		bs = ip.To4()
+	default:
+		bs = ip.To16()
+	}
+	if bs == nil {
+		return fmt.Errorf("%v: %v is not an IPv4 address", fieldName, ip)
+	}
+	return writeBytes(buf, bs)
+}
+
+func decodeIP(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := ipLen(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	bs, err := readBytes(fieldName, buf, n, options)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(net.IP(bs).To16()))
+	return nil
+}
+
+func encodeAddr(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := ipLen(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	addr := v.Interface().(netip.Addr)
+	switch {
+	case !addr.IsValid():
+		return writeBytes(buf, make([]byte, n))
+	case n == net.IPv4len:
+		if !addr.Unmap().Is4() {
+			return fmt.Errorf("%v: %v is not an IPv4 address", fieldName, addr)
+		}
+		bs := addr.Unmap().As4()
+		return writeBytes(buf, bs[:])
+	default:
+		bs := addr.As16()
+		return writeBytes(buf, bs[:])
+	}
+}
+
+func decodeAddr(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := ipLen(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	bs, err := readBytes(fieldName, buf, n, options)
+	if err != nil {
+		return err
+	}
+	addr, _ := netip.AddrFromSlice(bs)
+	v.Set(reflect.ValueOf(addr))
+	return nil
+}
+
+//macLen returns the number of bytes of an address with the `mac` tag.
+func macLen(fieldName string, tag reflect.StructTag) (int, error) {
+	switch format := tag.Get("mac"); format {
+	case "", "eui48":
+		return 6, nil
+	case "eui64":
+		return 8, nil
+	default:
+		return 0, fmt.Errorf("%v: unsupported mac value: %v", fieldName, format)
+	}
+}
+
+func encodeHardwareAddr(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := macLen(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	addr := v.Interface().(net.HardwareAddr)
+	switch len(addr) {
+	case 0:
+		return writeBytes(buf, make([]byte, n))
+	case n:
+		return writeBytes(buf, addr)
+	default:
+		return fmt.Errorf("%v: %v is not %v bytes", fieldName, addr, n)
+	}
+}
+
+func decodeHardwareAddr(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := macLen(fieldName, tag)
+	if err != nil {
+		return err
+	}
+	bs, err := readBytes(fieldName, buf, n, options)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(net.HardwareAddr(bs)))
+	return nil
+}
+
+//bigIntLen returns the number of bytes of the `bigint` tag.
+func bigIntLen(fieldName string, tag reflect.StructTag, sizeMap map[string]int) (int, error) {
+	s, ok := tag.Lookup("bigint")
+	if !ok {
+		return 0, fmt.Errorf("%v: big.Int needs a bigint tag with its number of bytes", fieldName)
+	}
+	n, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		i, has := sizeMap[s]
+		switch {
+		case !has:
+			return 0, fmt.Errorf("%v: bigint must either be a positive number or a field found prior to this field :%v", fieldName, err)
+		case i < 0:
+			return 0, fmt.Errorf("%v: value of %v is %v, to be used for bigint it must be nonnegative", fieldName, s, i)
+		}
+		n = uint64(i)
+	}
+	return int(n), nil
+}
+
+//reverse reverses bs in place.
+func reverse(bs []byte) {
+	for i, j := 0, len(bs)-1; i < j; i, j = i+1, j-1 {
+		bs[i], bs[j] = bs[j], bs[i]
+	}
+}
+
+func encodeBigInt(fieldName string, v reflect.Value, tag reflect.StructTag, buf bits.BitSetWriter, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := bigIntLen(fieldName, tag, sizeMap)
+	if err != nil {
+		return err
+	}
+	endian, err := getEndianness(tag, options)
+	if err != nil {
+		return fmt.Errorf("%v: %v", fieldName, err)
+	}
+
+	var x *big.Int
+	if v.CanAddr() {
+		x = v.Addr().Interface().(*big.Int)
+	} else {
+		value := v.Interface().(big.Int)
+		x = &value
+	}
+	switch {
+	case x.Sign() < 0:
+		return fmt.Errorf("%v: negative big.Int %v not supported", fieldName, x)
+	case (x.BitLen()+7)/8 > n:
+		return fmt.Errorf("%v: %v does not fit in %v bytes", fieldName, x, n)
+	}
+
+	bs := x.FillBytes(make([]byte, n))
+	if endian == binary.LittleEndian {
+		reverse(bs)
+	}
+	return writeBytes(buf, bs)
+}
+
+func decodeBigInt(fieldName string, t reflect.Type, v reflect.Value, tag reflect.StructTag, buf *bits.BitSetBuffer, sizeMap map[string]int, options ...EncDecOption) error {
+	n, err := bigIntLen(fieldName, tag, sizeMap)
+	if err != nil {
+		return err
+	}
+	endian, err := getEndianness(tag, options)
+	if err != nil {
+		return fmt.Errorf("%v: %v", fieldName, err)
+	}
+	if state := getDecodeState(options); state != nil {
+		if err := state.alloc(fieldName, n, 1); err != nil {
+			return err
+		}
+	}
+
+	bs, err := readBytes(fieldName, buf, n, options)
+	if err != nil {
+		return err
+	}
+	if endian == binary.LittleEndian {
+		reverse(bs)
+	}
+	v.Addr().Interface().(*big.Int).SetBytes(bs)
+	return nil
+}
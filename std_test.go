@@ -0,0 +1,164 @@
+package binary
+
+import (
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdEncDecs(t *testing.T) {
+	type packet struct {
+		Sent     time.Time `time:"unix32" endian:"big"`
+		Received time.Time `time:"ntp" endian:"big"`
+		Fix      time.Time `time:"gps" endian:"big"`
+		Logged   time.Time `time:"unixms"`
+		Timeout  time.Duration `duration:"ms" bits:"24"`
+		Source   net.IP        `ip:"v4"`
+		Dest     net.IP
+		Gateway  netip.Addr `ip:"v4"`
+		Peer     netip.Addr `ip:"v6"`
+		MAC      net.HardwareAddr
+		EUI      net.HardwareAddr `mac:"eui64"`
+		KeyLen   uint8
+		Key      *big.Int `bigint:"KeyLen" endian:"big"`
+		Nonce    big.Int  `bigint:"3"`
+	}
+
+	input := packet{
+		Sent:     time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Received: time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC),
+		Fix:      time.Date(1980, 1, 6, 0, 1, 0, 0, time.UTC),
+		Logged:   time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC),
+		Timeout:  1500 * time.Millisecond,
+		Source:   net.IPv4(192, 168, 1, 2),
+		Dest:     net.ParseIP("2001:db8::1"),
+		Gateway:  netip.MustParseAddr("10.0.0.1"),
+		Peer:     netip.MustParseAddr("::ffff:10.0.0.2"),
+		MAC:      net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		EUI:      net.HardwareAddr{1, 2, 3, 4, 5, 6, 7, 8},
+		KeyLen:   2,
+		Key:      big.NewInt(0x1234),
+	}
+	input.Nonce.SetInt64(0x010203)
+
+	bs, err := Encode(&input, StdEncDecs()...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+
+	expected := []byte{
+		0x66, 0x38, 0x81, 0xd9, //unix32
+		0xe9, 0xe3, 0x00, 0x59, 0x80, 0, 0, 0, //ntp
+		0, 0, 0, 60, //gps
+		0x23, 0x38, 0xbb, 0x4c, 0x8f, 0x01, 0, 0, //unixms
+		0xdc, 0x05, 0, //1500ms in 24 bits
+		192, 168, 1, 2,
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		10, 0, 0, 1,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 0, 0, 2,
+		0, 0x11, 0x22, 0x33, 0x44, 0x55,
+		1, 2, 3, 4, 5, 6, 7, 8,
+		2, 0x12, 0x34,
+		0x03, 0x02, 0x01,
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual packet
+	if err := Decode(bs, &actual, StdEncDecs()...); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", input, actual)
+	}
+
+	//dumps list them as one value
+	listing, err := DumpBytes(bs, &packet{}, StdEncDecs()...)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !strings.Contains(listing, "0:32     Sent      66 38 81 d9") || !strings.Contains(listing, "2024-05-06 07:08:09 +0000 UTC") ||
+		!strings.Contains(listing, "12 34                                            4660") || strings.Contains(listing, "Sent.Sent") {
+		t.Fatalf("expected the time as one value but found:\n%v", listing)
+	}
+}
+
+func TestStdEncDecsNTP(t *testing.T) {
+	type stamp struct {
+		T time.Time `time:"ntp"`
+	}
+	base := time.Date(2024, time.May, 6, 3, 4, 5, 0, time.UTC)
+	for _, offset := range []time.Duration{
+		time.Millisecond, 999 * time.Millisecond, time.Microsecond, 123456 * time.Microsecond,
+		time.Nanosecond, 999999999 * time.Nanosecond, 500000001 * time.Nanosecond,
+	} {
+		input := stamp{T: base.Add(offset)}
+		bs, err := Encode(&input, TimeEncDec)
+		if err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		var actual stamp
+		if err := Decode(bs, &actual, TimeEncDec); err != nil {
+			t.Fatalf("expected no error found: %v", err)
+		}
+		if !input.T.Equal(actual.T) {
+			t.Fatalf("expected %v but found %v", input.T, actual.T)
+		}
+	}
+}
+
+func TestStdEncDecsErrors(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			T time.Time `time:"unix16"`
+		}{},
+		&struct {
+			T time.Time `time:"unix32"`
+		}{T: time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC)},
+		&struct {
+			T time.Time `time:"gps"`
+		}{},
+		&struct {
+			D time.Duration `duration:"h"`
+		}{},
+		&struct {
+			IP net.IP `ip:"v4"`
+		}{IP: net.ParseIP("::1")},
+		&struct {
+			IP netip.Addr `ip:"v5"`
+		}{},
+		&struct {
+			IP netip.Addr `ip:"v4"`
+		}{IP: netip.MustParseAddr("::1")},
+		&struct {
+			MAC net.HardwareAddr
+		}{MAC: net.HardwareAddr{1, 2}},
+		&struct {
+			N *big.Int
+		}{N: big.NewInt(1)},
+		&struct {
+			N *big.Int `bigint:"1"`
+		}{N: big.NewInt(256)},
+		&struct {
+			N *big.Int `bigint:"4"`
+		}{N: big.NewInt(-1)},
+	}
+	for i, test := range tests {
+		if _, err := Encode(test, StdEncDecs()...); err == nil {
+			t.Errorf("%v: expected an error", i)
+		}
+	}
+
+	//too little data
+	var short struct {
+		IP net.IP
+	}
+	if err := Decode([]byte{1, 2, 3}, &short, StdEncDecs()...); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,111 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/nathanhack/binary/internal/tagrules"
+)
+
+//Problem is a problem found in the tags of a field.
+type Problem struct {
+	//Path is the path of the field from the struct checked, e.g. `Header.Length`.
+	Path string
+	//Message describes the problem.
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%v: %v", p.Path, p.Message)
+}
+
+//CheckTags returns the problems found in the tags of the struct t, a struct or a pointer to one, and of the structs
+// within it. It reports:
+//   - `binary` tags with unknown or malformed settings, or settings that disagree with the separate tag of the same
+//     name
+//   - tags that look like a misspelled tag, e.g. `endain:"big"`, which would otherwise be ignored
+//   - size, strlen and bits naming a field that is not an integer declared before the field
+//   - bits on a type that can not have them, more bits than the type has or less than 2 bits on a signed type
+//   - omit along with other settings, a min greater than the max and unsupported endian values
+//   - unexported fields, which are encoded as their zero value and skipped when decoding
+//   - fields of unsupported kinds, e.g. int or map, unless the type has MarshalBits and UnmarshalBits
+//
+// Most of these make Encode and Decode fail, CheckTags finds them all at once, e.g. in a test. The lint package
+// runs the same checks on source code.
+func CheckTags(t reflect.Type) []Problem {
+	if t == nil {
+		return []Problem{{Message: "nil type"}}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return []Problem{{Message: fmt.Sprintf("%v is not a struct", t)}}
+	}
+
+	c := tagChecker{visited: map[reflect.Type]bool{}, earlier: map[string]reflect.Kind{}}
+	c.checkStruct("", t)
+	return c.problems
+}
+
+type tagChecker struct {
+	problems []Problem
+	visited  map[reflect.Type]bool
+	//earlier are the kinds of the fields checked so far by name, the fields encoded before the ones being checked
+	earlier map[string]reflect.Kind
+}
+
+func (c *tagChecker) checkStruct(path string, t reflect.Type) {
+	if c.visited[t] {
+		return
+	}
+	c.visited[t] = true
+
+	fields := make([]tagrules.Field, t.NumField())
+	for i := range fields {
+		sf := t.Field(i)
+		kind, item := sf.Type, sf.Type
+		for kind.Kind() == reflect.Ptr {
+			kind = kind.Elem()
+		}
+		for item.Kind() == reflect.Ptr || item.Kind() == reflect.Array || item.Kind() == reflect.Slice {
+			item = item.Elem()
+		}
+		fields[i] = tagrules.Field{Name: sf.Name, Tag: sf.Tag, Kind: kind.Kind(), Item: item.Kind(), Custom: hasCustomEncoding(item),
+			Empty: sf.Type.Kind() == reflect.Struct && sf.Type.NumField() == 0}
+	}
+	problems := tagrules.Check(fields, c.earlier)
+
+	//the problems of each field are followed by the ones of the structs within it
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		for len(problems) > 0 && problems[0].Index == i {
+			c.problems = append(c.problems, Problem{Path: joinPath(path, sf.Name), Message: problems[0].Message})
+			problems = problems[1:]
+		}
+
+		tag, _ := tagrules.Expand(sf.Tag)
+		if _, has := tag.Lookup("omit"); has || sf.Name == "_" {
+			continue
+		}
+		item := sf.Type
+		for item.Kind() == reflect.Ptr || item.Kind() == reflect.Array || item.Kind() == reflect.Slice {
+			item = item.Elem()
+		}
+		if item.Kind() == reflect.Struct && !hasCustomEncoding(item) {
+			c.checkStruct(joinPath(path, sf.Name), item)
+		}
+		c.earlier[sf.Name] = fields[i].Kind
+	}
+}
+
+//hasCustomEncoding reports if values of type t encode themselves with MarshalBits and UnmarshalBits.
+func hasCustomEncoding(t reflect.Type) bool {
+	marshaler := reflect.TypeOf((*BitsMarshaler)(nil)).Elem()
+	unmarshaler := reflect.TypeOf((*BitsUnmarshaler)(nil)).Elem()
+	return (t.Implements(marshaler) || reflect.PtrTo(t).Implements(marshaler)) &&
+		(t.Implements(unmarshaler) || reflect.PtrTo(t).Implements(unmarshaler))
+}
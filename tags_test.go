@@ -0,0 +1,221 @@
+package binary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type unifiedHeader struct {
+	Count   uint8  `binary:"bits=4"`
+	Kind    uint8  `binary:"bits=4,oneof=1 2 3"`
+	Offset  uint16 `binary:"bits=13,endian=big"`
+	Flag    bool   `binary:"bits=1" bits:"1"`
+	Spare   uint8  `binary:"omit"`
+	Name    string `binary:"strlen=3"`
+	TTL     uint8  `binary:"min=1,max=64"`
+	Payload []byte `binary:"size=Count"`
+}
+
+type legacyHeader struct {
+	Count   uint8  `bits:"4"`
+	Kind    uint8  `bits:"4" oneof:"1 2 3"`
+	Offset  uint16 `bits:"13" endian:"big"`
+	Flag    bool   `bits:"1"`
+	Spare   uint8  `omit:""`
+	Name    string `strlen:"3"`
+	TTL     uint8  `min:"1" max:"64"`
+	Payload []byte `size:"Count"`
+}
+
+func TestBinaryTag(t *testing.T) {
+	input := unifiedHeader{Count: 2, Kind: 3, Offset: 0x1234, Flag: true, Name: "abc", TTL: 9, Payload: []byte{7, 8}}
+	legacy := legacyHeader{Count: 2, Kind: 3, Offset: 0x1234, Flag: true, Name: "abc", TTL: 9, Payload: []byte{7, 8}}
+
+	expected, err := Encode(&legacy)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	bs, err := Encode(&input)
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual unifiedHeader
+	if err := Decode(bs, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(input, actual) {
+		t.Fatalf("expected \n%#v\n but found \n%#v\n", input, actual)
+	}
+
+	schema, err := Describe(reflect.TypeOf(input))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	legacySchema, err := Describe(reflect.TypeOf(legacy))
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if len(schema.Fields) != len(legacySchema.Fields) || schema.Fields[2].Endian != "big" || *schema.Fields[2].Bits != 13 {
+		t.Fatalf("expected the schema of the legacy tags but found %#v", schema.Fields)
+	}
+
+	//the checks of the tag are validated
+	input.TTL = 65
+	if _, err := Encode(&input); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := Validate(&input); err == nil || !strings.Contains(err.Error(), "TTL") {
+		t.Fatalf("expected a validation error on TTL but found %v", err)
+	}
+}
+
+func TestBinaryTagPadding(t *testing.T) {
+	type unified struct {
+		A uint8 `binary:"bits=3"`
+		_ uint8 `binary:"bits=5"`
+		B uint16
+	}
+	type legacy struct {
+		A uint8 `bits:"3"`
+		_ uint8 `bits:"5"`
+		B uint16
+	}
+
+	expected, err := Encode(&legacy{A: 5, B: 0x1234})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	bs, err := Encode(&unified{A: 5, B: 0x1234})
+	if err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if !reflect.DeepEqual(expected, bs) {
+		t.Fatalf("expected \n%x\n but found \n%x\n", expected, bs)
+	}
+
+	var actual unified
+	if err := Decode(bs, &actual); err != nil {
+		t.Fatalf("expected no error found: %v", err)
+	}
+	if actual.A != 5 || actual.B != 0x1234 {
+		t.Fatalf("expected A 5 and B 0x1234 but found %#v", actual)
+	}
+	if problems := CheckTags(reflect.TypeOf(unified{})); len(problems) != 0 {
+		t.Fatalf("expected no problems but found %v", problems)
+	}
+}
+
+func TestBinaryTagErrors(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			A uint8 `binary:"bytes=2"`
+		}{},
+		&struct {
+			A uint8 `binary:"bits"`
+		}{},
+		&struct {
+			A uint8 `binary:"bits=4,bits=5"`
+		}{},
+		&struct {
+			A uint16 `binary:"endian=middle"`
+		}{},
+		&struct {
+			A uint8 `binary:"bits=4" bits:"5"`
+		}{},
+		&struct {
+			A uint8 `binary:"omit=true"`
+		}{},
+	}
+	for i, test := range tests {
+		if _, err := Encode(test); err == nil {
+			t.Errorf("%v: expected an error", i)
+		}
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	if problems := CheckTags(reflect.TypeOf(&unifiedHeader{})); len(problems) != 0 {
+		t.Fatalf("expected no problems but found %v", problems)
+	}
+
+	type inner struct {
+		_ struct{} `binary:"endian=big,order=msb"`
+		B uint8    `binary:"bits=3,bytes=1"`
+	}
+	type outer struct {
+		A     uint8 `binary:"bits=4" bits:"5"`
+		Inner []inner
+		Range uint8  `binary:"min=10,max=2"`
+		Gone  uint8  `binary:"omit,bits=3"`
+		Self  *outer `binary:"endian=middle"`
+	}
+	expected := []string{
+		"A: binary tag sets bits=4 but the bits tag is \"5\"",
+		"Inner._: unknown struct binary tag setting order",
+		"Inner.B: unknown binary tag setting bytes",
+		"Range: min 10 is greater than max 2",
+		"Gone: omit along with bits, the field is not encoded",
+		"Self: unsupported endian value: middle",
+	}
+	var actual []string
+	for _, problem := range CheckTags(reflect.TypeOf(outer{})) {
+		actual = append(actual, problem.String())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%q\n but found \n%q\n", expected, actual)
+	}
+
+	type fields struct {
+		Length   uint16 `endain:"big"`
+		Data     []byte `size:"Count"`
+		Count    uint8
+		Name     string  `strlen:"Data"`
+		Ratio    float32 `bits:"16"`
+		Wide     uint8   `bits:"9"`
+		Sign     int16   `bits:"1"`
+		Items    []int8  `bits:"Count"`
+		hidden   uint8
+		_        uint8 `bits:"3"`
+		Counts   map[string]int
+		Size     int
+		Flags    []uint
+		Stamp    *uint16 `binary:"bits=12"`
+		Self     uint8   `bits:"Self"`
+		Reserved uint8   `Bits:"2"`
+		Missing  []byte  `size:"Nope"`
+		Nested   []struct {
+			Data []byte `size:"Count"`
+		} `size:"2"`
+	}
+	expected = []string{
+		"Length: unknown tag endain, did you mean endian?",
+		"Data: size refers to Count, which is declared after it",
+		"Name: strlen refers to Data, which is not an integer",
+		"Ratio: bits not supported on float32",
+		"Wide: bits 9 is more than the 8 bits of uint8",
+		"Sign: bits 1 on the signed int16, it needs at least 2 bits",
+		"hidden: unexported field is encoded as its zero value and skipped when decoding",
+		"Counts: map not supported",
+		"Size: int not supported",
+		"Flags: uint not supported",
+		"Self: bits refers to the field itself",
+		"Reserved: unknown tag Bits, did you mean bits?",
+		"Missing: size must either be a number or the name of a field declared before it, there is no field Nope",
+	}
+	actual = nil
+	for _, problem := range CheckTags(reflect.TypeOf(fields{})) {
+		actual = append(actual, problem.String())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected \n%q\n but found \n%q\n", expected, actual)
+	}
+
+	if problems := CheckTags(reflect.TypeOf(0)); len(problems) != 1 {
+		t.Fatalf("expected a problem but found %v", problems)
+	}
+}
@@ -0,0 +1,255 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Validator is implemented by types that check their own values. ValidateBits is called after DecodeToBits fills a
+// value and before EncodeToBits writes it. Fields are validated before the struct holding them.
+type Validator interface {
+	ValidateBits() error
+}
+
+//ValidationError is returned when a value fails validation. Path is the path of the field that failed, e.g.
+// `IpHeader.Options[2].Kind`.
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	validatorType       = reflect.TypeOf((*Validator)(nil)).Elem()
+	validationTypeCache sync.Map
+)
+
+//Validate checks value the same way Encode and Decode do: the `min`, `max` and `oneof` tags of every field and the
+// ValidateBits method of every Validator.
+func Validate(value interface{}) (err error) {
+	defer recoverError(&err)
+
+	if value == nil {
+		return fmt.Errorf("nil parameters not allowed")
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return validateRoot(v)
+}
+
+func validateRoot(v reflect.Value) error {
+	if !typeNeedsValidation(v.Type()) {
+		return nil
+	}
+
+	//pointer receivers can only be found on addressable values
+	if !v.CanAddr() {
+		tmp := reflect.New(v.Type()).Elem()
+		tmp.Set(v)
+		v = tmp
+	}
+	return validateValue(v.Type().Name(), v, "")
+}
+
+func validateValue(path string, v reflect.Value, tag reflect.StructTag) error {
+	t := v.Type()
+	if !tagNeedsValidation(tag) && !typeNeedsValidation(t) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateValue(path, v.Elem(), tag)
+	case reflect.Struct:
+		info := getStructInfo(t)
+		if info.err != nil {
+			return &ValidationError{Path: path, Err: info.err}
+		}
+		for _, sf := range info.fields {
+			if err := validateValue(joinPath(path, sf.Name), v.Field(sf.Index[0]), sf.Tag); err != nil {
+				return err
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(fmt.Sprintf("%v[%v]", path, i), v.Index(i), tag); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := validateTags(v, tag); err != nil {
+			return &ValidationError{Path: path, Err: err}
+		}
+	}
+
+	return callValidator(path, v)
+}
+
+func callValidator(path string, v reflect.Value) error {
+	if !v.CanInterface() {
+		return nil
+	}
+
+	var validator Validator
+	if v.Type().Implements(validatorType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		validator = v.Interface().(Validator)
+	} else if reflect.PtrTo(v.Type()).Implements(validatorType) && v.CanAddr() {
+		validator = v.Addr().Interface().(Validator)
+	} else {
+		return nil
+	}
+
+	err := validator.ValidateBits()
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ValidationError); ok {
+		return err
+	}
+	return &ValidationError{Path: path, Err: err}
+}
+
+func validateTags(v reflect.Value, tag reflect.StructTag) error {
+	if s, ok := tag.Lookup("min"); ok {
+		less, err := compareTag(v, s)
+		if err != nil {
+			return fmt.Errorf("min: %v", err)
+		}
+		if less < 0 {
+			return fmt.Errorf("value %v is less than min %v", v, s)
+		}
+	}
+
+	if s, ok := tag.Lookup("max"); ok {
+		more, err := compareTag(v, s)
+		if err != nil {
+			return fmt.Errorf("max: %v", err)
+		}
+		if more > 0 {
+			return fmt.Errorf("value %v is greater than max %v", v, s)
+		}
+	}
+
+	if s, ok := tag.Lookup("oneof"); ok {
+		for _, option := range strings.Fields(s) {
+			c, err := compareTag(v, option)
+			if err != nil {
+				return fmt.Errorf("oneof: %v", err)
+			}
+			if c == 0 {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %v is not one of %v", v, s)
+	}
+	return nil
+}
+
+//compareTag compares v to the tag value s returning -1, 0 or 1 if v is less than, equal to or greater than s.
+func compareTag(v reflect.Value, s string) (int, error) {
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		limit, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return 0, err
+		}
+		return compare(v.Uint() < limit, v.Uint() > limit), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		limit, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return 0, err
+		}
+		return compare(v.Int() < limit, v.Int() > limit), nil
+	case reflect.Float32, reflect.Float64:
+		limit, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+		return compare(v.Float() < limit, v.Float() > limit), nil
+	case reflect.String:
+		return strings.Compare(v.String(), s), nil
+	}
+	return 0, fmt.Errorf("%v not supported", v.Type())
+}
+
+func compare(less, more bool) int {
+	switch {
+	case less:
+		return -1
+	case more:
+		return 1
+	}
+	return 0
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func tagNeedsValidation(tag reflect.StructTag) bool {
+	for _, key := range []string{"min", "max", "oneof"} {
+		if _, has := tag.Lookup(key); has {
+			return true
+		}
+	}
+	return false
+}
+
+//typeNeedsValidation reports if values of t can ever fail validation, so large payloads of plain values can be
+// passed over without walking them.
+func typeNeedsValidation(t reflect.Type) bool {
+	if needs, ok := validationTypeCache.Load(t); ok {
+		return needs.(bool)
+	}
+	needs := typeNeedsValidationVisit(t, map[reflect.Type]bool{})
+	validationTypeCache.Store(t, needs)
+	return needs
+}
+
+func typeNeedsValidationVisit(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t.Implements(validatorType) || reflect.PtrTo(t).Implements(validatorType) {
+		return true
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Array, reflect.Slice:
+		return typeNeedsValidationVisit(t.Elem(), visiting)
+	case reflect.Struct:
+		for _, sf := range getStructInfo(t).fields {
+			if tagNeedsValidation(sf.Tag) || typeNeedsValidationVisit(sf.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
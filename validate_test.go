@@ -0,0 +1,96 @@
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type validatedHeader struct {
+	Version uint8   `oneof:"4 6"`
+	TTL     uint8   `min:"1" max:"64"`
+	Offsets []int16 `size:"2" min:"-10" max:"10"`
+	Kind    string  `strlen:"3" oneof:"abc xyz"`
+}
+
+type validatedMessage struct {
+	Header validatedHeader
+	Length uint16
+	Data   []byte `size:"Length"`
+}
+
+func (m *validatedMessage) ValidateBits() error {
+	if int(m.Length) != len(m.Data) {
+		return fmt.Errorf("length %v does not match data length %v", m.Length, len(m.Data))
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	valid := validatedHeader{Version: 4, TTL: 64, Offsets: []int16{-10, 10}, Kind: "xyz"}
+
+	tests := []struct {
+		input validatedHeader
+		path  string
+	}{
+		{valid, ""},
+		{validatedHeader{Version: 5, TTL: 64, Offsets: []int16{0, 0}, Kind: "abc"}, "validatedHeader.Version"},
+		{validatedHeader{Version: 6, TTL: 0, Offsets: []int16{0, 0}, Kind: "abc"}, "validatedHeader.TTL"},
+		{validatedHeader{Version: 6, TTL: 65, Offsets: []int16{0, 0}, Kind: "abc"}, "validatedHeader.TTL"},
+		{validatedHeader{Version: 6, TTL: 1, Offsets: []int16{0, -11}, Kind: "abc"}, "validatedHeader.Offsets[1]"},
+		{validatedHeader{Version: 6, TTL: 1, Offsets: []int16{0, 0}, Kind: "abd"}, "validatedHeader.Kind"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			err := Validate(test.input)
+			if test.path == "" {
+				if err != nil {
+					t.Fatalf("expected no error but found: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a ValidationError but found: %v", err)
+			}
+			if verr.Path != test.path {
+				t.Fatalf("expected path %v but found %v", test.path, verr.Path)
+			}
+		})
+	}
+}
+
+func TestValidateOnEncodeDecode(t *testing.T) {
+	input := validatedMessage{
+		Header: validatedHeader{Version: 6, TTL: 1, Kind: "abc"},
+		Length: 2,
+		Data:   []byte{1},
+	}
+
+	//the Validator is called before encoding
+	_, err := Encode(input)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Path != "validatedMessage" {
+		t.Fatalf("expected a ValidationError for validatedMessage but found: %v", err)
+	}
+
+	input.Data = []byte{1, 2}
+	bs, err := Encode(input)
+	if err != nil {
+		t.Fatalf("expected no encoding error found: %v", err)
+	}
+
+	var actual validatedMessage
+	if err = Decode(bs, &actual); err != nil {
+		t.Fatalf("expected no decoding error found: %v", err)
+	}
+
+	//the tags are checked after decoding
+	bs[1] = 100
+	err = Decode(bs, &actual)
+	if !errors.As(err, &verr) || verr.Path != "validatedMessage.Header.TTL" {
+		t.Fatalf("expected a ValidationError for validatedMessage.Header.TTL but found: %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+//Package wireshark generates Wireshark dissectors for tagged structs. The dissectors are Lua plugins showing the fields
+// of a struct the same as Decode reads them, so the Go definitions stay the one description of a protocol.
+//
+// A generated dissector is loaded by copying it to the personal Lua plugins folder, listed in Wireshark under
+// Help > About Wireshark > Folders.
+package wireshark
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nathanhack/binary"
+	"github.com/nathanhack/binary/internal/names"
+)
+
+//Config names the protocol of a dissector and the ports it is registered on.
+type Config struct {
+	//Name is the protocol name used in display filters, the snake case name of the struct when empty.
+	Name string
+	//Description is the protocol name shown in the packet details, the name of the struct when empty.
+	Description string
+	//UDPPorts and TCPPorts are the ports the dissector is registered on. Either way it can be picked with Decode As.
+	UDPPorts []int
+	TCPPorts []int
+}
+
+//Dissector returns the Lua dissector of t, a tagged struct or a pointer to one. The options are the ones given to
+// Encode and Decode, values they handle can not be dissected.
+//
+// Each packet holds one struct. When the struct has a static size and is carried over TCP the dissector asks Wireshark
+// for more data until the whole struct is there.
+func Dissector(t reflect.Type, config Config, options ...binary.EncDecOption) ([]byte, error) {
+	schema, err := binary.Describe(t, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Name == "" {
+		config.Name = names.Snake(schema.Name)
+	}
+	if err := validName(config.Name); err != nil {
+		return nil, err
+	}
+	if config.Description == "" {
+		config.Description = schema.Name
+	}
+	for _, port := range append(append([]int{}, config.UDPPorts...), config.TCPPorts...) {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("port %v is not between 1 and 65535", port)
+		}
+	}
+
+	g := generator{proto: config.Name, types: map[string]string{}, used: map[string]bool{}}
+	root, err := g.structFunc(schema.Name, "", schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "-- Code generated by wireshark.Dissector from %v. DO NOT EDIT.\n\n", schema.Name)
+	fmt.Fprintf(&out, "local proto = Proto(%v, %v)\n\n", quote(config.Name), quote(config.Description))
+	out.WriteString("local fields = {}\n")
+	for _, f := range g.fields {
+		out.WriteString(f)
+	}
+	out.WriteString("proto.fields = fields\n")
+	out.WriteString(runtime)
+	for _, f := range g.funcs {
+		out.WriteString(f)
+	}
+
+	out.WriteString("\nfunction proto.dissector(tvb, pinfo, tree)\n")
+	if schema.Bits != nil {
+		fmt.Fprintf(&out, "\tlocal length = %v\n", (*schema.Bits+7)/8)
+		out.WriteString("\tif pinfo.can_desegment > 0 and tvb:len() < length then\n")
+		out.WriteString("\t\tpinfo.desegment_len = length - tvb:len()\n")
+		out.WriteString("\t\treturn\n")
+		out.WriteString("\tend\n")
+	}
+	out.WriteString("\tpinfo.cols.protocol = proto.name\n")
+	out.WriteString("\tlocal subtree = tree:add(proto, tvb())\n")
+	fmt.Fprintf(&out, "\tlocal ok, err = pcall(%v, tvb, subtree, 0, {})\n", root)
+	out.WriteString("\tif not ok then\n")
+	out.WriteString("\t\tsubtree:add_expert_info(PI_MALFORMED, PI_ERROR, tostring(err))\n")
+	out.WriteString("\tend\n")
+	out.WriteString("end\n\n")
+
+	for _, table := range []struct {
+		name  string
+		ports []int
+	}{{"udp.port", config.UDPPorts}, {"tcp.port", config.TCPPorts}} {
+		fmt.Fprintf(&out, "DissectorTable.get(%v):add_for_decode_as(proto)\n", quote(table.name))
+		for _, port := range table.ports {
+			fmt.Fprintf(&out, "DissectorTable.get(%v):add(%v, proto)\n", quote(table.name), port)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+type generator struct {
+	proto string
+	//fields are the ProtoField declarations
+	fields []string
+	//types are the Lua names of the functions of the structs by Go type, used are all the names given out
+	types map[string]string
+	used  map[string]bool
+	//funcs are the functions dissecting the structs, each after the ones it calls
+	funcs []string
+}
+
+//structFunc returns the name of the Lua function dissecting the struct typeName, writing it when it is the first
+// time the struct is seen. name is the name of the field holding an anonymous struct.
+func (g *generator) structFunc(typeName, name string, fields []binary.FieldSchema) (string, error) {
+	if fn, has := g.types[typeName]; has && !strings.HasPrefix(typeName, "struct") {
+		return fn, nil
+	}
+
+	id := names.Snake(typeName[strings.LastIndex(typeName, ".")+1:])
+	if strings.HasPrefix(typeName, "struct") {
+		id = names.Snake(name)
+	}
+	if id == "" {
+		id = "packet"
+	}
+	unique := id
+	for i := 2; g.used[unique]; i++ {
+		unique = id + strconv.Itoa(i)
+	}
+	//the fields of the top level struct are named after the protocol alone
+	prefix := unique
+	if len(g.used) == 0 {
+		prefix = ""
+	}
+	g.used[unique] = true
+	fn := "dissect_" + unique
+	g.types[typeName] = fn
+
+	var b luaWriter
+	b.line(0, "")
+	b.line(0, "local function %v(tvb, tree, pos, parent)", fn)
+	b.line(1, "local values = setmetatable({}, {__index = parent})")
+	b.line(1, "local bits, item, n, r, value")
+	for _, f := range fields {
+		key := names.Snake(f.Name)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		b.line(1, "-- %v", f.Name)
+		if err := g.field(&b, 1, f, key, quote(f.Name), fmt.Sprintf("values[%v]", quote(f.Name))); err != nil {
+			return "", fmt.Errorf("%v: %v", f.Name, err)
+		}
+	}
+	b.line(1, "return pos")
+	b.line(0, "end")
+	g.funcs = append(g.funcs, b.String())
+	return fn, nil
+}
+
+//field writes the statements dissecting f at pos into tree. key is the key of the ProtoFields of f, label is the Lua
+// expression of its name and store is where its value is kept for the fields after it, empty for items.
+func (g *generator) field(b *luaWriter, depth int, f binary.FieldSchema, key, label, store string) error {
+	switch f.Kind {
+	case "bool", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		return g.number(b, depth, f, key, store)
+	case "float32", "float64":
+		n := *f.Bits / 8
+		g.protoField(key, fmt.Sprintf("ProtoField.%v(%v, %v)", map[int]string{4: "float", 8: "double"}[n],
+			quote(g.abbr(key)), quote(f.Name)))
+		method := "le_float"
+		if f.Endian == "big" {
+			method = "float"
+		}
+		b.line(depth, "r = read_bytes(tvb, pos, %v)", n)
+		b.line(depth, "tree:add(fields[%v], span(tvb, pos, pos + %v), r:%v())", quote(key), n*8, method)
+		b.line(depth, "pos = pos + %v", n*8)
+		return nil
+	case "string":
+		g.protoField(key, fmt.Sprintf("ProtoField.string(%v, %v)", quote(g.abbr(key)), quote(f.Name)))
+		b.line(depth, "n = %v", countExpr(f.StrLen, "math.floor((tvb:len() * 8 - pos) / 8)"))
+		b.line(depth, "r = read_bytes(tvb, pos, n)")
+		b.line(depth, "tree:add(fields[%v], span(tvb, pos, pos + 8 * n), r:string())", quote(key))
+		b.line(depth, "pos = pos + 8 * n")
+		return nil
+	case "array", "slice":
+		count := f.Size
+		if f.Kind == "array" {
+			count = strconv.Itoa(f.Length)
+		}
+
+		//bytes are shown as one field
+		if f.Elem.Kind == "uint8" && f.Elem.Bits != nil && *f.Elem.Bits == 8 {
+			g.protoField(key, fmt.Sprintf("ProtoField.bytes(%v, %v)", quote(g.abbr(key)), quote(f.Name)))
+			b.line(depth, "n = %v", countExpr(count, "math.floor((tvb:len() * 8 - pos) / 8)"))
+			b.line(depth, "r = read_bytes(tvb, pos, n)")
+			b.line(depth, "tree:add(fields[%v], span(tvb, pos, pos + 8 * n), r:bytes())", quote(key))
+			b.line(depth, "pos = pos + 8 * n")
+			return nil
+		}
+
+		b.line(depth, "do")
+		b.line(depth+1, "local start = pos")
+		b.line(depth+1, "local tree = tree:add(span(tvb, pos, pos), %v)", label)
+		b.line(depth+1, "local count = %v", countExpr(count, "nil"))
+		b.line(depth+1, "local i = 0")
+		b.line(depth+1, "while (count and i < count) or (not count and pos < tvb:len() * 8) do")
+		//items are named after the field holding them
+		item := *f.Elem
+		item.Name = f.Name
+		if err := g.field(b, depth+2, item, key, label+` .. "[" .. i .. "]"`, ""); err != nil {
+			return err
+		}
+		b.line(depth+2, "i = i + 1")
+		b.line(depth+1, "end")
+		b.line(depth+1, "tree:set_len(math.ceil(pos / 8) - math.floor(start / 8))")
+		b.line(depth, "end")
+		return nil
+	case "struct":
+		fn, err := g.structFunc(f.Type, f.Name, f.Fields)
+		if err != nil {
+			return err
+		}
+		b.line(depth, "do")
+		b.line(depth+1, "local start = pos")
+		b.line(depth+1, "local tree = tree:add(span(tvb, pos, pos), %v)", label)
+		b.line(depth+1, "pos = %v(tvb, tree, pos, values)", fn)
+		b.line(depth+1, "tree:set_len(math.ceil(pos / 8) - math.floor(start / 8))")
+		b.line(depth, "end")
+		return nil
+	}
+	return fmt.Errorf("%v of kind %v can not be dissected", f.Type, f.Kind)
+}
+
+//number writes the statements dissecting the integer or bool f.
+func (g *generator) number(b *luaWriter, depth int, f binary.FieldSchema, key, store string) error {
+	natural := 8
+	if f.Kind != "bool" {
+		natural, _ = strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(f.Kind, "u"), "int"))
+	}
+	signed := strings.HasPrefix(f.Kind, "int")
+	base := "base.DEC"
+	if len(f.Flags) > 0 {
+		base = "base.HEX"
+	}
+
+	switch {
+	case f.Kind == "bool":
+		g.protoField(key, fmt.Sprintf("ProtoField.bool(%v, %v)", quote(g.abbr(key)), quote(f.Name)))
+	default:
+		g.protoField(key, fmt.Sprintf("ProtoField.%v(%v, %v, %v)", f.Kind, quote(g.abbr(key)), quote(f.Name), base))
+	}
+
+	if f.BitsFrom != "" {
+		b.line(depth, "n = size(values, %v, %v)", quote(f.BitsFrom), natural)
+	} else {
+		b.line(depth, "n = %v", *f.Bits)
+	}
+	b.line(depth, "bits = read_bits(tvb, pos, n, %v)", f.Endian == "big")
+
+	value := fmt.Sprintf("to_number(bits, n, %v)", signed)
+	switch {
+	case f.Kind == "bool":
+		value = "to_number(bits, n, false) > 0"
+	case natural == 64:
+		value = fmt.Sprintf("to_int64(bits, n, %v)", signed)
+	}
+	b.line(depth, "value = %v", value)
+	b.line(depth, "item = tree:add(fields[%v], span(tvb, pos, pos + n), value)", quote(key))
+
+	//the values kept as sizes are plain numbers
+	switch {
+	case store == "" || f.Kind == "bool":
+	case natural == 64:
+		b.line(depth, "%v = to_number(bits, n, %v)", store, signed)
+	default:
+		b.line(depth, "%v = value", store)
+	}
+
+	for _, flag := range f.Flags {
+		flagKey := key + "." + names.Snake(flag.Name)
+		g.protoField(flagKey, fmt.Sprintf("ProtoField.bool(%v, %v)", quote(g.abbr(flagKey)), quote(flag.Name)))
+		b.line(depth, "item:add(fields[%v], span(tvb, pos, pos + n), (bits[%v] or 0) == 1)", quote(flagKey), flag.Bit)
+	}
+	b.line(depth, "pos = pos + n")
+	return nil
+}
+
+//protoField adds the ProtoField declaration of key, the fields of a struct used more than once are declared once.
+func (g *generator) protoField(key, declaration string) {
+	line := fmt.Sprintf("fields[%v] = %v\n", quote(key), declaration)
+	for _, f := range g.fields {
+		if f == line {
+			return
+		}
+	}
+	g.fields = append(g.fields, line)
+}
+
+//abbr returns the display filter name of the field key.
+func (g *generator) abbr(key string) string {
+	return g.proto + "." + key
+}
+
+//countExpr returns the Lua expression of a `size` or `strlen` tag, or rest when the tag is empty.
+func countExpr(size, rest string) string {
+	if size == "" {
+		return rest
+	}
+	if _, err := strconv.ParseUint(size, 10, 63); err == nil {
+		return size
+	}
+	return fmt.Sprintf("size(values, %v)", quote(size))
+}
+
+//validName checks name can be used as a protocol name in display filters.
+func validName(name string) error {
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case i > 0 && (r == '_' || r == '-' || (r >= '0' && r <= '9')):
+		default:
+			return fmt.Errorf("%q is not a valid protocol name", name)
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("empty protocol name")
+	}
+	return nil
+}
+
+//quote returns s as a Lua string.
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+type luaWriter struct {
+	bytes.Buffer
+}
+
+func (w *luaWriter) line(depth int, format string, args ...interface{}) {
+	w.WriteString(strings.Repeat("\t", depth))
+	fmt.Fprintf(w, format, args...)
+	w.WriteByte('\n')
+}
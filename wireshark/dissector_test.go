@@ -0,0 +1,130 @@
+package wireshark
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nathanhack/binary"
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+type msgFlags uint8
+
+var _ = binary.RegisterFlags(msgFlags(0), "Ack", "", "Retry")
+
+type point struct {
+	X int16 `bits:"12" endian:"big"`
+	Y int16 `bits:"12" endian:"big"`
+}
+
+type message struct {
+	Version uint8    `bits:"4"`
+	Flags   msgFlags `bits:"4"`
+	Length  uint16   `endian:"big"`
+	Count   uint8
+	Name    string `strlen:"Length"`
+	Points  []point `size:"Count"`
+	Origin  point
+	Key     [4]byte
+	Stamp   uint64
+}
+
+type fixed struct {
+	Kind  uint8
+	Value int32 `endian:"big"`
+}
+
+type custom struct{}
+
+func (custom) MarshalBits() (*bits.BitSetBuffer, error) { return &bits.BitSetBuffer{}, nil }
+
+func TestDissector(t *testing.T) {
+	lua, err := Dissector(reflect.TypeOf(message{}), Config{Name: "msg", Description: "Message", UDPPorts: []int{4000, 4001}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(lua)
+
+	expected := []string{
+		`-- Code generated by wireshark.Dissector from message. DO NOT EDIT.`,
+		`local proto = Proto("msg", "Message")`,
+		`fields["version"] = ProtoField.uint8("msg.version", "Version", base.DEC)`,
+		`fields["flags"] = ProtoField.uint8("msg.flags", "Flags", base.HEX)`,
+		`fields["flags.ack"] = ProtoField.bool("msg.flags.ack", "Ack")`,
+		`fields["flags.retry"] = ProtoField.bool("msg.flags.retry", "Retry")`,
+		`fields["name"] = ProtoField.string("msg.name", "Name")`,
+		`fields["point.x"] = ProtoField.int16("msg.point.x", "X", base.DEC)`,
+		`fields["key"] = ProtoField.bytes("msg.key", "Key")`,
+		`fields["stamp"] = ProtoField.uint64("msg.stamp", "Stamp", base.DEC)`,
+		"\tbits = read_bits(tvb, pos, n, true)\n\tvalue = to_number(bits, n, true)\n",
+		`item:add(fields["flags.retry"], span(tvb, pos, pos + n), (bits[2] or 0) == 1)`,
+		"\tn = size(values, \"Length\")\n\tr = read_bytes(tvb, pos, n)\n",
+		`local count = size(values, "Count")`,
+		`local tree = tree:add(span(tvb, pos, pos), "Points" .. "[" .. i .. "]")`,
+		`pos = dissect_point(tvb, tree, pos, values)`,
+		`value = to_int64(bits, n, false)`,
+		`DissectorTable.get("udp.port"):add(4000, proto)`,
+		`DissectorTable.get("udp.port"):add(4001, proto)`,
+		`DissectorTable.get("tcp.port"):add_for_decode_as(proto)`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("expected the dissector to contain %q:\n%v", e, s)
+		}
+	}
+
+	//point is used three times but dissected by one function with one set of fields
+	if n := strings.Count(s, "local function dissect_point("); n != 1 {
+		t.Errorf("expected dissect_point once but found it %v times", n)
+	}
+	if n := strings.Count(s, `fields["point.y"] =`); n != 1 {
+		t.Errorf("expected the field point.y once but found it %v times", n)
+	}
+	if strings.Index(s, "local function dissect_point(") > strings.Index(s, "local function dissect_message(") {
+		t.Errorf("expected dissect_point before dissect_message")
+	}
+	//message has no static size so it is not reassembled
+	if strings.Contains(s, "desegment_len") {
+		t.Errorf("expected no reassembly")
+	}
+}
+
+func TestDissectorStaticSize(t *testing.T) {
+	lua, err := Dissector(reflect.TypeOf(&fixed{}), Config{TCPPorts: []int{7000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(lua)
+
+	expected := []string{
+		`local proto = Proto("fixed", "fixed")`,
+		"\tlocal length = 5\n",
+		"\t\tpinfo.desegment_len = length - tvb:len()\n",
+		`local ok, err = pcall(dissect_fixed, tvb, subtree, 0, {})`,
+		`DissectorTable.get("tcp.port"):add(7000, proto)`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("expected the dissector to contain %q:\n%v", e, s)
+		}
+	}
+}
+
+func TestDissectorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		t      reflect.Type
+		config Config
+	}{
+		{"not a struct", reflect.TypeOf(0), Config{}},
+		{"protocol name", reflect.TypeOf(fixed{}), Config{Name: "Fixed"}},
+		{"port", reflect.TypeOf(fixed{}), Config{UDPPorts: []int{70000}}},
+		{"custom", reflect.TypeOf(struct{ C custom }{}), Config{Name: "c"}},
+	}
+	for _, test := range tests {
+		if _, err := Dissector(test.t, test.config); err == nil {
+			t.Errorf("%v: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package wireshark
+
+//runtime are the Lua functions shared by the dissectors. Bits are numbered from the least significant bit of the
+// first byte, the same as this library packs them.
+const runtime = `
+-- read_bits returns the n bits at pos as a table of 0s and 1s, least significant first. Big endian values have their
+-- partial byte first followed by the whole bytes from the most significant.
+local function read_bits(tvb, pos, n, big)
+	local bits = {}
+	local function bit(p)
+		local byte = tvb:range(math.floor(p / 8), 1):uint()
+		return math.floor(byte / 2 ^ (p % 8)) % 2
+	end
+	if not big or n <= 8 then
+		for i = 0, n - 1 do
+			bits[i] = bit(pos + i)
+		end
+		return bits
+	end
+	local start = 0
+	while start < n do
+		local stop = math.min(start + 8, n)
+		for i = 0, stop - start - 1 do
+			bits[start + i] = bit(pos + n - stop + i)
+		end
+		start = stop
+	end
+	return bits
+end
+
+-- to_number returns the value of n bits.
+local function to_number(bits, n, signed)
+	local v = 0
+	for i = n - 1, 0, -1 do
+		v = v * 2 + bits[i]
+	end
+	if signed and n > 0 and bits[n - 1] == 1 then
+		v = v - 2 ^ n
+	end
+	return v
+end
+
+-- to_int64 returns the value of n bits as an Int64 or UInt64.
+local function to_int64(bits, n, signed)
+	local lo, hi = 0, 0
+	for i = n - 1, 0, -1 do
+		if i >= 32 then
+			hi = hi * 2 + bits[i]
+		else
+			lo = lo * 2 + bits[i]
+		end
+	end
+	if not signed then
+		return UInt64.new(lo, hi)
+	end
+	if n > 0 and bits[n - 1] == 1 then
+		-- the sign is extended over the bits above n
+		if n < 32 then
+			lo = lo + 2 ^ 32 - 2 ^ n
+		end
+		if n < 64 then
+			hi = hi + 2 ^ 32 - 2 ^ math.max(n - 32, 0)
+		end
+	end
+	return Int64.new(lo, hi)
+end
+
+-- read_bytes returns a range of the n bytes at pos, copied when pos is not on a byte boundary.
+local function read_bytes(tvb, pos, n)
+	if pos % 8 == 0 or n == 0 then
+		return tvb:range(math.floor(pos / 8), n)
+	end
+	local hex = {}
+	for i = 0, n - 1 do
+		hex[#hex + 1] = string.format("%02x", to_number(read_bits(tvb, pos + 8 * i, 8, false), 8, false))
+	end
+	return ByteArray.new(table.concat(hex)):tvb("Unaligned"):range(0, n)
+end
+
+-- span returns the range of the bytes holding the bits from pos up to stop.
+local function span(tvb, pos, stop)
+	local first = math.floor(pos / 8)
+	return tvb:range(first, math.ceil(stop / 8) - first)
+end
+
+-- size returns the value of the field name decoded before, checking it is a size of at most max.
+local function size(values, name, max)
+	local v = values[name]
+	if type(v) ~= "number" or v < 0 or (max and v > max) then
+		error(name .. " is not a valid size")
+	end
+	return v
+end
+`
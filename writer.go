@@ -0,0 +1,200 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+//AppendEncode encodes st the same as Encode and appends the bytes to dst, returning the extended slice. When dst has
+// the capacity for the result and st has no slices, strings or custom encoding nothing is allocated.
+func AppendEncode(dst []byte, st interface{}, options ...EncDecOption) (_ []byte, err error) {
+	defer recoverError(&err)
+
+	w := writerPool.Get().(*byteWriter)
+	w.reset(dst)
+	defer func() {
+		w.reset(nil)
+		writerPool.Put(w)
+	}()
+
+	if err := encodeStruct(w, st, options...); err != nil {
+		return dst, err
+	}
+	return w.bytes, nil
+}
+
+//EncodeInto encodes st the same as EncodeToBits but writes the bits to buf.
+func EncodeInto(buf bits.BitSetWriter, st interface{}, options ...EncDecOption) (err error) {
+	defer recoverError(&err)
+
+	if buf == nil {
+		return fmt.Errorf("nil parameters not allowed")
+	}
+	return encodeStruct(buf, st, options...)
+}
+
+var (
+	writerPool = sync.Pool{
+		New: func() interface{} { return &byteWriter{} },
+	}
+	sizeMapPool = sync.Pool{
+		New: func() interface{} { return map[string]int{} },
+	}
+)
+
+//getSizeMap returns an empty sizeMap from the pool, it is returned with putSizeMap.
+func getSizeMap() map[string]int {
+	m := sizeMapPool.Get().(map[string]int)
+	for k := range m {
+		delete(m, k)
+	}
+	return m
+}
+
+func putSizeMap(m map[string]int) {
+	sizeMapPool.Put(m)
+}
+
+//byteWriter is a BitSetWriter that packs the bits straight into bytes, in the same order as BitSetBuffer.Bytes.
+type byteWriter struct {
+	bytes []byte
+	//used is the number of bits used in the last byte, 0 when it is full
+	used uint
+	//start is the number of bytes of dst the writer was reset with
+	start int
+}
+
+func (w *byteWriter) reset(dst []byte) {
+	w.bytes = dst
+	w.used = 0
+	w.start = len(dst)
+}
+
+//pos returns the number of bits written since the writer was reset.
+func (w *byteWriter) pos() int {
+	n := 8 * (len(w.bytes) - w.start)
+	if w.used > 0 {
+		n -= 8 - int(w.used)
+	}
+	return n
+}
+
+func (w *byteWriter) Write(bytes []byte) (int, error) {
+	if w.used == 0 {
+		w.bytes = append(w.bytes, bytes...)
+		return len(bytes), nil
+	}
+
+	for _, b := range bytes {
+		last := len(w.bytes) - 1
+		w.bytes[last] |= b << w.used
+		w.bytes = append(w.bytes, b>>(8-w.used))
+	}
+	return len(bytes), nil
+}
+
+func (w *byteWriter) WriteBits(bits []bool) (int, error) {
+	for _, bit := range bits {
+		if w.used == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+		if bit {
+			w.bytes[len(w.bytes)-1] |= 1 << w.used
+		}
+		w.used = (w.used + 1) % 8
+	}
+	return len(bits), nil
+}
+
+//writeUint writes the lowest n bits of value to buf, the same as bits.WriteUint but without allocating.
+func writeUint(buf bits.BitSetWriter, n int, endianness binary.ByteOrder, value uint64) error {
+	var scratch [64]bool
+	b := scratch[:n]
+	for i := range b {
+		b[i] = value&(1<<uint(i)) > 0
+	}
+	return writeOrdered(buf, b, endianness)
+}
+
+//writeInt writes value in n bits to buf, the same as bits.WriteInt but without allocating.
+func writeInt(buf bits.BitSetWriter, n int, endianness binary.ByteOrder, value int64) error {
+	var scratch [64]bool
+	b := scratch[:n]
+	for i := 0; i < n-1; i++ {
+		b[i] = value&(1<<uint(i)) > 0
+	}
+	b[n-1] = value < 0
+	return writeOrdered(buf, b, endianness)
+}
+
+func boolBits(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+//writeOrdered writes the little endian bits b to buf in the given endianness.
+func writeOrdered(buf bits.BitSetWriter, b []bool, endianness binary.ByteOrder) error {
+	if m, ok := buf.(*msbWriter); ok {
+		return m.writeNumber(b, endianness)
+	}
+	if endianness != binary.BigEndian {
+		return writeBits(buf, b)
+	}
+
+	var scratch [64]bool
+	return writeBits(buf, swapBytes(scratch[:len(b)], b))
+}
+
+//swapBytes puts the little endian bits b in dst as big endian bits, the bytes swapped with any partial byte coming
+// first, and returns dst.
+func swapBytes(dst, b []bool) []bool {
+	for start := 0; start < len(b); start += 8 {
+		end := start + 8
+		if end > len(b) {
+			end = len(b)
+		}
+		copy(dst[len(b)-end:], b[start:end])
+	}
+	return dst
+}
+
+//unswapBytes undoes swapBytes, putting the big endian bits b in dst as little endian bits, and returns dst.
+func unswapBytes(dst, b []bool) []bool {
+	for start := 0; start < len(b); start += 8 {
+		end := start + 8
+		if end > len(b) {
+			end = len(b)
+		}
+		copy(dst[start:end], b[len(b)-end:])
+	}
+	return dst
+}
+
+//writeBits writes b to buf. The writers of this package and BitSetBuffer are called directly so b can stay on the
+// stack, any other writer is given a copy.
+func writeBits(buf bits.BitSetWriter, b []bool) error {
+	var n int
+	var err error
+	switch w := buf.(type) {
+	case *byteWriter:
+		n, err = w.WriteBits(b)
+	case *bitCounter:
+		n, err = w.WriteBits(b)
+	case *bits.BitSetBuffer:
+		n, err = w.WriteBits(b)
+	default:
+		n, err = buf.WriteBits(append([]bool(nil), b...))
+	}
+	if err != nil {
+		return err
+	}
+	if n != len(b) {
+		return fmt.Errorf("only %v of %v bits written", n, len(b))
+	}
+	return nil
+}
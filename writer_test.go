@@ -0,0 +1,119 @@
+package binary
+
+import (
+	"bytes"
+	"testing"
+
+	bits "github.com/nathanhack/bitsetbuffer"
+)
+
+func writerInputs() []interface{} {
+	return []interface{}{
+		ipv4Header{Version: 4, IHL: 5, TotalLength: 20, Flags: ipDontFrag, FragOffset: 0x1234, TTL: 64, Protocol: 6,
+			Source: [4]byte{10, 0, 0, 1}, Destination: [4]byte{10, 0, 0, 2}},
+		routedInput(),
+		&fuzzSized{Count: 2, Items: []int16{-1, 1}, Length: 3, Name: "abc", Rest: []byte{1, 2}},
+		struct {
+			A bool   `bits:"1"`
+			B string `strlen:"2"`
+			C int16  `bits:"11" endian:"big"`
+			D float32
+			E float64 `endian:"big"`
+			F []int64 `size:"1"`
+		}{true, "hi", -3, 1.5, -2.25, []int64{-7}},
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	prefix := []byte{0xaa, 0xbb}
+	for _, input := range writerInputs() {
+		buf, err := EncodeToBits(input)
+		if err != nil {
+			t.Fatalf("expected no encoding error found: %v", err)
+		}
+		expected := append(append([]byte{}, prefix...), buf.Bytes()...)
+
+		actual, err := AppendEncode(append([]byte{}, prefix...), input)
+		if err != nil {
+			t.Fatalf("expected no encoding error found: %v", err)
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Fatalf("%T: expected \n%x\n but found \n%x\n", input, expected, actual)
+		}
+	}
+}
+
+func TestEncodeInto(t *testing.T) {
+	for _, input := range writerInputs() {
+		expected, err := EncodeToBits(input)
+		if err != nil {
+			t.Fatalf("expected no encoding error found: %v", err)
+		}
+
+		//bits already in the buffer are kept
+		buf := &bits.BitSetBuffer{}
+		buf.WriteBits([]bool{true})
+		if err := EncodeInto(buf, input); err != nil {
+			t.Fatalf("expected no encoding error found: %v", err)
+		}
+		if len(buf.Set) != len(expected.Set)+1 {
+			t.Fatalf("%T: expected %v bits but found %v", input, len(expected.Set)+1, len(buf.Set))
+		}
+		for i, bit := range expected.Set {
+			if buf.Set[i+1] != bit {
+				t.Fatalf("%T: bit %v differs", input, i)
+			}
+		}
+	}
+}
+
+func TestAppendEncodeAllocs(t *testing.T) {
+	input := writerInputs()[0].(ipv4Header)
+	dst := make([]byte, 0, 64)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := AppendEncode(dst, &input); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations but found %v", allocs)
+	}
+}
+
+func BenchmarkAppendEncode(b *testing.B) {
+	input := writerInputs()[0].(ipv4Header)
+	dst := make([]byte, 0, 64)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := AppendEncode(dst, &input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	input := writerInputs()[0].(ipv4Header)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Encode(&input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeInto(b *testing.B) {
+	input := writerInputs()[0].(ipv4Header)
+	buf := &bits.BitSetBuffer{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Set = buf.Set[:0]
+		buf.ResetToStart()
+		if err := EncodeInto(buf, &input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}